@@ -0,0 +1,197 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/apierror"
+	"github.com/jamistoso/chirpy/internal/audit"
+	"github.com/jamistoso/chirpy/internal/auth"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/decode"
+	"github.com/jamistoso/chirpy/internal/logging"
+	"github.com/jamistoso/chirpy/internal/metrics"
+)
+
+const (
+	stateActive       = "active"
+	stateSuspended    = "suspended"
+	stateShadowBanned = "shadow_banned"
+	stateDeactivated  = "deactivated"
+)
+
+// accountState returns the state currently in force for dbUser. States with
+// an expiry lapse back to active once it passes.
+func accountState(dbUser database.User) string {
+	if dbUser.StateExpiresAt.Valid && !time.Now().Before(dbUser.StateExpiresAt.Time) {
+		return stateActive
+	}
+	return dbUser.State
+}
+
+// checkAccountState writes a 403 and returns false for accounts that may not
+// use the API. Shadow-banned users are let through on purpose: they shouldn't
+// be able to tell they've been banned.
+func checkAccountState(rWriter http.ResponseWriter, rq *http.Request, dbUser database.User) bool {
+	switch accountState(dbUser) {
+	case stateSuspended:
+		respondWithError(rWriter, rq, apierror.Forbidden(apierror.CodeAccountSuspended, "account suspended"))
+		return false
+	case stateDeactivated:
+		respondWithError(rWriter, rq, apierror.Forbidden(apierror.CodeAccountDeactivated, "account deactivated"))
+		return false
+	}
+	return true
+}
+
+// authenticate validates the request's access token and loads the user it
+// was issued to, writing the error response itself when either fails or the
+// account isn't allowed to act.
+func (cfg *apiConfig) authenticate(rWriter http.ResponseWriter, rq *http.Request) (database.User, bool) {
+	jwtToken, err := auth.GetBearerToken(rq.Header)
+	if err != nil {
+		cfg.metrics.AuthFailure(metrics.AuthAccessToken)
+		respondWithError(rWriter, rq, apierror.Unauthorized(apierror.CodeUnauthorized, "missing bearer token"))
+		return database.User{}, false
+	}
+
+	authID, err := cfg.jwtKeys.ValidateJWT(jwtToken)
+	if err != nil {
+		cfg.metrics.AuthFailure(metrics.AuthAccessToken)
+		respondWithError(rWriter, rq, apierror.Unauthorized(apierror.CodeInvalidToken, "invalid or expired access token"))
+		return database.User{}, false
+	}
+
+	dbUser, err := cfg.users.GetUserFromID(rq.Context(), authID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rWriter, rq, fmt.Errorf("looking up user: %w", err))
+		return database.User{}, false
+	}
+	if err != nil {
+		cfg.metrics.AuthFailure(metrics.AuthAccessToken)
+		respondWithError(rWriter, rq, apierror.Unauthorized(apierror.CodeInvalidToken, "user not found"))
+		return database.User{}, false
+	}
+
+	logging.SetUserID(rq.Context(), dbUser.ID)
+
+	if !checkAccountState(rWriter, rq, dbUser) {
+		return database.User{}, false
+	}
+	return dbUser, true
+}
+
+// requireAdmin is authenticate plus a check that the user is an admin.
+func (cfg *apiConfig) requireAdmin(rWriter http.ResponseWriter, rq *http.Request) (database.User, bool) {
+	dbUser, ok := cfg.authenticate(rWriter, rq)
+	if !ok {
+		return database.User{}, false
+	}
+
+	if !dbUser.IsAdmin {
+		respondWithError(rWriter, rq, apierror.Forbidden(apierror.CodeForbidden, "admin access required"))
+		return database.User{}, false
+	}
+	return dbUser, true
+}
+
+type accountStateResponse struct {
+	Id               uuid.UUID  `json:"id"`
+	Email            string     `json:"email"`
+	State            string     `json:"state"`
+	State_reason     string     `json:"state_reason"`
+	State_expires_at *time.Time `json:"state_expires_at"`
+}
+
+func newAccountStateResponse(u database.User) accountStateResponse {
+	resp := accountStateResponse{
+		Id:           u.ID,
+		Email:        u.Email,
+		State:        u.State,
+		State_reason: u.StateReason,
+	}
+	if u.StateExpiresAt.Valid {
+		resp.State_expires_at = &u.StateExpiresAt.Time
+	}
+	return resp
+}
+
+// userStateHandler lets admins suspend, shadow-ban, deactivate or reinstate
+// an account, optionally until a given time.
+func (cfg *apiConfig) userStateHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	type parameters struct {
+		State     string     `json:"state" validate:"required,oneof=active suspended shadow_banned deactivated"`
+		Reason    string     `json:"reason" validate:"max=500"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	admin, ok := cfg.requireAdmin(rWriter, rq)
+	if !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(rq.PathValue("userID"))
+	if err != nil {
+		respondWithError(rWriter, rq, apierror.InvalidParameter("userID", "must be a UUID"))
+		return
+	}
+
+	rqParams := parameters{}
+	err = decode.JSON(rWriter, rq, &rqParams)
+	if err != nil {
+		respondWithError(rWriter, rq, err)
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if rqParams.ExpiresAt != nil {
+		if rqParams.State == stateActive {
+			respondWithError(rWriter, rq, apierror.Validation(apierror.FieldError{
+				Field:   "expires_at",
+				Message: "must not be set for the active state",
+			}))
+			return
+		}
+		if !rqParams.ExpiresAt.After(time.Now()) {
+			respondWithError(rWriter, rq, apierror.Validation(apierror.FieldError{
+				Field:   "expires_at",
+				Message: "must be in the future",
+			}))
+			return
+		}
+		expiresAt = sql.NullTime{Time: *rqParams.ExpiresAt, Valid: true}
+	}
+
+	before, err := cfg.users.GetUserFromID(rq.Context(), userID)
+	if err != nil {
+		respondWithError(rWriter, rq, apierror.NoRows(err, apierror.NotFound("user not found")))
+		return
+	}
+
+	dbUser, err := cfg.users.SetUserState(rq.Context(), database.SetUserStateParams{
+		State:          rqParams.State,
+		StateReason:    rqParams.Reason,
+		StateExpiresAt: expiresAt,
+		ID:             userID,
+	})
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("updating account state: %w", err))
+		return
+	}
+
+	cfg.audit(rq, audit.Event{
+		ActorID:    admin.ID,
+		ActorType:  audit.ActorAdmin,
+		Action:     "user.state_change",
+		TargetType: "user",
+		TargetID:   dbUser.ID.String(),
+		Before:     newAccountStateResponse(before),
+		After:      newAccountStateResponse(dbUser),
+	})
+
+	respondWithJSON(rWriter, 200, newAccountStateResponse(dbUser))
+}
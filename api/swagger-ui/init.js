@@ -0,0 +1,7 @@
+// Starts Swagger UI on the spec. It is a file of its own rather than inline
+// in docs.html so that the page's Content-Security-Policy can forbid inline
+// scripts.
+window.ui = SwaggerUIBundle({
+  url: "/api/openapi.json",
+  dom_id: "#swagger-ui",
+});
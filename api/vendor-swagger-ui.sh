@@ -0,0 +1,17 @@
+#!/bin/sh
+# Vendors the Swagger UI release that /api/docs serves into api/swagger-ui.
+# The release's files are committed under dist/ at its tag, so they are
+# fetched as a Go module, which go checks against the checksum database.
+# Commit the files it writes.
+set -eu
+
+VERSION=5.17.14
+dir=$(cd "$(dirname "$0")" && pwd)/swagger-ui
+src=$(cd "$dir" && GOFLAGS=-mod=mod go mod download -json "github.com/swagger-api/swagger-ui@v$VERSION+incompatible" |
+	sed -n 's/^[[:space:]]*"Dir": "\(.*\)",$/\1/p')
+
+for file in dist/swagger-ui.css dist/swagger-ui-bundle.js LICENSE NOTICE; do
+	cp "$src/$file" "$dir/$(basename "$file")"
+	chmod 644 "$dir/$(basename "$file")"
+done
+echo "$VERSION" >"$dir/VERSION"
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/apierror"
+	"github.com/jamistoso/chirpy/internal/audit"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/logging"
+)
+
+const (
+	auditPageSize    = 50
+	auditMaxPageSize = 200
+)
+
+// audit records e, logging rather than failing the request if the write
+// doesn't go through.
+func (cfg *apiConfig) audit(rq *http.Request, e audit.Event) {
+	err := cfg.auditor.Record(rq, e)
+	if err != nil {
+		logging.FromContext(rq.Context()).Error("error writing audit log entry", "action", e.Action, "err", err)
+	}
+}
+
+func (cfg *apiConfig) auditSystem(ctx context.Context, e audit.Event) {
+	err := cfg.auditor.RecordSystem(ctx, e)
+	if err != nil {
+		logging.FromContext(ctx).Error("error writing audit log entry", "action", e.Action, "err", err)
+	}
+}
+
+type auditEntryResponse struct {
+	ID          int64           `json:"id"`
+	Created_at  time.Time       `json:"created_at"`
+	Actor_id    *uuid.UUID      `json:"actor_id"`
+	Actor_type  string          `json:"actor_type"`
+	Action      string          `json:"action"`
+	Target_type string          `json:"target_type"`
+	Target_id   string          `json:"target_id"`
+	Request_id  string          `json:"request_id"`
+	Ip          string          `json:"ip"`
+	Before      json.RawMessage `json:"before"`
+	After       json.RawMessage `json:"after"`
+}
+
+func newAuditEntryResponse(entry database.AuditLog) auditEntryResponse {
+	resp := auditEntryResponse{
+		ID:          entry.ID,
+		Created_at:  entry.CreatedAt,
+		Actor_type:  entry.ActorType,
+		Action:      entry.Action,
+		Target_type: entry.TargetType,
+		Target_id:   entry.TargetID,
+		Request_id:  entry.RequestID,
+		Ip:          entry.Ip,
+		Before:      entry.Before,
+		After:       entry.After,
+	}
+	if entry.ActorID.Valid {
+		resp.Actor_id = &entry.ActorID.UUID
+	}
+	return resp
+}
+
+type auditLogResponse struct {
+	Entries     []auditEntryResponse `json:"entries"`
+	Next_cursor string               `json:"next_cursor,omitempty"`
+}
+
+// auditLogHandler lists audit entries newest first. Filters are exact matches
+// on actor_id, action and target_id plus a since/until time range; pages are
+// walked by passing the previous response's next_cursor as cursor.
+func (cfg *apiConfig) auditLogHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	if _, ok := cfg.requireAdmin(rWriter, rq); !ok {
+		return
+	}
+
+	query := rq.URL.Query()
+	params := database.ListAuditEntriesParams{
+		MaxEntries: auditPageSize,
+	}
+
+	if actorID := query.Get("actor_id"); actorID != "" {
+		id, err := uuid.Parse(actorID)
+		if err != nil {
+			respondWithError(rWriter, rq, apierror.InvalidParameter("actor_id", "must be a UUID"))
+			return
+		}
+		params.ActorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if action := query.Get("action"); action != "" {
+		params.Action = sql.NullString{String: action, Valid: true}
+	}
+	if targetID := query.Get("target_id"); targetID != "" {
+		params.TargetID = sql.NullString{String: targetID, Valid: true}
+	}
+	for _, bound := range []struct {
+		name string
+		dest *sql.NullTime
+	}{
+		{"since", &params.Since},
+		{"until", &params.Until},
+	} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondWithError(rWriter, rq, apierror.InvalidParameter(bound.name, "must be an RFC 3339 time"))
+			return
+		}
+		*bound.dest = sql.NullTime{Time: t, Valid: true}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > auditMaxPageSize {
+			respondWithError(rWriter, rq, apierror.InvalidParameter("limit", "must be between 1 and "+strconv.Itoa(auditMaxPageSize)))
+			return
+		}
+		params.MaxEntries = int32(n)
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		beforeID, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil {
+			respondWithError(rWriter, rq, apierror.InvalidParameter("cursor", "must be a cursor from a previous page"))
+			return
+		}
+		params.BeforeID = sql.NullInt64{Int64: beforeID, Valid: true}
+	}
+
+	entries, err := cfg.dbQueries.ListAuditEntries(rq.Context(), params)
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("retrieving audit log: %w", err))
+		return
+	}
+
+	respBody := auditLogResponse{Entries: []auditEntryResponse{}}
+	for _, entry := range entries {
+		respBody.Entries = append(respBody.Entries, newAuditEntryResponse(entry))
+	}
+	if len(entries) == int(params.MaxEntries) {
+		respBody.Next_cursor = strconv.FormatInt(entries[len(entries)-1].ID, 10)
+	}
+
+	respondWithJSON(rWriter, 200, respBody)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jamistoso/chirpy/internal/config"
+	"github.com/jamistoso/chirpy/internal/logging"
+	"github.com/jamistoso/chirpy/internal/sqlite"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+// loadConfig reads the configuration from path, or CHIRPY_CONFIG when path
+// is empty, and the environment, which an optional .env file adds to.
+func loadConfig(path string) (config.Config, error) {
+	err := godotenv.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return config.Config{}, fmt.Errorf("reading .env: %w", err)
+	}
+	if path == "" {
+		path = os.Getenv("CHIRPY_CONFIG")
+	}
+	return config.Load(path, os.LookupEnv)
+}
+
+// openDB opens the database named by DB_URL, SQLite for sqlite: URLs and
+// Postgres otherwise.
+func openDB(conf config.Config) (*sql.DB, error) {
+	if sqlite.IsURL(conf.DatabaseURL) {
+		return sqlite.Open(conf.DatabaseURL)
+	}
+	return sql.Open("postgres", conf.DatabaseURL)
+}
+
+type command struct {
+	name    string
+	summary string
+	run     func(conf config.Config, args []string) error
+}
+
+var commands = []command{
+	{"serve", "run the HTTP server (the default)", runServe},
+	{"migrate", "apply or roll back database migrations", runMigrate},
+	{"seed", "fill a dev database with fake users and chirps", runSeed},
+	{"create-admin", "create an admin account, or promote an existing one", runCreateAdmin},
+	{"revoke-sessions", "revoke every refresh token of a user", runRevokeSessions},
+	{"rotate-keys", "start signing access tokens with a new key", runRotateKeys},
+	{"config", "print the configuration, with secrets redacted", runConfig},
+}
+
+func main() {
+	err := run(os.Args[1:])
+	// On -h the flag package has already printed the command's usage.
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	global := newFlagSet("chirpy")
+	global.Usage = printUsage
+	configFile := global.String("config", "", "")
+	err := global.Parse(args)
+	if err != nil {
+		return err
+	}
+	args = global.Args()
+
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		printUsage()
+		return nil
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		conf, err := loadConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		// config print shows the settings even when they are invalid, and
+		// says what's wrong with them afterwards.
+		if cmd.name != "config" {
+			err = conf.Validate()
+			if err != nil {
+				return fmt.Errorf("invalid configuration:\n%w", err)
+			}
+			logger, err := logging.New(os.Stderr, conf.Log.Format, conf.Log.Level)
+			if err != nil {
+				return err
+			}
+			slog.SetDefault(logger)
+		}
+		return cmd.run(conf, args)
+	}
+	printUsage()
+	return fmt.Errorf("unknown command %q", name)
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "usage: chirpy [-config file] [command] [flags]\n\ncommands:")
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	w.Flush()
+	fmt.Fprintln(os.Stderr, "\nSettings come from the YAML file given with -config or CHIRPY_CONFIG,")
+	fmt.Fprintln(os.Stderr, "then environment variables and a .env file, then the command's flags.")
+	fmt.Fprintln(os.Stderr, "Run chirpy <command> -h for a command's flags.")
+}
+
+// runConfig implements `chirpy config print`.
+func runConfig(conf config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return errors.New("usage: chirpy config print")
+	}
+	data, err := conf.YAML()
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(data)
+	err = conf.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+// parseFlags parses args, which must all be flags.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("%s: unexpected argument %q", flags.Name(), flags.Arg(0))
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/pkg/chirpyclient"
+)
+
+// codeConstants returns the Code constants declared in the Go file at path,
+// by name.
+func codeConstants(t *testing.T, path string) map[string]string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		t.Fatalf(`parsing %s: %v`, path, err)
+	}
+	codes := map[string]string{}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			for i, name := range spec.(*ast.ValueSpec).Names {
+				lit, ok := spec.(*ast.ValueSpec).Values[i].(*ast.BasicLit)
+				if !ok || !strings.HasPrefix(name.Name, "Code") {
+					continue
+				}
+				codes[name.Name], _ = strconv.Unquote(lit.Value)
+			}
+		}
+	}
+	return codes
+}
+
+// The SDK declares the error codes itself, since it can't import the
+// server's internal packages; they must not drift apart.
+func TestClientErrorCodes(t *testing.T) {
+	server := codeConstants(t, "internal/apierror/apierror.go")
+	client := codeConstants(t, "pkg/chirpyclient/errors.go")
+	if len(server) == 0 || !maps.Equal(server, client) {
+		t.Fatalf(`chirpyclient codes = %v, wanted apierror's %v`, client, server)
+	}
+}
+
+func TestClient(t *testing.T) {
+	server, cfg := newTestServer(t)
+	ctx := context.Background()
+	var saved chirpyclient.Tokens
+	client := chirpyclient.New(server.URL, chirpyclient.OnTokens(func(tokens chirpyclient.Tokens) {
+		saved = tokens
+	}))
+
+	_, err := client.CreateUser(ctx, "walt", "heisenberg")
+	if chirpyclient.ErrorCode(err) != chirpyclient.CodeValidationFailed {
+		t.Fatalf(`CreateUser(bad email) = %v, wanted %s`, err, chirpyclient.CodeValidationFailed)
+	}
+	user, err := client.CreateUser(ctx, "walt@example.com", "heisenberg")
+	if err != nil || user.Email != "walt@example.com" {
+		t.Fatalf(`CreateUser() = %+v, %v`, user, err)
+	}
+	_, err = client.CreateChirp(ctx, "not logged in")
+	if chirpyclient.ErrorCode(err) != chirpyclient.CodeUnauthorized {
+		t.Fatalf(`CreateChirp() logged out = %v, wanted %s`, err, chirpyclient.CodeUnauthorized)
+	}
+	_, err = client.Login(ctx, "walt@example.com", "wrong")
+	if chirpyclient.ErrorCode(err) != chirpyclient.CodeInvalidCredentials {
+		t.Fatalf(`Login(wrong password) = %v, wanted %s`, err, chirpyclient.CodeInvalidCredentials)
+	}
+	loggedIn, err := client.Login(ctx, "walt@example.com", "heisenberg")
+	if err != nil || loggedIn.ID != user.ID || saved.Access == "" || saved.Refresh == "" {
+		t.Fatalf(`Login() = %+v, %v, saving %+v`, loggedIn, err, saved)
+	}
+
+	var posted []chirpyclient.Chirp
+	for _, body := range []string{"Say my name.", "I am the one who knocks.", "Tread lightly."} {
+		chirp, err := client.CreateChirp(ctx, body)
+		if err != nil {
+			t.Fatalf(`CreateChirp(%q) = %v`, body, err)
+		}
+		posted = append(posted, chirp)
+	}
+
+	var listed []chirpyclient.Chirp
+	for chirp, err := range client.Chirps(ctx, chirpyclient.ListChirpsOptions{AuthorID: user.ID, Limit: 2}) {
+		if err != nil {
+			t.Fatalf(`Chirps() yielded %v`, err)
+		}
+		listed = append(listed, chirp)
+	}
+	if len(listed) != 3 || listed[0].ID != posted[0].ID || listed[2].ID != posted[2].ID {
+		t.Fatalf(`Chirps() = %+v, wanted %+v`, listed, posted)
+	}
+	page, err := client.ListChirps(ctx, chirpyclient.ListChirpsOptions{Sort: chirpyclient.SortDesc, Limit: 1})
+	if err != nil || len(page.Chirps) != 1 || page.Chirps[0].ID != posted[2].ID || page.NextCursor == "" {
+		t.Fatalf(`ListChirps(desc, limit 1) = %+v, %v, wanted the newest chirp and a cursor`, page, err)
+	}
+
+	_, err = client.EditChirp(ctx, posted[0].ID, "Say my name!")
+	if chirpyclient.ErrorCode(err) != chirpyclient.CodePlanRequired {
+		t.Fatalf(`EditChirp() on the free plan = %v, wanted %s`, err, chirpyclient.CodePlanRequired)
+	}
+
+	// An expired access token is refreshed without the caller noticing.
+	expired, err := cfg.jwtKeys.MakeJWT(user.ID, -time.Minute)
+	if err != nil {
+		t.Fatalf(`MakeJWT() = %v`, err)
+	}
+	client = chirpyclient.New(server.URL, chirpyclient.WithTokens(chirpyclient.Tokens{
+		Access:  expired,
+		Refresh: saved.Refresh,
+	}))
+	err = client.DeleteChirp(ctx, posted[1].ID)
+	if err != nil {
+		t.Fatalf(`DeleteChirp() with an expired token = %v`, err)
+	}
+	if client.Tokens().Access == expired {
+		t.Fatalf(`DeleteChirp() didn't refresh the access token`)
+	}
+	_, err = client.GetChirp(ctx, posted[1].ID)
+	if chirpyclient.ErrorCode(err) != chirpyclient.CodeNotFound {
+		t.Fatalf(`GetChirp(deleted) = %v, wanted %s`, err, chirpyclient.CodeNotFound)
+	}
+
+	updated, err := client.UpdateUser(ctx, "heisenberg@example.com", "blue sky")
+	if err != nil || updated.Email != "heisenberg@example.com" {
+		t.Fatalf(`UpdateUser() = %+v, %v`, updated, err)
+	}
+	entitlements, err := client.Entitlements(ctx)
+	if err != nil || entitlements.Plan != "free" || entitlements.MaxChirpLength == 0 {
+		t.Fatalf(`Entitlements() = %+v, %v`, entitlements, err)
+	}
+
+	err = client.Revoke(ctx)
+	if err != nil || client.Tokens() != (chirpyclient.Tokens{}) {
+		t.Fatalf(`Revoke() = %v, leaving tokens %+v`, err, client.Tokens())
+	}
+	client = chirpyclient.New(server.URL, chirpyclient.WithTokens(chirpyclient.Tokens{Refresh: saved.Refresh}))
+	err = client.Refresh(ctx)
+	if chirpyclient.ErrorCode(err) != chirpyclient.CodeInvalidToken {
+		t.Fatalf(`Refresh() after Revoke() = %v, wanted %s`, err, chirpyclient.CodeInvalidToken)
+	}
+}
+
+func TestClientAdmin(t *testing.T) {
+	server, cfg := newTestServer(t)
+	ctx := context.Background()
+	client := chirpyclient.New(server.URL)
+
+	admin, err := client.CreateUser(ctx, "gus@example.com", "pollos hermanos")
+	if err != nil {
+		t.Fatalf(`CreateUser() = %v`, err)
+	}
+	walt, err := client.CreateUser(ctx, "walt@example.com", "heisenberg")
+	if err != nil {
+		t.Fatalf(`CreateUser() = %v`, err)
+	}
+	_, err = cfg.dbQueries.SetUserAdmin(ctx, database.SetUserAdminParams{IsAdmin: true, ID: admin.ID})
+	if err != nil {
+		t.Fatalf(`SetUserAdmin() = %v`, err)
+	}
+	_, err = client.Login(ctx, "gus@example.com", "pollos hermanos")
+	if err != nil {
+		t.Fatalf(`Login() = %v`, err)
+	}
+
+	state, err := client.SetAccountState(ctx, walt.ID, chirpyclient.AccountStateChange{
+		State:  chirpyclient.StateSuspended,
+		Reason: "cooking",
+	})
+	if err != nil || state.State != chirpyclient.StateSuspended || state.StateReason != "cooking" {
+		t.Fatalf(`SetAccountState() = %+v, %v`, state, err)
+	}
+	_, err = client.SetAccountState(ctx, uuid.New(), chirpyclient.AccountStateChange{State: chirpyclient.StateActive})
+	if chirpyclient.ErrorCode(err) != chirpyclient.CodeNotFound {
+		t.Fatalf(`SetAccountState(unknown user) = %v, wanted %s`, err, chirpyclient.CodeNotFound)
+	}
+
+	var actions []string
+	for entry, err := range client.AuditEntries(ctx, chirpyclient.AuditFilter{Limit: 1}) {
+		if err != nil {
+			t.Fatalf(`AuditEntries() yielded %v`, err)
+		}
+		actions = append(actions, entry.Action)
+	}
+	want := []string{"user.state_change", "auth.login", "user.signup", "user.signup"}
+	if !slices.Equal(actions, want) {
+		t.Fatalf(`AuditEntries() = %v, wanted %v`, actions, want)
+	}
+
+	_, err = chirpyclient.New(server.URL).ModerationQueue(ctx)
+	if chirpyclient.ErrorCode(err) != chirpyclient.CodeUnauthorized {
+		t.Fatalf(`ModerationQueue() logged out = %v, wanted %s`, err, chirpyclient.CodeUnauthorized)
+	}
+}
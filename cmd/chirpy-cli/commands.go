@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/pkg/chirpyclient"
+)
+
+// runSignup implements `chirpy-cli signup`. It doesn't log in.
+func runSignup(c *cli, args []string) error {
+	flags := c.newFlagSet("signup")
+	email := flags.String("email", "", "`email` of the new account (required)")
+	password := flags.String("password", "", "`password` of the new account; read from CHIRPY_PASSWORD or stdin when empty")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("signup: -email is required")
+	}
+	*password, err = c.password(*password)
+	if err != nil {
+		return err
+	}
+
+	user, err := c.client.CreateUser(context.Background(), *email, *password)
+	if err != nil {
+		return err
+	}
+	return c.printUser(user)
+}
+
+// runLogin implements `chirpy-cli login`, which saves the server and the
+// new session in the config file.
+func runLogin(c *cli, args []string) error {
+	flags := c.newFlagSet("login")
+	email := flags.String("email", "", "`email` of the account (required)")
+	password := flags.String("password", "", "`password` of the account; read from CHIRPY_PASSWORD or stdin when empty")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("login: -email is required")
+	}
+	*password, err = c.password(*password)
+	if err != nil {
+		return err
+	}
+
+	user, err := c.client.Login(context.Background(), *email, *password)
+	if err != nil {
+		return err
+	}
+	c.conf.Server = c.server
+	c.conf.User = &user
+	c.dirty = true
+	return c.printUser(user)
+}
+
+// runLogout implements `chirpy-cli logout`. A session the server has
+// already ended is forgotten all the same.
+func runLogout(c *cli, args []string) error {
+	err := parseFlags(c.newFlagSet("logout"), args)
+	if err != nil {
+		return err
+	}
+	if c.client.Tokens().Refresh == "" {
+		return errors.New("not logged in")
+	}
+
+	err = c.client.Revoke(context.Background())
+	if err != nil && chirpyclient.ErrorCode(err) != chirpyclient.CodeInvalidToken {
+		return err
+	}
+	c.conf.User = nil
+	c.conf.Tokens = chirpyclient.Tokens{}
+	c.dirty = true
+	c.notef("logged out of %s", c.server)
+	return nil
+}
+
+// session is the output of `chirpy-cli session`. The tokens themselves are
+// left out: they only belong in the config file.
+type session struct {
+	Server          string             `json:"server"`
+	LoggedIn        bool               `json:"logged_in"`
+	User            *chirpyclient.User `json:"user"`
+	AccessExpiresAt *time.Time         `json:"access_token_expires_at"`
+}
+
+// runSession implements `chirpy-cli session [show|refresh]`.
+func runSession(c *cli, args []string) error {
+	flags := c.newFlagSet("session")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	switch flags.Arg(0) {
+	case "", "show":
+	case "refresh":
+		if c.client.Tokens().Refresh == "" {
+			return errors.New("not logged in")
+		}
+		err = c.client.Refresh(context.Background())
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("usage: chirpy-cli session [show|refresh]")
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("session: unexpected argument %q", flags.Arg(1))
+	}
+
+	tokens := c.client.Tokens()
+	s := session{Server: c.server, LoggedIn: tokens.Refresh != ""}
+	if s.LoggedIn {
+		s.User = c.conf.User
+		s.AccessExpiresAt = tokenExpiry(tokens.Access)
+	}
+	row := []string{s.Server, "-", "-", "-"}
+	if s.User != nil {
+		row[1], row[2] = s.User.Email, s.User.ID.String()
+	}
+	if s.AccessExpiresAt != nil {
+		row[3] = formatTime(*s.AccessExpiresAt)
+	}
+	if !s.LoggedIn {
+		row[1] = "(not logged in)"
+	}
+	return c.print(s, []string{"SERVER", "EMAIL", "USER ID", "ACCESS TOKEN EXPIRES"}, [][]string{row})
+}
+
+// tokenExpiry reads the expiry of a JWT without checking its signature,
+// which only the server can. It returns nil if there is none to read.
+func tokenExpiry(token string) *time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil || claims.Exp == 0 {
+		return nil
+	}
+	exp := time.Unix(claims.Exp, 0)
+	return &exp
+}
+
+// runAccount implements `chirpy-cli account`. The server sets the email and
+// password together, so the email defaults to the current one.
+func runAccount(c *cli, args []string) error {
+	flags := c.newFlagSet("account")
+	email := flags.String("email", "", "new `email`; the current one when empty")
+	password := flags.String("password", "", "new `password`; read from CHIRPY_PASSWORD or stdin when empty")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if *email == "" {
+		if c.conf.User == nil {
+			return errors.New("account: -email is required when not logged in")
+		}
+		*email = c.conf.User.Email
+	}
+	*password, err = c.password(*password)
+	if err != nil {
+		return err
+	}
+
+	user, err := c.client.UpdateUser(context.Background(), *email, *password)
+	if err != nil {
+		return err
+	}
+	c.conf.User = &user
+	c.dirty = true
+	return c.printUser(user)
+}
+
+// runChirps implements `chirpy-cli chirps`, which prints a page of chirps,
+// or all of them with -all.
+func runChirps(c *cli, args []string) error {
+	flags := c.newFlagSet("chirps")
+	author := flags.String("author", "", "only list the chirps of the user with this `id`, or \"me\"")
+	order := flags.String("sort", chirpyclient.SortAsc, "`order` by creation time: asc or desc")
+	limit := flags.Int("limit", 20, "the most chirps to fetch at a time")
+	cursor := flags.String("cursor", "", "continue after a previous page: its next `cursor`")
+	all := flags.Bool("all", false, "fetch every page")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if *order != chirpyclient.SortAsc && *order != chirpyclient.SortDesc {
+		return fmt.Errorf("chirps: -sort must be asc or desc, not %q", *order)
+	}
+	if *limit < 1 {
+		return errors.New("chirps: -limit must be at least 1")
+	}
+
+	opts := chirpyclient.ListChirpsOptions{Sort: *order, Limit: *limit, Cursor: *cursor}
+	switch {
+	case *author == "me":
+		if c.conf.User == nil {
+			return errors.New("chirps: -author me needs a login")
+		}
+		opts.AuthorID = c.conf.User.ID
+	case *author != "":
+		opts.AuthorID, err = uuid.Parse(*author)
+		if err != nil {
+			return fmt.Errorf("chirps: -author %q isn't a user id", *author)
+		}
+	}
+
+	ctx := context.Background()
+	if !*all {
+		page, err := c.client.ListChirps(ctx, opts)
+		if err != nil {
+			return err
+		}
+		return c.printChirps(page.Chirps, page.NextCursor)
+	}
+	var chirps []chirpyclient.Chirp
+	for chirp, err := range c.client.Chirps(ctx, opts) {
+		if err != nil {
+			return err
+		}
+		chirps = append(chirps, chirp)
+	}
+	return c.printChirps(chirps, "")
+}
+
+// runChirp implements `chirpy-cli chirp <id>`.
+func runChirp(c *cli, args []string) error {
+	flags := c.newFlagSet("chirp")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: chirpy-cli chirp <id>")
+	}
+	id, err := parseChirpID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	chirp, err := c.client.GetChirp(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	return c.printChirp(chirp)
+}
+
+// runPost implements `chirpy-cli post <body>`. The body is read from stdin
+// when it is missing or "-".
+func runPost(c *cli, args []string) error {
+	flags := c.newFlagSet("post")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	body, err := c.body(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	chirp, err := c.client.CreateChirp(context.Background(), body)
+	if err != nil {
+		return err
+	}
+	return c.printChirp(chirp)
+}
+
+// runEdit implements `chirpy-cli edit <id> <body>`, reading the body like
+// post does.
+func runEdit(c *cli, args []string) error {
+	flags := c.newFlagSet("edit")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		return errors.New("usage: chirpy-cli edit <id> [body]")
+	}
+	id, err := parseChirpID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	body, err := c.body(flags.Args()[1:])
+	if err != nil {
+		return err
+	}
+
+	chirp, err := c.client.EditChirp(context.Background(), id, body)
+	if err != nil {
+		return err
+	}
+	return c.printChirp(chirp)
+}
+
+// runDelete implements `chirpy-cli delete <id>...`. It stops at the first
+// chirp it can't delete.
+func runDelete(c *cli, args []string) error {
+	flags := c.newFlagSet("delete")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: chirpy-cli delete <id>...")
+	}
+	ids := make([]uuid.UUID, flags.NArg())
+	for i, arg := range flags.Args() {
+		ids[i], err = parseChirpID(arg)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, id := range ids {
+		err = c.client.DeleteChirp(context.Background(), id)
+		if err != nil {
+			return fmt.Errorf("deleting %s: %w", id, err)
+		}
+		c.notef("deleted %s", id)
+	}
+	return nil
+}
+
+func parseChirpID(s string) (uuid.UUID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%q isn't a chirp id", s)
+	}
+	return id, nil
+}
+
+// body returns the chirp body made of args, or read from stdin when args
+// are empty or "-".
+func (c *cli) body(args []string) (string, error) {
+	if len(args) > 0 && !(len(args) == 1 && args[0] == "-") {
+		return strings.Join(args, " "), nil
+	}
+	data, err := io.ReadAll(c.stdin)
+	if err != nil {
+		return "", fmt.Errorf("reading the chirp from stdin: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// password returns flagValue, or else CHIRPY_PASSWORD, or else the first
+// line of stdin, so that passwords needn't show up in the process list.
+func (c *cli) password(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if password := os.Getenv("CHIRPY_PASSWORD"); password != "" {
+		return password, nil
+	}
+	fmt.Fprint(c.stderr, "password: ")
+	line, err := bufio.NewReader(c.stdin).ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", fmt.Errorf("reading the password from stdin: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/jamistoso/chirpy/pkg/chirpyclient"
+)
+
+// cliConfig is what the config file holds: the session of the last login.
+type cliConfig struct {
+	Server string `json:"server,omitempty"`
+	// User is who logged in, as of the login or the last account change.
+	User *chirpyclient.User `json:"user,omitempty"`
+	chirpyclient.Tokens
+}
+
+func defaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("finding the config directory (set -config or CHIRPY_CLI_CONFIG): %w", err)
+	}
+	return filepath.Join(dir, "chirpy", "cli.json"), nil
+}
+
+// loadConfig reads the config file at path. A missing file is an empty
+// config.
+func loadConfig(path string) (cliConfig, error) {
+	var conf cliConfig
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return conf, nil
+	}
+	if err != nil {
+		return conf, err
+	}
+	err = json.Unmarshal(data, &conf)
+	if err != nil {
+		return conf, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return conf, nil
+}
+
+// saveConfig writes conf to path, readable only by the user since it holds
+// the session's tokens. The file is replaced at once so that a crash can't
+// leave half of it behind.
+func saveConfig(path string, conf cliConfig) error {
+	data, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
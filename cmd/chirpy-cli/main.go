@@ -0,0 +1,165 @@
+// Command chirpy-cli talks to a chirpy server over its HTTP API, for
+// scripting the service and poking at it by hand.
+//
+//	chirpy-cli -server https://chirpy.example.com login -email walt@example.com
+//	chirpy-cli post "Say my name."
+//	chirpy-cli -output json chirps -author me -all
+//
+// login keeps the server and the session's tokens in a config file, which
+// later commands read and keep up to date as access tokens are refreshed.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jamistoso/chirpy/pkg/chirpyclient"
+)
+
+const defaultServer = "http://localhost:8080"
+
+type command struct {
+	name    string
+	summary string
+	run     func(c *cli, args []string) error
+}
+
+var commands = []command{
+	{"signup", "create an account", runSignup},
+	{"login", "log in and save the session", runLogin},
+	{"logout", "revoke the saved session and forget it", runLogout},
+	{"session", "show or refresh the saved session", runSession},
+	{"account", "change the logged-in user's email and password", runAccount},
+	{"chirps", "list chirps, everyone's or an author's", runChirps},
+	{"chirp", "show one chirp", runChirp},
+	{"post", "post a chirp", runPost},
+	{"edit", "change the body of one of your chirps", runEdit},
+	{"delete", "delete some of your chirps", runDelete},
+}
+
+// cli is the state a command runs with.
+type cli struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+
+	configPath string
+	conf       cliConfig
+	// dirty is set when conf has changes that aren't saved yet.
+	dirty  bool
+	server string
+	output string
+	client *chirpyclient.Client
+}
+
+func main() {
+	c := &cli{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	err := c.run(os.Args[1:])
+	// On -h the flag package has already printed the command's usage.
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, "chirpy-cli:", err)
+		os.Exit(1)
+	}
+}
+
+func (c *cli) run(args []string) error {
+	global := c.newFlagSet("chirpy-cli")
+	global.Usage = c.printUsage
+	global.StringVar(&c.configPath, "config", os.Getenv("CHIRPY_CLI_CONFIG"), "")
+	server := global.String("server", os.Getenv("CHIRPY_SERVER"), "")
+	global.StringVar(&c.output, "output", "table", "")
+	err := global.Parse(args)
+	if err != nil {
+		return err
+	}
+	if c.output != "table" && c.output != "json" {
+		return fmt.Errorf("-output must be table or json, not %q", c.output)
+	}
+	args = global.Args()
+	if len(args) == 0 || args[0] == "help" {
+		c.printUsage()
+		return nil
+	}
+	name, args := args[0], args[1:]
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if c.configPath == "" {
+			c.configPath, err = defaultConfigPath()
+			if err != nil {
+				return err
+			}
+		}
+		c.conf, err = loadConfig(c.configPath)
+		if err != nil {
+			return err
+		}
+		c.server = *server
+		if c.server == "" {
+			c.server = c.conf.Server
+		}
+		if c.server == "" {
+			c.server = defaultServer
+		}
+		// A session saved for another server is no use with this one. It is
+		// only overwritten by logging in here.
+		if c.conf.Server != "" && c.conf.Server != c.server {
+			c.conf = cliConfig{}
+		}
+		c.client = chirpyclient.New(c.server,
+			chirpyclient.WithTokens(c.conf.Tokens),
+			chirpyclient.OnTokens(func(tokens chirpyclient.Tokens) {
+				c.conf.Tokens = tokens
+				c.dirty = true
+			}),
+		)
+
+		err = cmd.run(c, args)
+		// Refreshed tokens are worth keeping even if the command failed.
+		if c.dirty {
+			saveErr := saveConfig(c.configPath, c.conf)
+			if saveErr != nil {
+				return errors.Join(err, saveErr)
+			}
+		}
+		return err
+	}
+	c.printUsage()
+	return fmt.Errorf("unknown command %q", name)
+}
+
+func (c *cli) printUsage() {
+	fmt.Fprintln(c.stderr, "usage: chirpy-cli [-server url] [-config file] [-output table|json] command [flags]\n\ncommands:")
+	w := tabwriter.NewWriter(c.stderr, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	w.Flush()
+	fmt.Fprintln(c.stderr, "\nThe server comes from -server or CHIRPY_SERVER, then the one logged in to,")
+	fmt.Fprintf(c.stderr, "then %s. The session is saved in the file given with -config or\n", defaultServer)
+	fmt.Fprintln(c.stderr, "CHIRPY_CLI_CONFIG, by default chirpy/cli.json in the user's config directory.")
+	fmt.Fprintln(c.stderr, "Run chirpy-cli <command> -h for a command's flags.")
+}
+
+func (c *cli) newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	return flags
+}
+
+// parseFlags parses args, which must all be flags.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("%s: unexpected argument %q", flags.Name(), flags.Arg(0))
+	}
+	return nil
+}
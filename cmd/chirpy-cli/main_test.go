@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/pkg/chirpyclient"
+)
+
+var walt = chirpyclient.User{ID: uuid.New(), Email: "walt@example.com"}
+
+// fakeServer logs walt in with the password "heisenberg", handing out the
+// access token "expired" that it never accepts, and lists three chirps.
+func fakeServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	chirps := make([]chirpyclient.Chirp, 3)
+	for i := range chirps {
+		chirps[i] = chirpyclient.Chirp{ID: uuid.New(), Body: fmt.Sprintf("chirp %d", i), UserID: walt.ID}
+	}
+	problem := func(w http.ResponseWriter, status int, code string) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{"status": status, "code": code})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/login", func(w http.ResponseWriter, r *http.Request) {
+		var creds struct{ Email, Password string }
+		json.NewDecoder(r.Body).Decode(&creds)
+		if creds.Email != walt.Email || creds.Password != "heisenberg" {
+			problem(w, 401, chirpyclient.CodeInvalidCredentials)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"id": walt.ID, "email": walt.Email, "token": "expired", "refresh_token": "refresh",
+		})
+	})
+	mux.HandleFunc("POST /api/refresh", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer refresh" {
+			problem(w, 401, chirpyclient.CodeInvalidToken)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": "fresh"})
+	})
+	mux.HandleFunc("POST /api/revoke", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(204)
+	})
+	mux.HandleFunc("GET /api/chirps", func(w http.ResponseWriter, r *http.Request) {
+		start := 0
+		for i, chirp := range chirps {
+			if chirp.ID.String() == r.URL.Query().Get("cursor") {
+				start = i + 1
+			}
+		}
+		end := min(start+2, len(chirps))
+		if end < len(chirps) {
+			w.Header().Set("Link", fmt.Sprintf(`</api/chirps?limit=2&cursor=%s>; rel="next"`, chirps[end-1].ID))
+		}
+		json.NewEncoder(w).Encode(chirps[start:end])
+	})
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			problem(w, 401, chirpyclient.CodeInvalidToken)
+			return
+		}
+		w.WriteHeader(204)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// runCLI runs chirpy-cli with args and stdin, returning its stdout.
+func runCLI(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	c := &cli{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr}
+	err := c.run(args)
+	return stdout.String(), err
+}
+
+func TestSession(t *testing.T) {
+	server := fakeServer(t)
+	configPath := filepath.Join(t.TempDir(), "chirpy", "cli.json")
+	t.Setenv("CHIRPY_CLI_CONFIG", configPath)
+	t.Setenv("CHIRPY_SERVER", "")
+	t.Setenv("CHIRPY_PASSWORD", "")
+
+	_, err := runCLI(t, "wrong\n", "-server", server.URL, "login", "-email", walt.Email)
+	if chirpyclient.ErrorCode(err) != chirpyclient.CodeInvalidCredentials {
+		t.Fatalf(`login with the wrong password = %v, wanted %s`, err, chirpyclient.CodeInvalidCredentials)
+	}
+	out, err := runCLI(t, "heisenberg\n", "-server", server.URL, "login", "-email", walt.Email)
+	if err != nil || !strings.Contains(out, walt.ID.String()) {
+		t.Fatalf(`login = %q, %v`, out, err)
+	}
+	info, err := os.Stat(configPath)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf(`config file = %v, %v, wanted mode 0600`, info, err)
+	}
+	conf, err := loadConfig(configPath)
+	want := chirpyclient.Tokens{Access: "expired", Refresh: "refresh"}
+	if err != nil || conf.Server != server.URL || conf.Tokens != want || conf.User.ID != walt.ID {
+		t.Fatalf(`config after login = %+v, %v`, conf, err)
+	}
+
+	// The server is remembered, and so is the refreshed access token.
+	_, err = runCLI(t, "", "delete", uuid.NewString())
+	if err != nil {
+		t.Fatalf(`delete = %v`, err)
+	}
+	conf, _ = loadConfig(configPath)
+	if conf.Access != "fresh" {
+		t.Fatalf(`access token after delete = %q, wanted the refreshed one`, conf.Access)
+	}
+
+	_, err = runCLI(t, "", "logout")
+	if err != nil {
+		t.Fatalf(`logout = %v`, err)
+	}
+	conf, _ = loadConfig(configPath)
+	if conf.Tokens != (chirpyclient.Tokens{}) || conf.User != nil {
+		t.Fatalf(`config after logout = %+v, wanted no session`, conf)
+	}
+	_, err = runCLI(t, "", "logout")
+	if err == nil || err.Error() != "not logged in" {
+		t.Fatalf(`logout twice = %v, wanted "not logged in"`, err)
+	}
+}
+
+func TestChirps(t *testing.T) {
+	server := fakeServer(t)
+	t.Setenv("CHIRPY_CLI_CONFIG", filepath.Join(t.TempDir(), "cli.json"))
+	t.Setenv("CHIRPY_SERVER", server.URL)
+
+	out, err := runCLI(t, "", "chirps", "-limit", "2")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if err != nil || len(lines) != 3 || !strings.HasPrefix(lines[0], "ID ") || !strings.HasSuffix(lines[2], "chirp 1") {
+		t.Fatalf(`chirps -limit 2 = %q, %v, wanted a header and two chirps`, out, err)
+	}
+
+	out, err = runCLI(t, "", "-output", "json", "chirps", "-limit", "2")
+	var page chirpList
+	if err != nil || json.Unmarshal([]byte(out), &page) != nil || len(page.Chirps) != 2 || page.NextCursor == "" {
+		t.Fatalf(`-output json chirps -limit 2 = %q, %v, wanted two chirps and a cursor`, out, err)
+	}
+	out, err = runCLI(t, "", "-output", "json", "chirps", "-limit", "2", "-cursor", page.NextCursor)
+	var last chirpList
+	if err != nil || json.Unmarshal([]byte(out), &last) != nil || len(last.Chirps) != 1 || last.NextCursor != "" {
+		t.Fatalf(`chirps -cursor = %q, %v, wanted the last chirp`, out, err)
+	}
+
+	out, err = runCLI(t, "", "-output", "json", "chirps", "-limit", "2", "-all")
+	var all chirpList
+	if err != nil || json.Unmarshal([]byte(out), &all) != nil || len(all.Chirps) != 3 {
+		t.Fatalf(`chirps -all = %q, %v, wanted all three chirps`, out, err)
+	}
+
+	_, err = runCLI(t, "", "chirps", "-author", "me")
+	if err == nil || !strings.Contains(err.Error(), "needs a login") {
+		t.Fatalf(`chirps -author me logged out = %v`, err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jamistoso/chirpy/pkg/chirpyclient"
+)
+
+// print writes v as JSON in JSON mode, and header and rows as a table
+// otherwise.
+func (c *cli) print(v any, header []string, rows [][]string) error {
+	if c.output == "json" {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// notef writes a message for people to stderr, where it stays out of the
+// way of output piped elsewhere.
+func (c *cli) notef(format string, args ...any) {
+	fmt.Fprintf(c.stderr, format+"\n", args...)
+}
+
+func (c *cli) printUser(user chirpyclient.User) error {
+	return c.print(user,
+		[]string{"ID", "EMAIL", "CHIRPY RED", "CREATED"},
+		[][]string{{user.ID.String(), user.Email, fmt.Sprint(user.IsChirpyRed), formatTime(user.CreatedAt)}},
+	)
+}
+
+// chirpList is the JSON output of commands listing chirps.
+type chirpList struct {
+	Chirps     []chirpyclient.Chirp `json:"chirps"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+func (c *cli) printChirps(chirps []chirpyclient.Chirp, nextCursor string) error {
+	if chirps == nil {
+		chirps = []chirpyclient.Chirp{}
+	}
+	rows := make([][]string, len(chirps))
+	for i, chirp := range chirps {
+		rows[i] = chirpRow(chirp)
+	}
+	err := c.print(chirpList{Chirps: chirps, NextCursor: nextCursor}, chirpHeader, rows)
+	if err != nil {
+		return err
+	}
+	if nextCursor != "" && c.output != "json" {
+		c.notef("more chirps follow; continue with -cursor %s", nextCursor)
+	}
+	return nil
+}
+
+func (c *cli) printChirp(chirp chirpyclient.Chirp) error {
+	return c.print(chirp, chirpHeader, [][]string{chirpRow(chirp)})
+}
+
+var chirpHeader = []string{"ID", "CREATED", "AUTHOR", "BODY"}
+
+func chirpRow(chirp chirpyclient.Chirp) []string {
+	// Line breaks and tabs in the body would break up the table.
+	body := strings.Join(strings.Fields(chirp.Body), " ")
+	return []string{chirp.ID.String(), formatTime(chirp.CreatedAt), chirp.UserID.String(), body}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/audit"
+	"github.com/jamistoso/chirpy/internal/auth"
+	"github.com/jamistoso/chirpy/internal/config"
+	"github.com/jamistoso/chirpy/internal/database"
+)
+
+// runCreateAdmin implements `chirpy create-admin`. An existing account is
+// promoted and keeps its password unless -password is given; a new one gets
+// a generated password when -password isn't.
+func runCreateAdmin(conf config.Config, args []string) error {
+	flags := newFlagSet("create-admin")
+	email := flags.String("email", "", "`email` of the admin account (required)")
+	password := flags.String("password", "", "`password` to set; generated for new accounts when empty")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("create-admin: -email is required")
+	}
+
+	db, err := openDB(conf)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	dbQueries := database.New(db)
+	ctx := context.Background()
+
+	dbUser, err := dbQueries.GetUserFromEmail(ctx, *email)
+	created := false
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if *password == "" {
+			*password, err = auth.MakeRefreshToken()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("generated password: %s\n", *password)
+		}
+		hashedPassword, err := auth.HashPassword(*password)
+		if err != nil {
+			return err
+		}
+		dbUser, err = dbQueries.CreateUser(ctx, database.CreateUserParams{
+			Email:          *email,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			return fmt.Errorf("creating user: %w", err)
+		}
+		created = true
+	case err != nil:
+		return fmt.Errorf("looking up user: %w", err)
+	case *password != "":
+		hashedPassword, err := auth.HashPassword(*password)
+		if err != nil {
+			return err
+		}
+		dbUser, err = dbQueries.UpdatePasswordAndEmail(ctx, database.UpdatePasswordAndEmailParams{
+			HashedPassword: hashedPassword,
+			Email:          dbUser.Email,
+			ID:             dbUser.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("setting password: %w", err)
+		}
+	}
+
+	wasAdmin := dbUser.IsAdmin
+	dbUser, err = dbQueries.SetUserAdmin(ctx, database.SetUserAdminParams{
+		IsAdmin: true,
+		ID:      dbUser.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("promoting user: %w", err)
+	}
+	recordCLIEvent(ctx, dbQueries, audit.Event{
+		Action:     "user.create_admin",
+		TargetType: "user",
+		TargetID:   dbUser.ID.String(),
+		Before:     map[string]any{"is_admin": wasAdmin, "created": created},
+		After:      map[string]any{"is_admin": true},
+	})
+
+	if created {
+		fmt.Printf("created admin %s (%s)\n", dbUser.Email, dbUser.ID)
+	} else {
+		fmt.Printf("%s (%s) is an admin\n", dbUser.Email, dbUser.ID)
+	}
+	return nil
+}
+
+// runRevokeSessions implements `chirpy revoke-sessions`. Access tokens
+// already handed out stay valid until they expire, at most accessTokenTTL
+// later.
+func runRevokeSessions(conf config.Config, args []string) error {
+	flags := newFlagSet("revoke-sessions")
+	user := flags.String("user", "", "`id or email` of the user (required)")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if *user == "" {
+		return errors.New("revoke-sessions: -user is required")
+	}
+
+	db, err := openDB(conf)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	dbQueries := database.New(db)
+	ctx := context.Background()
+
+	var dbUser database.User
+	if id, parseErr := uuid.Parse(*user); parseErr == nil {
+		dbUser, err = dbQueries.GetUserFromID(ctx, id)
+	} else {
+		dbUser, err = dbQueries.GetUserFromEmail(ctx, *user)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no user %q", *user)
+	}
+	if err != nil {
+		return fmt.Errorf("looking up user: %w", err)
+	}
+
+	revoked, err := dbQueries.RevokeUserRefreshTokens(ctx, uuid.NullUUID{UUID: dbUser.ID, Valid: true})
+	if err != nil {
+		return fmt.Errorf("revoking refresh tokens: %w", err)
+	}
+	recordCLIEvent(ctx, dbQueries, audit.Event{
+		Action:     "user.revoke_sessions",
+		TargetType: "user",
+		TargetID:   dbUser.ID.String(),
+		After:      map[string]any{"revoked_tokens": revoked},
+	})
+
+	fmt.Printf("revoked %d refresh tokens of %s; access tokens expire within %s\n", revoked, dbUser.Email, accessTokenTTL)
+	return nil
+}
+
+func recordCLIEvent(ctx context.Context, dbQueries *database.Queries, e audit.Event) {
+	err := audit.New(dbQueries, nil).RecordSystem(ctx, e)
+	if err != nil {
+		slog.Error("error writing audit log entry", "action", e.Action, "err", err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jamistoso/chirpy/internal/auth"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/entitlements"
+)
+
+// planFor returns the entitlements plan dbUser is on: their subscription's
+// plan while they have Chirpy Red, the free plan otherwise. Red users on a
+// plan the config doesn't know about get the standard red limits.
+func (cfg *apiConfig) planFor(ctx context.Context, dbUser database.User) string {
+	if !dbUser.IsChirpyRed.Bool {
+		return entitlements.PlanFree
+	}
+	sub, err := cfg.dbQueries.GetSubscriptionFromUser(ctx, dbUser.ID)
+	if err == nil && cfg.entitlements.Has(sub.Plan) {
+		return sub.Plan
+	}
+	return entitlements.PlanRed
+}
+
+func (cfg *apiConfig) limitsFor(ctx context.Context, dbUser database.User) entitlements.Limits {
+	return cfg.entitlements.For(cfg.planFor(ctx, dbUser))
+}
+
+// rateLimitMultiplier scales rate limits by the caller's plan. Anonymous
+// callers and invalid tokens get the base policy; the handler rejects them.
+func (cfg *apiConfig) rateLimitMultiplier(rq *http.Request) float64 {
+	token, err := auth.GetBearerToken(rq.Header)
+	if err != nil {
+		return 1
+	}
+	userID, err := cfg.jwtKeys.ValidateJWT(token)
+	if err != nil {
+		return 1
+	}
+	dbUser, err := cfg.users.GetUserFromID(rq.Context(), userID)
+	if err != nil {
+		return 1
+	}
+	return cfg.limitsFor(rq.Context(), dbUser).RateLimitMultiplier
+}
+
+type entitlementsResponse struct {
+	Plan string `json:"plan"`
+	entitlements.Limits
+}
+
+// entitlementsHandler tells the caller which plan they are on and what it
+// allows, so clients can e.g. size the compose box.
+func (cfg *apiConfig) entitlementsHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	dbUser, ok := cfg.authenticate(rWriter, rq)
+	if !ok {
+		return
+	}
+
+	plan := cfg.planFor(rq.Context(), dbUser)
+
+	respondWithJSON(rWriter, 200, entitlementsResponse{
+		Plan:   plan,
+		Limits: cfg.entitlements.For(plan),
+	})
+}
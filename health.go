@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/health"
+	"github.com/jamistoso/chirpy/internal/migrate"
+)
+
+const (
+	// healthCacheTTL is how long a readiness report is served before the
+	// checks run again.
+	healthCacheTTL = 5 * time.Second
+	healthTimeout  = 2 * time.Second
+
+	// maxJobQueueLag is how long the oldest due job may wait before the
+	// instance reports itself degraded.
+	maxJobQueueLag = 5 * time.Minute
+)
+
+// newHealthChecker registers what an instance needs to serve: a database
+// that answers and the schema this build expects. It also watches that the
+// job queue keeps up, but the queue is shared by every instance, so a
+// backlog only degrades the report: taking instances out of rotation
+// wouldn't clear it.
+func newHealthChecker(db *sql.DB, migrator *migrate.Migrator, dbQueries *database.Queries) *health.Checker {
+	checker := health.New(healthCacheTTL)
+	checker.Register("database", healthTimeout, db.PingContext)
+	checker.Register("migrations", healthTimeout, func(ctx context.Context) error {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migrations, starting with %s", len(pending), pending[0].Name)
+		}
+		return nil
+	})
+	checker.RegisterInformational("job_queue", healthTimeout, func(ctx context.Context) error {
+		now := time.Now()
+		oldest, err := dbQueries.OldestDueJob(ctx, now)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		lag := now.Sub(oldest)
+		if lag > maxJobQueueLag {
+			return fmt.Errorf("oldest due job has waited %s", lag.Round(time.Second))
+		}
+		return nil
+	})
+	return checker
+}
+
+// healthzHandler is the health check from before livez and readyz, which
+// load balancers may still be configured to expect: a plain 200 OK whenever
+// the process is serving.
+func healthzHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	rWriter.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	rWriter.WriteHeader(200)
+	rWriter.Write([]byte("OK"))
+}
+
+// livezHandler reports that the process is up and serving. It checks nothing
+// else, so that a database outage doesn't get every instance restarted.
+func livezHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	respondWithJSON(rWriter, 200, map[string]string{"status": health.StatusOK})
+}
+
+// readyHandler reports whether this instance should get traffic, with the
+// result of each check. It stops being ready as soon as a shutdown starts,
+// while requests are still served.
+func (cfg *apiConfig) readyHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	if !cfg.ready.Load() {
+		respondWithJSON(rWriter, 503, health.Report{
+			Status:    "shutting_down",
+			CheckedAt: time.Now(),
+			Checks:    map[string]health.Result{},
+		})
+		return
+	}
+	report := cfg.health.Run(rq.Context())
+	if !report.Healthy() {
+		respondWithJSON(rWriter, 503, report)
+		return
+	}
+	respondWithJSON(rWriter, 200, report)
+}
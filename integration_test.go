@@ -0,0 +1,748 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/auth"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/moderation"
+	"github.com/jamistoso/chirpy/internal/store/memory"
+)
+
+// These tests drive chirpy's handler over HTTP, the way clients see it.
+
+type testUser struct {
+	ID           uuid.UUID `json:"id"`
+	Email        string    `json:"email"`
+	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+}
+
+type testChirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+
+// signUp creates a user and logs them in.
+func signUp(t *testing.T, server *httptest.Server, email string) testUser {
+	t.Helper()
+	creds := map[string]string{"email": email, "password": "hunter2"}
+	status, code := call(t, server, "POST", "/api/users", "", creds, nil)
+	if status != 201 {
+		t.Fatalf(`POST /api/users for %s = %d %s, wanted 201`, email, status, code)
+	}
+	var user testUser
+	status, code = call(t, server, "POST", "/api/login", "", creds, &user)
+	if status != 200 {
+		t.Fatalf(`POST /api/login for %s = %d %s, wanted 200`, email, status, code)
+	}
+	return user
+}
+
+func postChirp(t *testing.T, server *httptest.Server, user testUser, body string) testChirp {
+	t.Helper()
+	var chirp testChirp
+	status, code := call(t, server, "POST", "/api/chirps", user.Token, map[string]string{"body": body}, &chirp)
+	if status != 201 {
+		t.Fatalf(`POST /api/chirps %q = %d %s, wanted 201`, body, status, code)
+	}
+	return chirp
+}
+
+func TestSignupAndLogin(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	var created testUser
+	status, _ := call(t, server, "POST", "/api/users", "",
+		map[string]string{"email": "walt@example.com", "password": "heisenberg"}, &created)
+	if status != 201 || created.Email != "walt@example.com" || created.ID == uuid.Nil || created.IsChirpyRed {
+		t.Fatalf(`POST /api/users = %d %+v, wanted 201 and a free user`, status, created)
+	}
+	status, code := call(t, server, "POST", "/api/users", "",
+		map[string]string{"email": "walt", "password": "heisenberg"}, nil)
+	if status != 400 || code != "validation_failed" {
+		t.Fatalf(`POST /api/users with a bad email = %d %s, wanted 400 validation_failed`, status, code)
+	}
+
+	status, code = call(t, server, "POST", "/api/login", "",
+		map[string]string{"email": "walt@example.com", "password": "wrong"}, nil)
+	if status != 401 || code != "invalid_credentials" {
+		t.Fatalf(`POST /api/login with the wrong password = %d %s, wanted 401 invalid_credentials`, status, code)
+	}
+	status, code = call(t, server, "POST", "/api/login", "",
+		map[string]string{"email": "jesse@example.com", "password": "heisenberg"}, nil)
+	if status != 401 || code != "invalid_credentials" {
+		t.Fatalf(`POST /api/login for an unknown email = %d %s, wanted 401 invalid_credentials`, status, code)
+	}
+	var user testUser
+	status, _ = call(t, server, "POST", "/api/login", "",
+		map[string]string{"email": "walt@example.com", "password": "heisenberg"}, &user)
+	if status != 200 || user.ID != created.ID || user.Token == "" || user.RefreshToken == "" {
+		t.Fatalf(`POST /api/login = %d %+v, wanted 200 and tokens`, status, user)
+	}
+
+	var updated testUser
+	status, _ = call(t, server, "PUT", "/api/users", user.Token,
+		map[string]string{"email": "heisenberg@example.com", "password": "blue sky"}, &updated)
+	if status != 200 || updated.Email != "heisenberg@example.com" {
+		t.Fatalf(`PUT /api/users = %d %+v, wanted 200 and the new email`, status, updated)
+	}
+	status, _ = call(t, server, "POST", "/api/login", "",
+		map[string]string{"email": "heisenberg@example.com", "password": "blue sky"}, nil)
+	if status != 200 {
+		t.Fatalf(`POST /api/login with the new credentials = %d, wanted 200`, status)
+	}
+}
+
+func TestRefreshAndRevoke(t *testing.T) {
+	server, _ := newTestServer(t)
+	user := signUp(t, server, "walt@example.com")
+
+	var refreshed struct {
+		Token string `json:"token"`
+	}
+	status, code := call(t, server, "POST", "/api/refresh", user.RefreshToken, nil, &refreshed)
+	if status != 200 || refreshed.Token == "" {
+		t.Fatalf(`POST /api/refresh = %d %s, wanted 200 and a token`, status, code)
+	}
+	status, code = call(t, server, "POST", "/api/chirps", refreshed.Token, map[string]string{"body": "fresh"}, nil)
+	if status != 201 {
+		t.Fatalf(`POST /api/chirps with the refreshed token = %d %s, wanted 201`, status, code)
+	}
+	status, code = call(t, server, "POST", "/api/refresh", user.Token, nil, nil)
+	if status != 401 || code != "invalid_token" {
+		t.Fatalf(`POST /api/refresh with an access token = %d %s, wanted 401 invalid_token`, status, code)
+	}
+	status, code = call(t, server, "POST", "/api/refresh", "", nil, nil)
+	if status != 401 || code != "unauthorized" {
+		t.Fatalf(`POST /api/refresh without a token = %d %s, wanted 401 unauthorized`, status, code)
+	}
+
+	status, code = call(t, server, "POST", "/api/revoke", user.RefreshToken, nil, nil)
+	if status != 204 {
+		t.Fatalf(`POST /api/revoke = %d %s, wanted 204`, status, code)
+	}
+	status, code = call(t, server, "POST", "/api/refresh", user.RefreshToken, nil, nil)
+	if status != 401 || code != "invalid_token" {
+		t.Fatalf(`POST /api/refresh after revoking = %d %s, wanted 401 invalid_token`, status, code)
+	}
+	// Access tokens already handed out last until they expire.
+	status, code = call(t, server, "POST", "/api/chirps", user.Token, map[string]string{"body": "still here"}, nil)
+	if status != 201 {
+		t.Fatalf(`POST /api/chirps after revoking = %d %s, wanted 201`, status, code)
+	}
+}
+
+func TestChirpCRUD(t *testing.T) {
+	server, _ := newTestServer(t)
+	walt := signUp(t, server, "walt@example.com")
+
+	chirp := postChirp(t, server, walt, "What a kerfuffle")
+	if chirp.Body != "What a ****" || chirp.UserID != walt.ID {
+		t.Fatalf(`POST /api/chirps = %+v, wanted walt's chirp with the profanity hidden`, chirp)
+	}
+	var got testChirp
+	status, _ := call(t, server, "GET", "/api/chirps/"+chirp.ID.String(), "", nil, &got)
+	if status != 200 || got != chirp {
+		t.Fatalf(`GET /api/chirps/{id} = %d %+v, wanted %+v`, status, got, chirp)
+	}
+	status, code := call(t, server, "GET", "/api/chirps/not-a-uuid", "", nil, nil)
+	if status != 400 || code != "invalid_parameter" {
+		t.Fatalf(`GET /api/chirps/not-a-uuid = %d %s, wanted 400 invalid_parameter`, status, code)
+	}
+	status, code = call(t, server, "POST", "/api/chirps", walt.Token,
+		map[string]string{"body": strings.Repeat("a", 141)}, nil)
+	if status != 400 || code != "validation_failed" {
+		t.Fatalf(`POST /api/chirps with a long body = %d %s, wanted 400 validation_failed`, status, code)
+	}
+
+	// Editing needs Chirpy Red.
+	status, code = call(t, server, "PUT", "/api/chirps/"+chirp.ID.String(), walt.Token,
+		map[string]string{"body": "What a mess"}, nil)
+	if status != 403 || code != "plan_required" {
+		t.Fatalf(`PUT /api/chirps/{id} on the free plan = %d %s, wanted 403 plan_required`, status, code)
+	}
+	sendPolkaEvent(t, server, "evt_1", "user.upgraded", walt.ID)
+	var edited testChirp
+	status, code = call(t, server, "PUT", "/api/chirps/"+chirp.ID.String(), walt.Token,
+		map[string]string{"body": "What a mess"}, &edited)
+	if status != 200 || edited.ID != chirp.ID || edited.Body != "What a mess" {
+		t.Fatalf(`PUT /api/chirps/{id} on Chirpy Red = %d %s %+v, wanted 200 and the new body`, status, code, edited)
+	}
+
+	status, code = call(t, server, "DELETE", "/api/chirps/"+chirp.ID.String(), walt.Token, nil, nil)
+	if status != 204 {
+		t.Fatalf(`DELETE /api/chirps/{id} = %d %s, wanted 204`, status, code)
+	}
+	status, code = call(t, server, "GET", "/api/chirps/"+chirp.ID.String(), "", nil, nil)
+	if status != 404 || code != "not_found" {
+		t.Fatalf(`GET /api/chirps/{id} after deleting = %d %s, wanted 404 not_found`, status, code)
+	}
+	status, code = call(t, server, "DELETE", "/api/chirps/"+chirp.ID.String(), walt.Token, nil, nil)
+	if status != 404 || code != "not_found" {
+		t.Fatalf(`DELETE /api/chirps/{id} twice = %d %s, wanted 404 not_found`, status, code)
+	}
+}
+
+func TestAuthorizationFailures(t *testing.T) {
+	server, cfg := newTestServer(t)
+	walt := signUp(t, server, "walt@example.com")
+	jesse := signUp(t, server, "jesse@example.com")
+	chirp := postChirp(t, server, walt, "I am the one who knocks.")
+	chirpPath := "/api/chirps/" + chirp.ID.String()
+
+	expired, err := cfg.jwtKeys.MakeJWT(walt.ID, -time.Minute)
+	if err != nil {
+		t.Fatalf(`MakeJWT() = %v`, err)
+	}
+	forged, err := auth.NewKeyring("another-secret-another-secret-another").MakeJWT(walt.ID, time.Hour)
+	if err != nil {
+		t.Fatalf(`MakeJWT() = %v`, err)
+	}
+	tests := []struct {
+		name         string
+		method, path string
+		token        string
+		body         any
+		status       int
+		code         string
+	}{
+		{"post without a token", "POST", "/api/chirps", "", map[string]string{"body": "hi"}, 401, "unauthorized"},
+		{"post with an expired token", "POST", "/api/chirps", expired, map[string]string{"body": "hi"}, 401, "invalid_token"},
+		{"post with a forged token", "POST", "/api/chirps", forged, map[string]string{"body": "hi"}, 401, "invalid_token"},
+		{"post with a refresh token", "POST", "/api/chirps", walt.RefreshToken, map[string]string{"body": "hi"}, 401, "invalid_token"},
+		{"post a bad body without a token", "POST", "/api/chirps", "", map[string]int{"bogus": 1}, 401, "unauthorized"},
+		{"delete without a token", "DELETE", chirpPath, "", nil, 401, "unauthorized"},
+		{"delete someone else's chirp", "DELETE", chirpPath, jesse.Token, nil, 403, "forbidden"},
+		{"edit someone else's chirp", "PUT", chirpPath, jesse.Token, map[string]string{"body": "yo"}, 403, "forbidden"},
+		{"update a user without a token", "PUT", "/api/users", "", map[string]string{"email": "a@example.com", "password": "x"}, 401, "unauthorized"},
+		{"revoke with an access token", "POST", "/api/revoke", walt.Token, nil, 401, "invalid_token"},
+		{"read the audit log logged out", "GET", "/admin/audit", "", nil, 401, "unauthorized"},
+		{"read the audit log as a user", "GET", "/admin/audit", walt.Token, nil, 403, "forbidden"},
+		{"suspend a user as a user", "PUT", "/admin/users/" + jesse.ID.String() + "/state", walt.Token, map[string]string{"state": "suspended"}, 403, "forbidden"},
+	}
+	for _, test := range tests {
+		status, code := call(t, server, test.method, test.path, test.token, test.body, nil)
+		if status != test.status || code != test.code {
+			t.Errorf(`%s: %s %s = %d %s, wanted %d %s`, test.name, test.method, test.path, status, code, test.status, test.code)
+		}
+	}
+
+	status, _ := call(t, server, "GET", chirpPath, "", nil, nil)
+	if status != 200 {
+		t.Fatalf(`GET /api/chirps/{id} after the failed deletes = %d, wanted 200`, status)
+	}
+}
+
+func TestChirpSorting(t *testing.T) {
+	server, _ := newTestServer(t)
+	walt := signUp(t, server, "walt@example.com")
+	jesse := signUp(t, server, "jesse@example.com")
+	// The bodies differ enough that none is held as a duplicate.
+	bodies := []string{"Say my name.", "Yeah, science!", "Tread lightly.", "Yo, Mr. White!"}
+	var posted []uuid.UUID
+	for i, body := range bodies {
+		author := walt
+		if i%2 == 1 {
+			author = jesse
+		}
+		posted = append(posted, postChirp(t, server, author, body).ID)
+	}
+
+	ids := func(path string) []uuid.UUID {
+		t.Helper()
+		var chirps []testChirp
+		status, code := call(t, server, "GET", path, "", nil, &chirps)
+		if status != 200 {
+			t.Fatalf(`GET %s = %d %s, wanted 200`, path, status, code)
+		}
+		var ids []uuid.UUID
+		for _, chirp := range chirps {
+			ids = append(ids, chirp.ID)
+		}
+		return ids
+	}
+	reversed := slices.Clone(posted)
+	slices.Reverse(reversed)
+	tests := []struct {
+		path string
+		want []uuid.UUID
+	}{
+		{"/api/chirps", posted},
+		{"/api/chirps?sort=asc", posted},
+		{"/api/chirps?sort=desc", reversed},
+		{"/api/chirps?author_id=" + walt.ID.String(), []uuid.UUID{posted[0], posted[2]}},
+		{"/api/chirps?author_id=" + jesse.ID.String() + "&sort=desc", []uuid.UUID{posted[3], posted[1]}},
+		{"/api/chirps?author_id=" + uuid.NewString(), nil},
+	}
+	for _, test := range tests {
+		got := ids(test.path)
+		if !slices.Equal(got, test.want) {
+			t.Errorf(`GET %s = %v, wanted %v`, test.path, got, test.want)
+		}
+	}
+
+	status, code := call(t, server, "GET", "/api/chirps?author_id=walt", "", nil, nil)
+	if status != 400 || code != "invalid_parameter" {
+		t.Fatalf(`GET /api/chirps?author_id=walt = %d %s, wanted 400 invalid_parameter`, status, code)
+	}
+}
+
+func TestChirpPagination(t *testing.T) {
+	server, _ := newTestServer(t)
+	walt := signUp(t, server, "walt@example.com")
+	bodies := []string{"Say my name.", "Tread lightly.", "I am the one who knocks.", "Stay out of my territory.", "I won."}
+	var posted []testChirp
+	for _, body := range bodies {
+		posted = append(posted, postChirp(t, server, walt, body))
+	}
+
+	// page returns the chirps at path and the path of the next page.
+	page := func(path string) ([]uuid.UUID, string) {
+		t.Helper()
+		rq, _ := http.NewRequest("GET", server.URL+path, nil)
+		rq.Header.Set("Authorization", "Bearer "+walt.Token)
+		resp, err := server.Client().Do(rq)
+		if err != nil || resp.StatusCode != 200 {
+			t.Fatalf(`GET %s = %v, %v, wanted 200`, path, resp, err)
+		}
+		defer resp.Body.Close()
+		var chirps []testChirp
+		json.NewDecoder(resp.Body).Decode(&chirps)
+		var ids []uuid.UUID
+		for _, chirp := range chirps {
+			ids = append(ids, chirp.ID)
+		}
+		next, _, _ := strings.Cut(strings.TrimPrefix(resp.Header.Get("Link"), "<"), ">")
+		return ids, next
+	}
+
+	first, next := page("/api/chirps?limit=2")
+	if !slices.Equal(first, []uuid.UUID{posted[0].ID, posted[1].ID}) || next == "" {
+		t.Fatalf(`first page = %v, next %q, wanted the first two chirps and a next page`, first, next)
+	}
+	// The chirp the cursor continues after is gone by the time it is used.
+	status, code := call(t, server, "DELETE", "/api/chirps/"+posted[1].ID.String(), walt.Token, nil, nil)
+	if status != 204 {
+		t.Fatalf(`DELETE /api/chirps/{id} = %d %s, wanted 204`, status, code)
+	}
+	second, next := page(next)
+	if !slices.Equal(second, []uuid.UUID{posted[2].ID, posted[3].ID}) || next == "" {
+		t.Fatalf(`second page = %v, next %q, wanted chirps three and four and a next page`, second, next)
+	}
+	last, next := page(next)
+	if !slices.Equal(last, []uuid.UUID{posted[4].ID}) || next != "" {
+		t.Fatalf(`last page = %v, next %q, wanted the fifth chirp alone`, last, next)
+	}
+
+	newest, next := page("/api/chirps?sort=desc&limit=3")
+	older, _ := page(next)
+	if !slices.Equal(newest, []uuid.UUID{posted[4].ID, posted[3].ID, posted[2].ID}) ||
+		!slices.Equal(older, []uuid.UUID{posted[0].ID}) {
+		t.Fatalf(`sort=desc pages = %v, %v, wanted the chirps newest first`, newest, older)
+	}
+
+	status, code = call(t, server, "GET", "/api/chirps?limit=2&cursor="+posted[0].ID.String(), "", nil, nil)
+	if status != 400 || code != "invalid_parameter" {
+		t.Fatalf(`GET /api/chirps with a chirp id as cursor = %d %s, wanted 400 invalid_parameter`, status, code)
+	}
+}
+
+// sendPolkaEvent delivers a signed Polka event about userID, failing the
+// test unless it is accepted.
+func sendPolkaEvent(t *testing.T, server *httptest.Server, id, event string, userID uuid.UUID) {
+	t.Helper()
+	status := postPolkaEvent(t, server, id, event, userID, testPolkaKey)
+	if status != 204 {
+		t.Fatalf(`POST /api/polka/webhooks %s = %d, wanted 204`, event, status)
+	}
+}
+
+func postPolkaEvent(t *testing.T, server *httptest.Server, id, event string, userID uuid.UUID, key string) int {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{
+		"id":    id,
+		"event": event,
+		"data":  map[string]string{"user_id": userID.String()},
+	})
+	now := time.Now()
+	rq, _ := http.NewRequest("POST", server.URL+"/api/polka/webhooks", bytes.NewReader(body))
+	rq.Header.Set("Content-Type", "application/json")
+	rq.Header.Set("Polka-Timestamp", fmt.Sprint(now.Unix()))
+	rq.Header.Set("Polka-Signature", auth.SignWebhook(body, key, now))
+	resp, err := server.Client().Do(rq)
+	if err != nil {
+		t.Fatalf(`POST /api/polka/webhooks: %v`, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestPolkaWebhooks(t *testing.T) {
+	server, cfg := newTestServer(t)
+	walt := signUp(t, server, "walt@example.com")
+	type subscription struct {
+		Status      string            `json:"status"`
+		IsChirpyRed bool              `json:"is_chirpy_red"`
+		History     []json.RawMessage `json:"history"`
+	}
+	getSubscription := func() subscription {
+		t.Helper()
+		var sub subscription
+		status, code := call(t, server, "GET", "/api/subscription", walt.Token, nil, &sub)
+		if status != 200 && status != 404 {
+			t.Fatalf(`GET /api/subscription = %d %s, wanted 200 or 404`, status, code)
+		}
+		return sub
+	}
+	isRed := func() bool {
+		t.Helper()
+		return getSubscription().IsChirpyRed
+	}
+
+	status := postPolkaEvent(t, server, "evt_1", "user.upgraded", walt.ID, "wrong-key")
+	if status != 401 || isRed() {
+		t.Fatalf(`POST /api/polka/webhooks signed with the wrong key = %d, wanted 401 and no upgrade`, status)
+	}
+	status = postPolkaEvent(t, server, "evt_2", "user.upgraded", uuid.New(), testPolkaKey)
+	if status != 404 {
+		t.Fatalf(`POST /api/polka/webhooks for an unknown user = %d, wanted 404`, status)
+	}
+	sendPolkaEvent(t, server, "evt_3", "user.signed_up", walt.ID)
+	if isRed() {
+		t.Fatalf(`an event chirpy doesn't handle upgraded the user`)
+	}
+
+	// A renewal of a subscription that was never started can't be applied;
+	// it is acknowledged, so Polka doesn't retry it, and kept as ignored.
+	sendPolkaEvent(t, server, "evt_renewed", "subscription.renewed", walt.ID)
+	var reason sql.NullString
+	err := cfg.db.QueryRow(`SELECT ignored_reason FROM polka_events WHERE id = $1`, "evt_renewed").Scan(&reason)
+	if err != nil || !reason.Valid || isRed() {
+		t.Fatalf(`subscription.renewed without a subscription recorded %v, %v, wanted an ignored reason`, reason, err)
+	}
+	sendPolkaEvent(t, server, "evt_renewed", "subscription.renewed", walt.ID)
+
+	sendPolkaEvent(t, server, "evt_4", "user.upgraded", walt.ID)
+	if !isRed() {
+		t.Fatalf(`user.upgraded didn't upgrade the user`)
+	}
+	var user testUser
+	call(t, server, "POST", "/api/login", "", map[string]string{"email": "walt@example.com", "password": "hunter2"}, &user)
+	if !user.IsChirpyRed {
+		t.Fatalf(`POST /api/login after upgrading = %+v, wanted is_chirpy_red`, user)
+	}
+
+	// A redelivered event is acknowledged without being applied again.
+	sendPolkaEvent(t, server, "evt_4", "user.upgraded", walt.ID)
+	if sub := getSubscription(); len(sub.History) != 1 {
+		t.Fatalf(`subscription history after a redelivery = %d events, wanted 1`, len(sub.History))
+	}
+
+	// Downgrading cancels at the end of the paid period.
+	sendPolkaEvent(t, server, "evt_5", "user.downgraded", walt.ID)
+	if sub := getSubscription(); sub.Status != "canceled" || !sub.IsChirpyRed || len(sub.History) != 2 {
+		t.Fatalf(`subscription after user.downgraded = %+v, wanted canceled but still red`, sub)
+	}
+}
+
+func TestWebhookFanOutIsIdempotent(t *testing.T) {
+	server, cfg := newTestServer(t)
+	walt := signUp(t, server, "walt@example.com")
+	var endpoint struct {
+		ID uuid.UUID `json:"id"`
+	}
+	hook := map[string]any{"url": "https://hooks.example.com/chirpy", "event_types": []string{"chirp.created"}}
+	status, code := call(t, server, "POST", "/api/webhooks", walt.Token, hook, &endpoint)
+	if status != 201 {
+		t.Fatalf(`POST /api/webhooks = %d %s, wanted 201`, status, code)
+	}
+
+	// The job fanning an event out may run again after it has succeeded,
+	// if its runner died before marking it done.
+	ev := webhookEventJob{EventID: uuid.New(), UserID: walt.ID, EventType: "chirp.created", Data: json.RawMessage(`{}`)}
+	for range 2 {
+		err := cfg.fanOutWebhook(context.Background(), ev)
+		if err != nil {
+			t.Fatalf(`fanOutWebhook() = %v, wanted nil`, err)
+		}
+	}
+
+	var deliveries []struct {
+		Payload struct {
+			ID uuid.UUID `json:"id"`
+		} `json:"payload"`
+	}
+	call(t, server, "GET", "/api/webhooks/"+endpoint.ID.String()+"/deliveries", walt.Token, nil, &deliveries)
+	if len(deliveries) != 1 || deliveries[0].Payload.ID != ev.EventID {
+		t.Fatalf(`deliveries after fanning out an event twice = %+v, wanted one of event %s`, deliveries, ev.EventID)
+	}
+
+	// An event without an ID couldn't be told apart from its retries.
+	ev.EventID = uuid.Nil
+	if err := cfg.fanOutWebhook(context.Background(), ev); err == nil {
+		t.Fatalf(`fanOutWebhook(no event id) = nil, wanted an error`)
+	}
+}
+
+// TestMemoryStore serves users, chirps and refresh tokens from memory.Store,
+// which the store conformance suite holds to the database's behaviour.
+func TestMemoryStore(t *testing.T) {
+	server, cfg := newTestServer(t)
+	mem := memory.New()
+	cfg.users, cfg.chirps, cfg.tokens, cfg.transactor = mem, mem, mem, mem
+
+	walt := signUp(t, server, "walt@example.com")
+	if _, err := mem.GetUserFromID(context.Background(), walt.ID); err != nil {
+		t.Fatalf(`GetUserFromID(%s) = %v, wanted the user in memory`, walt.ID, err)
+	}
+	chirp := postChirp(t, server, walt, "kept in memory")
+	var chirps []testChirp
+	call(t, server, "GET", "/api/chirps", "", nil, &chirps)
+	if len(chirps) != 1 || chirps[0].ID != chirp.ID {
+		t.Fatalf(`GET /api/chirps = %+v, wanted the chirp just posted`, chirps)
+	}
+	status, code := call(t, server, "DELETE", "/api/chirps/"+chirp.ID.String(), walt.Token, nil, nil)
+	if status != 204 {
+		t.Fatalf(`DELETE /api/chirps/%s = %d %s, wanted 204`, chirp.ID, status, code)
+	}
+
+	// Creating and deleting the chirp each queued a webhook event with it.
+	if jobs := mem.Jobs(); len(jobs) != 2 || jobs[0].Kind != string(jobWebhookEvent) {
+		t.Fatalf(`queued jobs = %+v, wanted two webhook events`, jobs)
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	resp, err := server.Client().Get(server.URL + "/api/healthz")
+	if err != nil {
+		t.Fatalf(`GET /api/healthz: %v`, err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || string(body) != "OK" || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf(`GET /api/healthz = %d %q, wanted a plain 200 OK`, resp.StatusCode, body)
+	}
+
+	var report struct {
+		Status string `json:"status"`
+		Checks map[string]struct {
+			Status string `json:"status"`
+		} `json:"checks"`
+	}
+	status, code := call(t, server, "GET", "/api/readyz", "", nil, &report)
+	if status != 200 || report.Status != "ok" || len(report.Checks) != 3 {
+		t.Fatalf(`GET /api/readyz = %d %s %+v, wanted 200 with three passing checks`, status, code, report)
+	}
+}
+
+func TestMetricsAreServedApart(t *testing.T) {
+	server, cfg := newTestServer(t)
+	status, _ := call(t, server, "GET", "/metrics", "", nil, nil)
+	if status != 404 {
+		t.Fatalf(`GET /metrics on the API = %d, wanted 404`, status)
+	}
+
+	rec := httptest.NewRecorder()
+	cfg.metricsRoutes().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), "chirpy_signups_total") {
+		t.Fatalf(`GET /metrics on the metrics listener = %d %q, wanted the metrics`, rec.Code, rec.Body)
+	}
+}
+
+// holdEverything is a moderation scorer that holds every chirp for review.
+type holdEverything struct{}
+
+func (holdEverything) Score(ctx context.Context, sub moderation.Submission) moderation.Signal {
+	return moderation.Signal{Rule: "test", Score: moderation.DefaultThresholds.Hold, Reason: "held for review"}
+}
+
+// webhookEvents returns the type and chirp of each webhook event queued so
+// far, in order.
+func webhookEvents(t *testing.T, cfg *apiConfig) []string {
+	t.Helper()
+	rows, err := cfg.db.Query(`SELECT payload FROM jobs WHERE kind = $1 ORDER BY created_at`, string(jobWebhookEvent))
+	if err != nil {
+		t.Fatalf(`querying webhook event jobs: %v`, err)
+	}
+	defer rows.Close()
+	var events []string
+	for rows.Next() {
+		var payload []byte
+		var ev webhookEventJob
+		var data chirpEventData
+		if err := rows.Scan(&payload); err != nil {
+			t.Fatalf(`scanning webhook event job: %v`, err)
+		}
+		json.Unmarshal(payload, &ev)
+		json.Unmarshal(ev.Data, &data)
+		events = append(events, ev.EventType+" "+data.ID.String())
+	}
+	return events
+}
+
+func TestModerationEmitsWebhooks(t *testing.T) {
+	for _, test := range []struct {
+		resolution string
+		event      string
+		status     int
+	}{
+		{"approve", "chirp.created", 200},
+		{"remove", "chirp.deleted", 404},
+	} {
+		t.Run(test.resolution, func(t *testing.T) {
+			server, cfg := newTestServer(t)
+			cfg.moderator = &moderation.Pipeline{Scorers: []moderation.Scorer{holdEverything{}}, Thresholds: moderation.DefaultThresholds}
+			walt := signUp(t, server, "walt@example.com")
+			gus := newAdmin(t, server, cfg, "gus@example.com")
+
+			chirp := postChirp(t, server, walt, "Say my name.")
+			if events := webhookEvents(t, cfg); len(events) != 0 {
+				t.Fatalf(`webhook events for a held chirp = %q, wanted none`, events)
+			}
+
+			var queue []moderationDecisionResponse
+			call(t, server, "GET", "/admin/moderation", gus.Token, nil, &queue)
+			if len(queue) != 1 {
+				t.Fatalf(`GET /admin/moderation = %+v, wanted the held chirp`, queue)
+			}
+			status, code := call(t, server, "POST", "/admin/moderation/"+queue[0].ID.String(), gus.Token, map[string]string{"resolution": test.resolution}, nil)
+			if status != 200 {
+				t.Fatalf(`POST /admin/moderation/{id} %s = %d %s, wanted 200`, test.resolution, status, code)
+			}
+
+			want := []string{test.event + " " + chirp.ID.String()}
+			if events := webhookEvents(t, cfg); !slices.Equal(events, want) {
+				t.Fatalf(`webhook events after %s = %q, wanted %q`, test.resolution, events, want)
+			}
+			status, _ = call(t, server, "GET", "/api/chirps/"+chirp.ID.String(), "", nil, nil)
+			if status != test.status {
+				t.Fatalf(`GET /api/chirps/{id} after %s = %d, wanted %d`, test.resolution, status, test.status)
+			}
+		})
+	}
+}
+
+// setState has admin put user in state through the API.
+func setState(t *testing.T, server *httptest.Server, admin, user testUser, body map[string]any) {
+	t.Helper()
+	status, code := call(t, server, "PUT", "/admin/users/"+user.ID.String()+"/state", admin.Token, body, nil)
+	if status != 200 {
+		t.Fatalf(`PUT /admin/users/{id}/state %v = %d %s, wanted 200`, body, status, code)
+	}
+}
+
+// newAdmin signs up a user and makes them an admin.
+func newAdmin(t *testing.T, server *httptest.Server, cfg *apiConfig, email string) testUser {
+	t.Helper()
+	admin := signUp(t, server, email)
+	_, err := cfg.dbQueries.SetUserAdmin(context.Background(), database.SetUserAdminParams{IsAdmin: true, ID: admin.ID})
+	if err != nil {
+		t.Fatalf(`SetUserAdmin() = %v`, err)
+	}
+	return admin
+}
+
+func TestSuspendedUser(t *testing.T) {
+	server, cfg := newTestServer(t)
+	gus := newAdmin(t, server, cfg, "gus@example.com")
+	walt := signUp(t, server, "walt@example.com")
+	creds := map[string]string{"email": "walt@example.com", "password": "hunter2"}
+
+	setState(t, server, gus, walt, map[string]any{
+		"state":      "suspended",
+		"reason":     "cooking",
+		"expires_at": time.Now().Add(time.Hour),
+	})
+	status, code := call(t, server, "POST", "/api/login", "", creds, nil)
+	if status != 403 || code != "account_suspended" {
+		t.Fatalf(`POST /api/login while suspended = %d %s, wanted 403 account_suspended`, status, code)
+	}
+	status, code = call(t, server, "POST", "/api/refresh", walt.RefreshToken, nil, nil)
+	if status != 403 || code != "account_suspended" {
+		t.Fatalf(`POST /api/refresh while suspended = %d %s, wanted 403 account_suspended`, status, code)
+	}
+
+	// The suspension lifts by itself once it expires, without anyone
+	// setting the state back.
+	_, err := cfg.users.SetUserState(context.Background(), database.SetUserStateParams{
+		State:          "suspended",
+		StateReason:    "cooking",
+		StateExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Minute).UTC(), Valid: true},
+		ID:             walt.ID,
+	})
+	if err != nil {
+		t.Fatalf(`SetUserState() = %v`, err)
+	}
+	status, code = call(t, server, "POST", "/api/login", "", creds, nil)
+	if status != 200 {
+		t.Fatalf(`POST /api/login after the suspension expired = %d %s, wanted 200`, status, code)
+	}
+	status, code = call(t, server, "POST", "/api/refresh", walt.RefreshToken, nil, nil)
+	if status != 200 {
+		t.Fatalf(`POST /api/refresh after the suspension expired = %d %s, wanted 200`, status, code)
+	}
+}
+
+func TestShadowBannedUser(t *testing.T) {
+	server, cfg := newTestServer(t)
+	gus := newAdmin(t, server, cfg, "gus@example.com")
+	walt := signUp(t, server, "walt@example.com")
+	jesse := signUp(t, server, "jesse@example.com")
+
+	setState(t, server, gus, walt, map[string]any{"state": "shadow_banned"})
+	chirp := postChirp(t, server, walt, "Say my name.")
+
+	for _, viewer := range []struct {
+		name   string
+		token  string
+		status int
+		chirps int
+	}{
+		{"anonymous", "", 404, 0},
+		{"another user", jesse.Token, 404, 0},
+		{"the author", walt.Token, 200, 1},
+	} {
+		status, _ := call(t, server, "GET", "/api/chirps/"+chirp.ID.String(), viewer.token, nil, nil)
+		if status != viewer.status {
+			t.Errorf(`GET /api/chirps/{id} as %s = %d, wanted %d`, viewer.name, status, viewer.status)
+		}
+		var chirps []testChirp
+		call(t, server, "GET", "/api/chirps", viewer.token, nil, &chirps)
+		if len(chirps) != viewer.chirps {
+			t.Errorf(`GET /api/chirps as %s = %d chirps, wanted %d`, viewer.name, len(chirps), viewer.chirps)
+		}
+	}
+}
+
+func TestUserStateRequiresAdmin(t *testing.T) {
+	server, _ := newTestServer(t)
+	walt := signUp(t, server, "walt@example.com")
+	jesse := signUp(t, server, "jesse@example.com")
+
+	status, code := call(t, server, "PUT", "/admin/users/"+walt.ID.String()+"/state", jesse.Token, map[string]any{"state": "suspended"}, nil)
+	if status != 403 || code != "forbidden" {
+		t.Fatalf(`PUT /admin/users/{id}/state as a non-admin = %d %s, wanted 403 forbidden`, status, code)
+	}
+	status, code = call(t, server, "POST", "/api/login", "", map[string]string{"email": "walt@example.com", "password": "hunter2"}, nil)
+	if status != 200 {
+		t.Fatalf(`POST /api/login after a refused suspension = %d %s, wanted 200`, status, code)
+	}
+}
@@ -0,0 +1,212 @@
+// Package apierror is chirpy's error model. Handlers return an *Error, which
+// carries the HTTP status, a stable machine-readable code and a message that
+// is safe to show, and Write turns it into an RFC 9457 problem+json
+// response. Anything else written with Write is an internal error: it is
+// logged with the request and the client only learns that something failed.
+package apierror
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jamistoso/chirpy/internal/logging"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Codes are part of the API: clients match on them, so they never change
+// meaning once released. New codes are fine.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeInvalidJSON          = "invalid_json"
+	CodeBodyTooLarge         = "body_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeInvalidParameter     = "invalid_parameter"
+	CodeValidationFailed     = "validation_failed"
+	CodeContentRejected      = "content_rejected"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidToken         = "invalid_token"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeInvalidSignature     = "invalid_signature"
+	CodeForbidden            = "forbidden"
+	CodeAccountSuspended     = "account_suspended"
+	CodeAccountDeactivated   = "account_deactivated"
+	CodePlanRequired         = "plan_required"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeLimitReached         = "limit_reached"
+	CodeUnprocessable        = "unprocessable"
+	CodeRateLimited          = "rate_limited"
+	CodeInternal             = "internal_error"
+)
+
+// TypePrefix prefixes a code to make a problem's type URI.
+const TypePrefix = "urn:chirpy:problem:"
+
+// FieldError says what is wrong with one field of the request: a JSON
+// field, query parameter or path value.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is the RFC 9457 body Write sends, with chirpy's code, field errors
+// and request ID as extension members.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+type Error struct {
+	Status int
+	Code   string
+	// Detail is shown to the client.
+	Detail string
+	Fields []FieldError
+	// Err is the underlying error. It is logged for internal errors and
+	// never shown.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func BadRequest(detail string) *Error {
+	return New(400, CodeInvalidRequest, detail)
+}
+
+// InvalidJSON reports a request body that didn't decode, or was too large
+// to.
+func InvalidJSON(err error) *Error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return &Error{
+			Status: 413,
+			Code:   CodeBodyTooLarge,
+			Detail: fmt.Sprintf("request body is larger than %d bytes", tooLarge.Limit),
+			Err:    err,
+		}
+	}
+	return &Error{
+		Status: 400,
+		Code:   CodeInvalidJSON,
+		Detail: "request body is not valid JSON: " + err.Error(),
+		Err:    err,
+	}
+}
+
+// InvalidParameter reports a malformed path value or query parameter.
+func InvalidParameter(name, message string) *Error {
+	return &Error{
+		Status: 400,
+		Code:   CodeInvalidParameter,
+		Detail: "invalid " + name,
+		Fields: []FieldError{{Field: name, Message: message}},
+	}
+}
+
+// Validation reports a well-formed request whose fields break the rules.
+func Validation(fields ...FieldError) *Error {
+	detail := "the request has invalid fields"
+	if len(fields) == 1 {
+		detail = fields[0].Field + ": " + fields[0].Message
+	}
+	return &Error{
+		Status: 400,
+		Code:   CodeValidationFailed,
+		Detail: detail,
+		Fields: fields,
+	}
+}
+
+func Unauthorized(code, detail string) *Error {
+	return New(401, code, detail)
+}
+
+func Forbidden(code, detail string) *Error {
+	return New(403, code, detail)
+}
+
+func NotFound(detail string) *Error {
+	return New(404, CodeNotFound, detail)
+}
+
+// NoRows returns e when err is a lookup that found nothing, and Internal(err)
+// otherwise, so that a database failure isn't reported as a missing row:
+//
+//	apierror.NoRows(err, apierror.NotFound("chirp not found"))
+func NoRows(err error, e *Error) *Error {
+	if errors.Is(err, sql.ErrNoRows) {
+		e.Err = err
+		return e
+	}
+	return Internal(err)
+}
+
+func Conflict(code, detail string) *Error {
+	return New(409, code, detail)
+}
+
+func Internal(err error) *Error {
+	return &Error{
+		Status: 500,
+		Code:   CodeInternal,
+		Detail: "internal server error",
+		Err:    err,
+	}
+}
+
+// Write responds with err as a problem. Errors that aren't an *Error, and
+// *Errors with a 5xx status, are logged with the request's logger.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = Internal(err)
+	}
+	if apiErr.Status >= 500 {
+		logging.FromContext(r.Context()).ErrorContext(r.Context(), "internal error",
+			"code", apiErr.Code,
+			"err", apiErr.Err,
+		)
+		trace.SpanFromContext(r.Context()).RecordError(apiErr.Err)
+	}
+
+	dat, err := json.Marshal(Problem{
+		Type:      TypePrefix + apiErr.Code,
+		Title:     http.StatusText(apiErr.Status),
+		Status:    apiErr.Status,
+		Detail:    apiErr.Detail,
+		Instance:  r.URL.Path,
+		Code:      apiErr.Code,
+		Errors:    apiErr.Fields,
+		RequestID: r.Header.Get("X-Request-ID"),
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error marshalling problem", "err", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(apiErr.Status)
+	w.Write(dat)
+}
@@ -0,0 +1,104 @@
+package apierror
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jamistoso/chirpy/internal/logging"
+)
+
+// write runs Write for err and decodes the problem, capturing what was
+// logged.
+func write(t *testing.T, err error) (*httptest.ResponseRecorder, Problem, string) {
+	t.Helper()
+	var logs bytes.Buffer
+	rq := httptest.NewRequest("GET", "/api/chirps/123", nil)
+	rq.Header.Set("X-Request-ID", "req-1")
+	rq = rq.WithContext(logging.NewContext(rq.Context(), slog.New(slog.NewTextHandler(&logs, nil))))
+
+	w := httptest.NewRecorder()
+	Write(w, rq, err)
+	var problem Problem
+	decodeErr := json.Unmarshal(w.Body.Bytes(), &problem)
+	if decodeErr != nil {
+		t.Fatalf(`decoding %q: %v`, w.Body.String(), decodeErr)
+	}
+	return w, problem, logs.String()
+}
+
+func TestWriteProblem(t *testing.T) {
+	w, problem, logs := write(t, InvalidParameter("chirpID", "must be a UUID"))
+
+	if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Fatalf(`Content-Type = %q, wanted application/problem+json`, got)
+	}
+	want := Problem{
+		Type:      "urn:chirpy:problem:invalid_parameter",
+		Title:     "Bad Request",
+		Status:    400,
+		Detail:    "invalid chirpID",
+		Instance:  "/api/chirps/123",
+		Code:      CodeInvalidParameter,
+		Errors:    []FieldError{{Field: "chirpID", Message: "must be a UUID"}},
+		RequestID: "req-1",
+	}
+	if w.Code != 400 || !reflect.DeepEqual(problem, want) {
+		t.Fatalf(`Write() = %d %+v, wanted 400 %+v`, w.Code, problem, want)
+	}
+	if logs != "" {
+		t.Fatalf(`Write() logged %q for a client error, wanted nothing`, logs)
+	}
+}
+
+func TestWriteHidesInternalErrors(t *testing.T) {
+	cause := errors.New(`pq: relation "chirps" does not exist`)
+	for _, err := range []error{
+		fmt.Errorf("retrieving chirps: %w", cause),
+		Internal(cause),
+	} {
+		w, problem, logs := write(t, err)
+		if w.Code != 500 || problem.Code != CodeInternal {
+			t.Fatalf(`Write(%v) = %d %q, wanted 500 %q`, err, w.Code, problem.Code, CodeInternal)
+		}
+		if strings.Contains(w.Body.String(), "relation") {
+			t.Fatalf(`Write(%v) body = %s, wanted the cause left out`, err, w.Body)
+		}
+		if !strings.Contains(logs, "internal error") || !strings.Contains(logs, "relation") {
+			t.Fatalf(`Write(%v) logged %q, wanted the cause`, err, logs)
+		}
+	}
+}
+
+func TestInvalidJSON(t *testing.T) {
+	err := InvalidJSON(&http.MaxBytesError{Limit: 1024})
+	if err.Status != 413 || err.Code != CodeBodyTooLarge {
+		t.Fatalf(`InvalidJSON(MaxBytesError) = %d %q, wanted 413 %q`, err.Status, err.Code, CodeBodyTooLarge)
+	}
+
+	var v struct{}
+	decodeErr := json.Unmarshal([]byte(`{`), &v)
+	err = InvalidJSON(decodeErr)
+	if err.Status != 400 || err.Code != CodeInvalidJSON {
+		t.Fatalf(`InvalidJSON(syntax error) = %d %q, wanted 400 %q`, err.Status, err.Code, CodeInvalidJSON)
+	}
+}
+
+func TestNoRows(t *testing.T) {
+	err := NoRows(sql.ErrNoRows, NotFound("chirp not found"))
+	if err.Status != 404 || !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf(`NoRows(sql.ErrNoRows) = %d %v, wanted 404 wrapping sql.ErrNoRows`, err.Status, err)
+	}
+	err = NoRows(sql.ErrConnDone, NotFound("chirp not found"))
+	if err.Status != 500 || err.Code != CodeInternal {
+		t.Fatalf(`NoRows(sql.ErrConnDone) = %d %q, wanted an internal error`, err.Status, err.Code)
+	}
+}
@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/database"
+)
+
+// Actor types recorded alongside the actor ID. Only user and admin actors
+// have an ID; the rest are identified by the request's IP and the action.
+const (
+	ActorUser      = "user"
+	ActorAdmin     = "admin"
+	ActorWebhook   = "webhook"
+	ActorAnonymous = "anonymous"
+	ActorSystem    = "system"
+)
+
+type Event struct {
+	ActorID    uuid.UUID
+	ActorType  string
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     any
+	After      any
+}
+
+type Store interface {
+	CreateAuditEntry(ctx context.Context, arg database.CreateAuditEntryParams) (database.AuditLog, error)
+}
+
+// Auditor appends events to the audit log. The table rejects updates and
+// deletes, so nothing written here can be rewritten later.
+type Auditor struct {
+	store    Store
+	clientIP func(*http.Request) string
+}
+
+func New(store Store, clientIP func(*http.Request) string) *Auditor {
+	return &Auditor{
+		store:    store,
+		clientIP: clientIP,
+	}
+}
+
+// Record writes e, taking the request ID and client IP from rq.
+func (a *Auditor) Record(rq *http.Request, e Event) error {
+	return a.write(rq.Context(), rq.Header.Get("X-Request-ID"), a.clientIP(rq), e)
+}
+
+// RecordSystem writes e for work that isn't tied to a request, such as
+// scheduled jobs.
+func (a *Auditor) RecordSystem(ctx context.Context, e Event) error {
+	if e.ActorType == "" {
+		e.ActorType = ActorSystem
+	}
+	return a.write(ctx, "", "", e)
+}
+
+func (a *Auditor) write(ctx context.Context, requestID, ip string, e Event) error {
+	before, err := json.Marshal(e.Before)
+	if err != nil {
+		return err
+	}
+	after, err := json.Marshal(e.After)
+	if err != nil {
+		return err
+	}
+
+	actorID := uuid.NullUUID{}
+	if e.ActorID != uuid.Nil {
+		actorID = uuid.NullUUID{UUID: e.ActorID, Valid: true}
+	}
+	actorType := e.ActorType
+	if actorType == "" {
+		actorType = ActorAnonymous
+	}
+
+	_, err = a.store.CreateAuditEntry(ctx, database.CreateAuditEntryParams{
+		ActorID:    actorID,
+		ActorType:  actorType,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		RequestID:  requestID,
+		Ip:         ip,
+		Before:     before,
+		After:      after,
+	})
+	return err
+}
@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/database"
+)
+
+type fakeStore struct {
+	entries []database.CreateAuditEntryParams
+}
+
+func (f *fakeStore) CreateAuditEntry(ctx context.Context, arg database.CreateAuditEntryParams) (database.AuditLog, error) {
+	f.entries = append(f.entries, arg)
+	return database.AuditLog{}, nil
+}
+
+func TestRecordFillsRequestContext(t *testing.T) {
+	store := &fakeStore{}
+	auditor := New(store, func(*http.Request) string { return "203.0.113.7" })
+
+	rq := httptest.NewRequest("DELETE", "/api/chirps/1", nil)
+	rq.Header.Set("X-Request-ID", "req-123")
+	actor := uuid.New()
+	err := auditor.Record(rq, Event{
+		ActorID:    actor,
+		ActorType:  ActorUser,
+		Action:     "chirp.delete",
+		TargetType: "chirp",
+		TargetID:   "1",
+		Before:     map[string]string{"body": "hello"},
+	})
+	if err != nil {
+		t.Fatalf(`Record() = %v, wanted nil`, err)
+	}
+
+	entry := store.entries[0]
+	if entry.RequestID != "req-123" || entry.Ip != "203.0.113.7" {
+		t.Fatalf(`Record() request id, ip = %q, %q, wanted "req-123", "203.0.113.7"`, entry.RequestID, entry.Ip)
+	}
+	if !entry.ActorID.Valid || entry.ActorID.UUID != actor {
+		t.Fatalf(`Record() actor = %v, wanted %v`, entry.ActorID, actor)
+	}
+	if string(entry.Before) != `{"body":"hello"}` || string(entry.After) != "null" {
+		t.Fatalf(`Record() before, after = %s, %s, wanted {"body":"hello"}, null`, entry.Before, entry.After)
+	}
+}
+
+func TestRecordAnonymousActor(t *testing.T) {
+	store := &fakeStore{}
+	auditor := New(store, func(*http.Request) string { return "203.0.113.7" })
+
+	err := auditor.Record(httptest.NewRequest("POST", "/admin/reset", nil), Event{Action: "admin.reset"})
+	if err != nil {
+		t.Fatalf(`Record() = %v, wanted nil`, err)
+	}
+	if store.entries[0].ActorID.Valid || store.entries[0].ActorType != ActorAnonymous {
+		t.Fatalf(`Record() actor = %v %q, wanted no id and %q`, store.entries[0].ActorID, store.entries[0].ActorType, ActorAnonymous)
+	}
+}
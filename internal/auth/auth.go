@@ -1,10 +1,13 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"net/http"
 	"crypto/rand"
+	"strconv"
 	"strings"
 	"time"
 
@@ -92,4 +95,51 @@ func GetAPIKey(headers http.Header) (string, error) {
 		return "", fmt.Errorf("no bearer token found in headers")
 	}
 	return strings.TrimPrefix(apiKey, "ApiKey "), nil
-}
\ No newline at end of file
+}
+
+// SignWebhook signs body as sent at timestamp, returning the value for the
+// signature header. The timestamp is part of the signed payload so that a
+// captured request can't be replayed later with a fresh timestamp.
+func SignWebhook(body []byte, secret string, timestamp time.Time) string {
+	return "sha256=" + webhookMAC(body, secret, strconv.FormatInt(timestamp.Unix(), 10))
+}
+
+// VerifyWebhookSignature checks a signature header produced by SignWebhook
+// against every secret in secrets, so keys can be rotated by running old and
+// new side by side. The header may carry several comma-separated signatures.
+func VerifyWebhookSignature(signature, timestamp string, body []byte, secrets []string, now time.Time, tolerance time.Duration) error {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp")
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age > tolerance || age < -tolerance {
+		return fmt.Errorf("webhook timestamp outside tolerance")
+	}
+
+	for _, candidate := range strings.Split(signature, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if !strings.HasPrefix(candidate, "sha256=") {
+			continue
+		}
+		given, err := hex.DecodeString(strings.TrimPrefix(candidate, "sha256="))
+		if err != nil {
+			continue
+		}
+		for _, secret := range secrets {
+			expected, _ := hex.DecodeString(webhookMAC(body, secret, timestamp))
+			if hmac.Equal(given, expected) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("webhook signature mismatch")
+}
+
+func webhookMAC(body []byte, secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
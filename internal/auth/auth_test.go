@@ -99,4 +99,42 @@ func TestGetBearerFailNoHeader(t *testing.T) {
     if err == nil || result != desiredResult {
         t.Fatalf(`GetBearerToken(headers) = %q, %v, wanted "", nil`, result, err)
     }
-}
\ No newline at end of file
+}
+
+func TestWebhookSignature(t *testing.T) {
+    body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+    now := time.Now()
+    timestamp := fmt.Sprint(now.Unix())
+    signature := SignWebhook(body, "new-key", now)
+
+    err := VerifyWebhookSignature(signature, timestamp, body, []string{"old-key", "new-key"}, now, 5*time.Minute)
+    if err != nil {
+        t.Fatalf(`VerifyWebhookSignature(rotated keys) = %v, wanted nil`, err)
+    }
+
+    err = VerifyWebhookSignature(signature, timestamp, body, []string{"old-key"}, now, 5*time.Minute)
+    if err == nil {
+        t.Fatal(`VerifyWebhookSignature(wrong key) = nil, wanted error`)
+    }
+
+    err = VerifyWebhookSignature(signature, timestamp, []byte(`{"id":"evt_2","event":"user.upgraded"}`), []string{"new-key"}, now, 5*time.Minute)
+    if err == nil {
+        t.Fatal(`VerifyWebhookSignature(tampered body) = nil, wanted error`)
+    }
+}
+
+func TestWebhookSignatureReplay(t *testing.T) {
+    body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+    sent := time.Now().Add(-10 * time.Minute)
+    signature := SignWebhook(body, "key", sent)
+
+    err := VerifyWebhookSignature(signature, fmt.Sprint(sent.Unix()), body, []string{"key"}, time.Now(), 5*time.Minute)
+    if err == nil {
+        t.Fatal(`VerifyWebhookSignature(stale timestamp) = nil, wanted error`)
+    }
+
+    err = VerifyWebhookSignature(signature, fmt.Sprint(time.Now().Unix()), body, []string{"key"}, time.Now(), 5*time.Minute)
+    if err == nil {
+        t.Fatal(`VerifyWebhookSignature(re-stamped replay) = nil, wanted error`)
+    }
+}
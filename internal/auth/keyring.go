@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// SigningKey is an HMAC key for access tokens. Tokens name the key that
+// signed them in their kid header.
+type SigningKey struct {
+	ID     string
+	Secret string
+}
+
+// NewSigningKey generates a key with a random ID and secret.
+func NewSigningKey() (SigningKey, error) {
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	_, err := rand.Read(id)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	_, err = rand.Read(secret)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return SigningKey{ID: hex.EncodeToString(id), Secret: hex.EncodeToString(secret)}, nil
+}
+
+// Keyring signs access tokens with the current key and accepts tokens from
+// any key it still holds, so keys can be rotated without logging everyone
+// out.
+//
+// Before the first rotation there are no keys and the legacy secret (the
+// JWT_SECRET setting) signs tokens without a kid, as MakeJWT does. The legacy
+// secret keeps validating those tokens until Update is given an empty one.
+type Keyring struct {
+	mu     sync.RWMutex
+	active SigningKey
+	keys   map[string]string
+	legacy string
+
+	// Refresh, if set, is called when a token names a key the keyring doesn't
+	// hold, in case another instance has just rotated. It is called at most
+	// once per RefreshInterval.
+	Refresh         func() error
+	RefreshInterval time.Duration
+	lastRefresh     time.Time
+	refreshMu       sync.Mutex
+}
+
+func NewKeyring(legacySecret string) *Keyring {
+	return &Keyring{
+		keys:            map[string]string{},
+		legacy:          legacySecret,
+		RefreshInterval: 5 * time.Second,
+	}
+}
+
+// Update replaces the keys. active signs new tokens, unless its ID is empty,
+// in which case the legacy secret does; active and verify both validate.
+func (k *Keyring) Update(active SigningKey, verify []SigningKey, legacySecret string) {
+	keys := map[string]string{}
+	for _, key := range verify {
+		keys[key.ID] = key.Secret
+	}
+	if active.ID != "" {
+		keys[active.ID] = active.Secret
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.active = active
+	k.keys = keys
+	k.legacy = legacySecret
+}
+
+// CanSign reports whether there is a key to sign tokens with.
+func (k *Keyring) CanSign() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active.ID != "" || k.legacy != ""
+}
+
+func (k *Keyring) MakeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	k.mu.RLock()
+	active, legacy := k.active, k.legacy
+	k.mu.RUnlock()
+
+	if active.ID == "" {
+		if legacy == "" {
+			return "", errors.New("no signing key")
+		}
+		return MakeJWT(userID, legacy, expiresIn)
+	}
+
+	claims := jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+		Subject:   userID.String(),
+	}
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	jwtToken.Header["kid"] = active.ID
+	return jwtToken.SignedString([]byte(active.Secret))
+}
+
+func (k *Keyring) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	claims := &jwt.RegisteredClaims{}
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		secret, ok := k.secret(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return []byte(secret), nil
+	}
+	jwtToken, err := jwt.ParseWithClaims(tokenString, claims, keyFunc)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("failed to parse jwt token: %s", err)
+	}
+	id, err := jwtToken.Claims.GetSubject()
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("unable to retrieve user id")
+	}
+	userId, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("unable to parse user id")
+	}
+	return userId, nil
+}
+
+func (k *Keyring) secret(kid string) (string, bool) {
+	lookup := func() (string, bool) {
+		k.mu.RLock()
+		defer k.mu.RUnlock()
+		if kid == "" {
+			return k.legacy, k.legacy != ""
+		}
+		secret, ok := k.keys[kid]
+		return secret, ok
+	}
+
+	secret, ok := lookup()
+	if ok || kid == "" || !k.refresh() {
+		return secret, ok
+	}
+	return lookup()
+}
+
+// refresh calls Refresh unless it ran recently, reporting whether it did.
+func (k *Keyring) refresh() bool {
+	if k.Refresh == nil {
+		return false
+	}
+	k.refreshMu.Lock()
+	defer k.refreshMu.Unlock()
+	if time.Since(k.lastRefresh) < k.RefreshInterval {
+		return false
+	}
+	k.lastRefresh = time.Now()
+	return k.Refresh() == nil
+}
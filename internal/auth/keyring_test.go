@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestKeyringLegacySecret(t *testing.T) {
+	userID := uuid.New()
+	keyring := NewKeyring("legacy")
+
+	token, err := keyring.MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatalf(`MakeJWT() = %v, wanted nil`, err)
+	}
+	// Interchangeable with the plain functions before any rotation.
+	got, err := ValidateJWT(token, "legacy")
+	if err != nil || got != userID {
+		t.Fatalf(`ValidateJWT(keyring token) = %v, %v, wanted %v`, got, err, userID)
+	}
+	old, _ := MakeJWT(userID, "legacy", time.Hour)
+	got, err = keyring.ValidateJWT(old)
+	if err != nil || got != userID {
+		t.Fatalf(`keyring.ValidateJWT(legacy token) = %v, %v, wanted %v`, got, err, userID)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	userID := uuid.New()
+	keyring := NewKeyring("legacy")
+	legacyToken, _ := keyring.MakeJWT(userID, time.Hour)
+
+	first, _ := NewSigningKey()
+	keyring.Update(first, nil, "legacy")
+	firstToken, err := keyring.MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatalf(`MakeJWT() = %v, wanted nil`, err)
+	}
+	_, err = ValidateJWT(firstToken, "legacy")
+	if err == nil {
+		t.Fatalf(`token from the first key validated with the legacy secret`)
+	}
+
+	second, _ := NewSigningKey()
+	keyring.Update(second, []SigningKey{first}, "")
+	secondToken, _ := keyring.MakeJWT(userID, time.Hour)
+
+	for name, token := range map[string]string{"first": firstToken, "second": secondToken} {
+		got, err := keyring.ValidateJWT(token)
+		if err != nil || got != userID {
+			t.Fatalf(`ValidateJWT(%s key token) = %v, %v, wanted %v`, name, got, err, userID)
+		}
+	}
+	_, err = keyring.ValidateJWT(legacyToken)
+	if err == nil {
+		t.Fatalf(`ValidateJWT(legacy token) = nil after the legacy secret was dropped, wanted error`)
+	}
+
+	keyring.Update(second, nil, "")
+	_, err = keyring.ValidateJWT(firstToken)
+	if err == nil {
+		t.Fatalf(`ValidateJWT(first key token) = nil after the key was dropped, wanted error`)
+	}
+}
+
+func TestKeyringRefreshesOnUnknownKey(t *testing.T) {
+	userID := uuid.New()
+	key, _ := NewSigningKey()
+
+	// Another instance rotated and signed with a key this one hasn't loaded.
+	other := NewKeyring("")
+	other.Update(key, nil, "")
+	token, _ := other.MakeJWT(userID, time.Hour)
+
+	keyring := NewKeyring("legacy")
+	refreshes := 0
+	keyring.Refresh = func() error {
+		refreshes++
+		keyring.Update(key, nil, "legacy")
+		return nil
+	}
+	got, err := keyring.ValidateJWT(token)
+	if err != nil || got != userID || refreshes != 1 {
+		t.Fatalf(`ValidateJWT() = %v, %v after %d refreshes, wanted %v after 1`, got, err, refreshes, userID)
+	}
+
+	// Garbage kids don't turn into a refresh per request.
+	forged := NewKeyring("")
+	unknown, _ := NewSigningKey()
+	forged.Update(unknown, nil, "")
+	forgedToken, _ := forged.MakeJWT(userID, time.Hour)
+	for range 3 {
+		_, err = keyring.ValidateJWT(forgedToken)
+		if err == nil {
+			t.Fatalf(`ValidateJWT(unknown key) = nil, wanted error`)
+		}
+	}
+	if refreshes != 1 {
+		t.Fatalf(`Refresh called %d times, wanted 1`, refreshes)
+	}
+}
+
+func TestKeyringWithoutKeys(t *testing.T) {
+	keyring := NewKeyring("")
+	if keyring.CanSign() {
+		t.Fatalf(`CanSign() = true with no keys, wanted false`)
+	}
+	_, err := keyring.MakeJWT(uuid.New(), time.Hour)
+	if err == nil {
+		t.Fatalf(`MakeJWT() with no keys = nil, wanted error`)
+	}
+}
@@ -0,0 +1,376 @@
+// Package config loads chirpy's settings. Defaults are overridden by a YAML
+// config file, which is overridden by environment variables, which commands
+// override again with their flags.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jamistoso/chirpy/internal/logging"
+	"github.com/jamistoso/chirpy/internal/ratelimit"
+	"github.com/jamistoso/chirpy/internal/sqlite"
+	"github.com/jamistoso/chirpy/internal/tracing"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	PlatformDev  = "dev"
+	PlatformProd = "prod"
+
+	RateLimitMemory   = "memory"
+	RateLimitPostgres = "postgres"
+
+	// MinSecretLength is the shortest JWT_SECRET accepted, 256 bits worth
+	// of hex.
+	MinSecretLength = 32
+)
+
+// Secret is a setting that must not end up in logs or `chirpy config print`.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+func (s Secret) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
+
+type Config struct {
+	DatabaseURL      string    `yaml:"db_url"`
+	Platform         string    `yaml:"platform"`
+	AutoMigrate      bool      `yaml:"auto_migrate"`
+	JWTSecret        Secret    `yaml:"jwt_secret"`
+	PolkaKeys        []Secret  `yaml:"polka_keys"`
+	TrustedProxies   []string  `yaml:"trusted_proxies"`
+	EntitlementsFile string    `yaml:"entitlements_file"`
+	RateLimit        RateLimit `yaml:"rate_limit"`
+	Server           Server    `yaml:"server"`
+	Log              Log       `yaml:"log"`
+	Tracing          Tracing   `yaml:"tracing"`
+	Webhooks         Webhooks  `yaml:"webhooks"`
+}
+
+type Webhooks struct {
+	// AllowLoopback lets endpoints point at localhost, for receivers
+	// running next to a development server. Only the dev platform allows
+	// it.
+	AllowLoopback bool `yaml:"allow_loopback"`
+}
+
+// Tracing picks where spans go. The OTLP exporter reads its endpoint and
+// headers from the standard OTEL_EXPORTER_OTLP_* variables.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+type Log struct {
+	Format string     `yaml:"format"`
+	Level  slog.Level `yaml:"level"`
+}
+
+type RateLimit struct {
+	Store  string `yaml:"store"`
+	Auth   string `yaml:"auth"`
+	Chirps string `yaml:"chirps"`
+}
+
+// Server holds the settings of `chirpy serve`.
+type Server struct {
+	Addr              string        `yaml:"addr"`
+	TLSCert           string        `yaml:"tls_cert"`
+	TLSKey            string        `yaml:"tls_key"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+	// MetricsAddr is where /metrics is served, over plain HTTP, apart from
+	// the API so that it needn't be exposed with it. Empty turns it off.
+	// The default only takes connections from the same host, on the port
+	// OpenTelemetry's Prometheus exporter uses rather than Prometheus's own
+	// 9090, which a host running Prometheus has taken; set it to e.g.
+	// ":9464" for a scraper on another host.
+	MetricsAddr string `yaml:"metrics_addr"`
+	// DrainDelay is how long serve keeps accepting requests, while
+	// reporting not ready, after it is told to stop. Set it to about the
+	// load balancer's health check interval so it stops sending traffic
+	// before the listener closes.
+	DrainDelay time.Duration `yaml:"drain_delay"`
+	// ShutdownTimeout bounds how long in-flight requests and background
+	// jobs get to finish once the listener is closed.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+func Default() Config {
+	return Config{
+		Platform: PlatformProd,
+		RateLimit: RateLimit{
+			Store:  RateLimitMemory,
+			Auth:   "10/1m",
+			Chirps: "30/1m:10",
+		},
+		Server: Server{
+			Addr:              ":8080",
+			MetricsAddr:       "127.0.0.1:9464",
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Log: Log{
+			Format: logging.FormatText,
+			Level:  slog.LevelInfo,
+		},
+		Tracing: Tracing{
+			Exporter:    tracing.ExporterNone,
+			SampleRatio: 1,
+		},
+	}
+}
+
+// envVars are the environment variables Load reads, in the order they are
+// applied.
+var envVars = []struct {
+	name string
+	set  func(c *Config, value string) error
+}{
+	{"DB_URL", func(c *Config, v string) error { c.DatabaseURL = v; return nil }},
+	{"PLATFORM", func(c *Config, v string) error { c.Platform = v; return nil }},
+	{"AUTO_MIGRATE", func(c *Config, v string) error { return parseBool(&c.AutoMigrate, v) }},
+	{"JWT_SECRET", func(c *Config, v string) error { c.JWTSecret = Secret(v); return nil }},
+	// POLKA_KEY is the single key setting from before rotation was
+	// supported; POLKA_KEYS wins when both are set.
+	{"POLKA_KEY", func(c *Config, v string) error { c.PolkaKeys = secretList(v); return nil }},
+	{"POLKA_KEYS", func(c *Config, v string) error { c.PolkaKeys = secretList(v); return nil }},
+	{"TRUSTED_PROXIES", func(c *Config, v string) error { c.TrustedProxies = SplitList(v); return nil }},
+	{"ENTITLEMENTS_FILE", func(c *Config, v string) error { c.EntitlementsFile = v; return nil }},
+	{"RATE_LIMIT_STORE", func(c *Config, v string) error { c.RateLimit.Store = v; return nil }},
+	{"RATE_LIMIT_AUTH", func(c *Config, v string) error { c.RateLimit.Auth = v; return nil }},
+	{"RATE_LIMIT_CHIRPS", func(c *Config, v string) error { c.RateLimit.Chirps = v; return nil }},
+	{"ADDR", func(c *Config, v string) error { c.Server.Addr = v; return nil }},
+	{"METRICS_ADDR", func(c *Config, v string) error { c.Server.MetricsAddr = v; return nil }},
+	{"TLS_CERT", func(c *Config, v string) error { c.Server.TLSCert = v; return nil }},
+	{"TLS_KEY", func(c *Config, v string) error { c.Server.TLSKey = v; return nil }},
+	{"LOG_FORMAT", func(c *Config, v string) error { c.Log.Format = v; return nil }},
+	{"LOG_LEVEL", func(c *Config, v string) error { return c.Log.Level.UnmarshalText([]byte(v)) }},
+	{"TRACING_EXPORTER", func(c *Config, v string) error { c.Tracing.Exporter = v; return nil }},
+	{"TRACING_SAMPLE_RATIO", func(c *Config, v string) error { return parseFloat(&c.Tracing.SampleRatio, v) }},
+	{"WEBHOOKS_ALLOW_LOOPBACK", func(c *Config, v string) error { return parseBool(&c.Webhooks.AllowLoopback, v) }},
+}
+
+// Load returns the defaults overridden by the config file at path, when path
+// isn't empty, and then by the variables lookupEnv finds. It doesn't
+// validate the result, so that flags can still change it.
+func Load(path string, lookupEnv func(string) (string, bool)) (Config, error) {
+	conf := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("reading config file: %w", err)
+		}
+		err = conf.parse(data)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	for _, env := range envVars {
+		value, ok := lookupEnv(env.name)
+		if !ok || value == "" {
+			continue
+		}
+		err := env.set(&conf, value)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: %w", env.name, err)
+		}
+	}
+	return conf, nil
+}
+
+// parse overrides conf with the settings in a YAML document. Unknown keys
+// are an error so that typos don't go unnoticed.
+func (conf *Config) parse(data []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(conf)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (conf Config) Validate() error {
+	var errs []error
+	if conf.DatabaseURL == "" {
+		errs = append(errs, errors.New("DB_URL is not set"))
+	}
+	if conf.Platform != PlatformDev && conf.Platform != PlatformProd {
+		errs = append(errs, fmt.Errorf("PLATFORM must be %q or %q, not %q", PlatformDev, PlatformProd, conf.Platform))
+	}
+	// An empty JWT_SECRET is fine once keys are rotated into the database;
+	// serve refuses to start when it has nothing to sign with.
+	if conf.JWTSecret != "" && len(conf.JWTSecret) < MinSecretLength {
+		errs = append(errs, fmt.Errorf("JWT_SECRET must be at least %d characters", MinSecretLength))
+	}
+	if len(conf.PolkaKeys) == 0 && conf.Platform != PlatformDev {
+		errs = append(errs, errors.New("POLKA_KEYS is not set, so no Polka webhook could be verified"))
+	}
+	_, err := ratelimit.ParseTrustedProxies(strings.Join(conf.TrustedProxies, ","))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("TRUSTED_PROXIES: %w", err))
+	}
+
+	switch conf.RateLimit.Store {
+	case RateLimitMemory:
+	case RateLimitPostgres:
+		if sqlite.IsURL(conf.DatabaseURL) {
+			errs = append(errs, errors.New("RATE_LIMIT_STORE=postgres needs a Postgres DB_URL"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_STORE must be %q or %q, not %q", RateLimitMemory, RateLimitPostgres, conf.RateLimit.Store))
+	}
+	_, err = ratelimit.ParsePolicy("auth", conf.RateLimit.Auth)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_AUTH: %w", err))
+	}
+	_, err = ratelimit.ParsePolicy("chirps", conf.RateLimit.Chirps)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_CHIRPS: %w", err))
+	}
+
+	if conf.Log.Format != logging.FormatText && conf.Log.Format != logging.FormatJSON {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT must be %q or %q, not %q", logging.FormatText, logging.FormatJSON, conf.Log.Format))
+	}
+
+	switch conf.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER must be %q, %q or %q, not %q", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP, conf.Tracing.Exporter))
+	}
+	if conf.Tracing.SampleRatio < 0 || conf.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1, not %g", conf.Tracing.SampleRatio))
+	}
+
+	if conf.Webhooks.AllowLoopback && conf.Platform != PlatformDev {
+		errs = append(errs, fmt.Errorf("WEBHOOKS_ALLOW_LOOPBACK is only allowed when PLATFORM is %q", PlatformDev))
+	}
+
+	errs = append(errs, conf.Server.Validate())
+	return errors.Join(errs...)
+}
+
+// Validate checks the server settings. Serve calls it again after applying
+// its flags.
+func (s Server) Validate() error {
+	var errs []error
+	if s.Addr == "" {
+		errs = append(errs, errors.New("the listen address is empty"))
+	}
+	if s.MetricsAddr != "" && s.MetricsAddr == s.Addr {
+		errs = append(errs, errors.New("METRICS_ADDR must differ from the listen address"))
+	}
+	if (s.TLSCert == "") != (s.TLSKey == "") {
+		errs = append(errs, errors.New("TLS_CERT and TLS_KEY must be set together"))
+	}
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"read_header_timeout", s.ReadHeaderTimeout},
+		{"read_timeout", s.ReadTimeout},
+		{"write_timeout", s.WriteTimeout},
+		{"idle_timeout", s.IdleTimeout},
+		{"shutdown_timeout", s.ShutdownTimeout},
+	} {
+		if timeout.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, not %s", timeout.name, timeout.value))
+		}
+	}
+	if s.DrainDelay < 0 {
+		errs = append(errs, fmt.Errorf("drain_delay can't be negative, not %s", s.DrainDelay))
+	}
+	if s.MaxHeaderBytes < 4<<10 {
+		errs = append(errs, fmt.Errorf("max_header_bytes must be at least 4KiB, not %d", s.MaxHeaderBytes))
+	}
+	return errors.Join(errs...)
+}
+
+// YAML returns the configuration as a config file, with secrets and the
+// database password redacted.
+func (conf Config) YAML() ([]byte, error) {
+	conf.DatabaseURL = redactURL(conf.DatabaseURL)
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	err := encoder.Encode(conf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), encoder.Close()
+}
+
+// redactURL hides the password of a database URL. Connection strings that
+// aren't URLs are hidden whole, as they may have a password anywhere.
+func redactURL(dbURL string) string {
+	u, err := url.Parse(dbURL)
+	if err != nil || u.Scheme == "" {
+		return Secret(dbURL).String()
+	}
+	return u.Redacted()
+}
+
+func parseBool(dst *bool, value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", value)
+	}
+	*dst = b
+	return nil
+}
+
+func parseFloat(dst *float64, value string) error {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", value)
+	}
+	*dst = f
+	return nil
+}
+
+// SplitList splits a comma separated list, like a setting, dropping empty
+// entries.
+func SplitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func secretList(list string) []Secret {
+	var secrets []Secret
+	for _, item := range SplitList(list) {
+		secrets = append(secrets, Secret(item))
+	}
+	return secrets
+}
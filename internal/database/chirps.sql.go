@@ -7,29 +7,32 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, visibility)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, visibility
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.NullUUID
+	Body       string
+	UserID     uuid.NullUUID
+	Visibility string
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.Visibility)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +40,7 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Visibility,
 	)
 	return i, err
 }
@@ -51,13 +55,39 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
-const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at ASC
+const getOneChirp = `-- name: GetOneChirp :one
+SELECT id, created_at, updated_at, body, user_id, visibility FROM chirps
+WHERE id = $1
+`
+
+func (q *Queries) GetOneChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getOneChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getRecentChirpsFromAuthor = `-- name: GetRecentChirpsFromAuthor :many
+SELECT id, created_at, updated_at, body, user_id, visibility FROM chirps
+WHERE user_id = $1
+AND created_at > $2
+ORDER BY created_at DESC
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+type GetRecentChirpsFromAuthorParams struct {
+	UserID uuid.NullUUID
+	Since  time.Time
+}
+
+func (q *Queries) GetRecentChirpsFromAuthor(ctx context.Context, arg GetRecentChirpsFromAuthorParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentChirpsFromAuthor, arg.UserID, arg.Since)
 	if err != nil {
 		return nil, err
 	}
@@ -71,6 +101,7 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Visibility,
 		); err != nil {
 			return nil, err
 		}
@@ -85,13 +116,42 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirpsFromAuthor = `-- name: GetChirpsFromAuthor :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
+const listChirps = `-- name: ListChirps :many
+SELECT id, created_at, updated_at, body, user_id, visibility FROM chirps
+WHERE ($1 IS NULL OR user_id = $1)
+AND (
+    (
+        visibility = 'visible'
+        AND NOT EXISTS (
+            SELECT 1 FROM users
+            WHERE users.id = chirps.user_id
+            AND users.state = 'shadow_banned'
+            AND (users.state_expires_at IS NULL OR users.state_expires_at > NOW())
+        )
+    )
+    OR user_id = $2
+)
+AND (created_at, id) > ($3, $4)
+ORDER BY created_at ASC, id ASC
+LIMIT $5
 `
 
-func (q *Queries) GetChirpsFromAuthor(ctx context.Context, userID uuid.NullUUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsFromAuthor, userID)
+type ListChirpsParams struct {
+	AuthorID       uuid.NullUUID
+	ViewerID       uuid.NullUUID
+	AfterCreatedAt time.Time
+	AfterID        uuid.UUID
+	MaxChirps      int32
+}
+
+func (q *Queries) ListChirps(ctx context.Context, arg ListChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirps,
+		arg.AuthorID,
+		arg.ViewerID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.MaxChirps,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -105,6 +165,7 @@ func (q *Queries) GetChirpsFromAuthor(ctx context.Context, userID uuid.NullUUID)
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Visibility,
 		); err != nil {
 			return nil, err
 		}
@@ -119,13 +180,104 @@ func (q *Queries) GetChirpsFromAuthor(ctx context.Context, userID uuid.NullUUID)
 	return items, nil
 }
 
-const getOneChirp = `-- name: GetOneChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE id = $1
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, visibility FROM chirps
+WHERE ($1 IS NULL OR user_id = $1)
+AND (
+    (
+        visibility = 'visible'
+        AND NOT EXISTS (
+            SELECT 1 FROM users
+            WHERE users.id = chirps.user_id
+            AND users.state = 'shadow_banned'
+            AND (users.state_expires_at IS NULL OR users.state_expires_at > NOW())
+        )
+    )
+    OR user_id = $2
+)
+AND (created_at, id) < ($3, $4)
+ORDER BY created_at DESC, id DESC
+LIMIT $5
 `
 
-func (q *Queries) GetOneChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getOneChirp, id)
+type ListChirpsDescParams struct {
+	AuthorID       uuid.NullUUID
+	ViewerID       uuid.NullUUID
+	AfterCreatedAt time.Time
+	AfterID        uuid.UUID
+	MaxChirps      int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.AuthorID,
+		arg.ViewerID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.MaxChirps,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setChirpVisibility = `-- name: SetChirpVisibility :exec
+UPDATE chirps
+SET visibility = $1,
+updated_at = NOW()
+WHERE id = $2
+`
+
+type SetChirpVisibilityParams struct {
+	Visibility string
+	ID         uuid.UUID
+}
+
+func (q *Queries) SetChirpVisibility(ctx context.Context, arg SetChirpVisibilityParams) error {
+	_, err := q.db.ExecContext(ctx, setChirpVisibility, arg.Visibility, arg.ID)
+	return err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1,
+visibility = $2,
+updated_at = NOW()
+WHERE id = $3
+RETURNING id, created_at, updated_at, body, user_id, visibility
+`
+
+type UpdateChirpBodyParams struct {
+	Body       string
+	Visibility string
+	ID         uuid.UUID
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.Body, arg.Visibility, arg.ID)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -133,6 +285,7 @@ func (q *Queries) GetOneChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Visibility,
 	)
 	return i, err
 }
@@ -6,17 +6,76 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AuditLog struct {
+	ID         int64
+	CreatedAt  time.Time
+	ActorID    uuid.NullUUID
+	ActorType  string
+	Action     string
+	TargetType string
+	TargetID   string
+	RequestID  string
+	Ip         string
+	Before     json.RawMessage
+	After      json.RawMessage
+}
+
 type Chirp struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Body       string
+	UserID     uuid.NullUUID
+	Visibility string
+}
+
+type Job struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Kind        string
+	Payload     json.RawMessage
+	Status      string
+	Attempts    int32
+	MaxAttempts int32
+	RunAt       time.Time
+	LastError   string
+}
+
+type ModerationDecision struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ChirpID    uuid.NullUUID
+	Body       string
+	Action     string
+	Score      float64
+	Reasons    string
+	Status     string
+	Resolution sql.NullString
+	ResolvedBy uuid.NullUUID
+	ResolvedAt sql.NullTime
+}
+
+type PolkaEvent struct {
+	ID            string
+	Event         string
+	ReceivedAt    time.Time
+	IgnoredReason sql.NullString
+}
+
+type RateLimit struct {
+	Key       string
+	Tokens    float64
+	Allowed   bool
 	UpdatedAt time.Time
-	Body      string
-	UserID    uuid.NullUUID
 }
 
 type RefreshToken struct {
@@ -28,6 +87,35 @@ type RefreshToken struct {
 	UserID    uuid.NullUUID
 }
 
+type SigningKey struct {
+	ID        string
+	CreatedAt time.Time
+	Secret    string
+	RetiredAt sql.NullTime
+}
+
+type Subscription struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	UserID           uuid.UUID
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+	CancelAt         sql.NullTime
+}
+
+type SubscriptionEvent struct {
+	ID               int64
+	CreatedAt        time.Time
+	SubscriptionID   uuid.UUID
+	Event            string
+	Status           string
+	CurrentPeriodEnd time.Time
+	CancelAt         sql.NullTime
+	SourceEventID    sql.NullString
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
@@ -35,4 +123,40 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    sql.NullBool
+	IsAdmin        bool
+	State          string
+	StateReason    string
+	StateExpiresAt sql.NullTime
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	EndpointID    uuid.UUID
+	EventType     string
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
+	EventID       uuid.UUID
+}
+
+type WebhookDeliveryAttempt struct {
+	ID             int64
+	CreatedAt      time.Time
+	DeliveryID     uuid.UUID
+	ResponseStatus sql.NullInt32
+	Error          string
+	DurationMs     int64
+}
+
+type WebhookEndpoint struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Url        string
+	Secret     string
+	EventTypes string
 }
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
@@ -20,7 +21,7 @@ VALUES (
     $1, 
     $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_reason, state_expires_at
 `
 
 type CreateUserParams struct {
@@ -38,12 +39,16 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateReason,
+		&i.StateExpiresAt,
 	)
 	return i, err
 }
 
 const getUserFromEmail = `-- name: GetUserFromEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_reason, state_expires_at FROM users
 WHERE email = $1
 `
 
@@ -57,6 +62,33 @@ func (q *Queries) GetUserFromEmail(ctx context.Context, email string) (User, err
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateReason,
+		&i.StateExpiresAt,
+	)
+	return i, err
+}
+
+const getUserFromID = `-- name: GetUserFromID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_reason, state_expires_at FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserFromID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserFromID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateReason,
+		&i.StateExpiresAt,
 	)
 	return i, err
 }
@@ -70,22 +102,61 @@ func (q *Queries) Reset(ctx context.Context) error {
 	return err
 }
 
-const updatePasswordAndEmail = `-- name: UpdatePasswordAndEmail :one
+const setUserAdmin = `-- name: SetUserAdmin :one
 UPDATE users
-SET hashed_password = $1,
-email = $2
-WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SET is_admin = $1,
+updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_reason, state_expires_at
 `
 
-type UpdatePasswordAndEmailParams struct {
-	HashedPassword string
-	Email          string
+type SetUserAdminParams struct {
+	IsAdmin bool
+	ID      uuid.UUID
+}
+
+func (q *Queries) SetUserAdmin(ctx context.Context, arg SetUserAdminParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserAdmin, arg.IsAdmin, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateReason,
+		&i.StateExpiresAt,
+	)
+	return i, err
+}
+
+const setUserState = `-- name: SetUserState :one
+UPDATE users
+SET state = $1,
+state_reason = $2,
+state_expires_at = $3,
+updated_at = NOW()
+WHERE id = $4
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_reason, state_expires_at
+`
+
+type SetUserStateParams struct {
+	State          string
+	StateReason    string
+	StateExpiresAt sql.NullTime
 	ID             uuid.UUID
 }
 
-func (q *Queries) UpdatePasswordAndEmail(ctx context.Context, arg UpdatePasswordAndEmailParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updatePasswordAndEmail, arg.HashedPassword, arg.Email, arg.ID)
+func (q *Queries) SetUserState(ctx context.Context, arg SetUserStateParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserState,
+		arg.State,
+		arg.StateReason,
+		arg.StateExpiresAt,
+		arg.ID,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -94,19 +165,30 @@ func (q *Queries) UpdatePasswordAndEmail(ctx context.Context, arg UpdatePassword
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateReason,
+		&i.StateExpiresAt,
 	)
 	return i, err
 }
 
-const upgradeUserToRed = `-- name: UpgradeUserToRed :one
+const updatePasswordAndEmail = `-- name: UpdatePasswordAndEmail :one
 UPDATE users
-SET is_chirpy_red = True
-WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SET hashed_password = $1,
+email = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_reason, state_expires_at
 `
 
-func (q *Queries) UpgradeUserToRed(ctx context.Context, id uuid.UUID) (User, error) {
-	row := q.db.QueryRowContext(ctx, upgradeUserToRed, id)
+type UpdatePasswordAndEmailParams struct {
+	HashedPassword string
+	Email          string
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdatePasswordAndEmail(ctx context.Context, arg UpdatePasswordAndEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updatePasswordAndEmail, arg.HashedPassword, arg.Email, arg.ID)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -115,6 +197,10 @@ func (q *Queries) UpgradeUserToRed(ctx context.Context, id uuid.UUID) (User, err
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateReason,
+		&i.StateExpiresAt,
 	)
 	return i, err
 }
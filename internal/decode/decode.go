@@ -0,0 +1,114 @@
+// Package decode reads JSON request bodies strictly: the body must be
+// declared as JSON, fit in MaxBodyBytes, hold exactly one value with no
+// fields the destination doesn't have, and pass the destination's validate
+// tags. Every failure is an *apierror.Error with a 400, 413 or 415 status.
+package decode
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/jamistoso/chirpy/internal/apierror"
+)
+
+// MaxBodyBytes is the largest request body JSON reads. Chirpy's requests are
+// a handful of short fields.
+const MaxBodyBytes = 64 << 10
+
+// JSON decodes the body of r into dst, a pointer to a struct, and validates
+// it.
+func JSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	err := RequireJSON(r)
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	if err != nil {
+		return apierror.InvalidJSON(err)
+	}
+	return Unmarshal(body, dst)
+}
+
+// RequireJSON checks that r declares a JSON body.
+func RequireJSON(r *http.Request) error {
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return apierror.New(415, apierror.CodeUnsupportedMediaType, "request body must be application/json")
+	}
+	return nil
+}
+
+// Unmarshal decodes data into dst, a pointer to a struct, and validates it.
+func Unmarshal(data []byte, dst any) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &apierror.Error{
+			Status: 400,
+			Code:   apierror.CodeInvalidJSON,
+			Detail: "request body is empty",
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(dst)
+	if err != nil {
+		return decodeError(err)
+	}
+	if decoder.More() {
+		return &apierror.Error{
+			Status: 400,
+			Code:   apierror.CodeInvalidJSON,
+			Detail: "request body must hold a single JSON value",
+		}
+	}
+	return Validate(dst)
+}
+
+// decodeError explains err in terms of the request's fields where it can.
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		message := "must be " + jsonType(typeErr.Type.Kind().String())
+		return &apierror.Error{
+			Status: 400,
+			Code:   apierror.CodeInvalidJSON,
+			Detail: typeErr.Field + ": " + message,
+			Fields: []apierror.FieldError{{Field: typeErr.Field, Message: message}},
+			Err:    err,
+		}
+	}
+	// encoding/json has no type for unknown fields.
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field = strings.Trim(field, `"`)
+		return &apierror.Error{
+			Status: 400,
+			Code:   apierror.CodeInvalidJSON,
+			Detail: fmt.Sprintf("unknown field %q", field),
+			Fields: []apierror.FieldError{{Field: field, Message: "is not a known field"}},
+			Err:    err,
+		}
+	}
+	return apierror.InvalidJSON(err)
+}
+
+// jsonType names a Go kind the way a JSON client thinks of it.
+func jsonType(kind string) string {
+	switch kind {
+	case "string":
+		return "a string"
+	case "bool":
+		return "a boolean"
+	case "slice", "array":
+		return "an array"
+	case "struct", "map":
+		return "an object"
+	}
+	return "a number"
+}
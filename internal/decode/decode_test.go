@@ -0,0 +1,109 @@
+package decode
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamistoso/chirpy/internal/apierror"
+)
+
+type signup struct {
+	Email    string   `json:"email" validate:"required,email"`
+	Password string   `json:"password" validate:"required,min=8,maxbytes=72"`
+	Nickname string   `json:"nickname" validate:"max=5"`
+	Plan     string   `json:"plan" validate:"oneof=free red"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Address  struct {
+		City string `json:"city" validate:"required"`
+	} `json:"address"`
+	Until *time.Time `json:"until"`
+}
+
+func decodeRequest(t *testing.T, contentType, body string) (signup, *apierror.Error) {
+	t.Helper()
+	rq := httptest.NewRequest("POST", "/api/users", strings.NewReader(body))
+	if contentType != "" {
+		rq.Header.Set("Content-Type", contentType)
+	}
+	var dst signup
+	err := JSON(httptest.NewRecorder(), rq, &dst)
+	if err == nil {
+		return dst, nil
+	}
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf(`JSON() = %v, wanted an *apierror.Error`, err)
+	}
+	return dst, apiErr
+}
+
+func TestJSON(t *testing.T) {
+	got, err := decodeRequest(t, "application/json; charset=utf-8",
+		`{"email":"walt@example.com","password":"hunter2hunter2","nickname":"wåltz","plan":"red","address":{"city":"Albuquerque"}}`)
+	if err != nil {
+		t.Fatalf(`JSON() = %v, wanted nil`, err)
+	}
+	if got.Email != "walt@example.com" || got.Address.City != "Albuquerque" {
+		t.Fatalf(`JSON() decoded %+v`, got)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	valid := `"email":"walt@example.com","password":"hunter2hunter2","address":{"city":"Albuquerque"}`
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+		fields      []apierror.FieldError
+	}{
+		{"no content type", "", `{` + valid + `}`, 415, apierror.CodeUnsupportedMediaType, nil},
+		{"form content type", "application/x-www-form-urlencoded", `{` + valid + `}`, 415, apierror.CodeUnsupportedMediaType, nil},
+		{"empty body", "application/json", ``, 400, apierror.CodeInvalidJSON, nil},
+		{"malformed", "application/json", `{"email":`, 400, apierror.CodeInvalidJSON, nil},
+		{"two values", "application/json", `{` + valid + `}{}`, 400, apierror.CodeInvalidJSON, nil},
+		{"too large", "application/json", `{"nickname":"` + strings.Repeat("a", MaxBodyBytes) + `"}`, 413, apierror.CodeBodyTooLarge, nil},
+		{"unknown field", "application/json", `{` + valid + `,"admin":true}`, 400, apierror.CodeInvalidJSON,
+			[]apierror.FieldError{{Field: "admin", Message: "is not a known field"}}},
+		{"wrong type", "application/json", `{"email":42}`, 400, apierror.CodeInvalidJSON,
+			[]apierror.FieldError{{Field: "email", Message: "must be a string"}}},
+		{"missing fields", "application/json", `{"address":{}}`, 400, apierror.CodeValidationFailed, []apierror.FieldError{
+			{Field: "email", Message: "is required"},
+			{Field: "password", Message: "is required"},
+			{Field: "address.city", Message: "is required"},
+		}},
+		{"blank email", "application/json", `{"email":"  ","password":"hunter2hunter2","address":{"city":"x"}}`, 400, apierror.CodeValidationFailed,
+			[]apierror.FieldError{{Field: "email", Message: "is required"}}},
+		{"rule failures", "application/json",
+			`{"email":"Walt <walt@example.com>","password":"short","nickname":"wåltzing","plan":"gold","tags":["a","b","c"],"address":{"city":"x"}}`,
+			400, apierror.CodeValidationFailed, []apierror.FieldError{
+				{Field: "email", Message: "must be an email address"},
+				{Field: "password", Message: "must be at least 8 characters"},
+				{Field: "nickname", Message: "must be at most 5 characters"},
+				{Field: "plan", Message: "must be one of free, red"},
+				{Field: "tags", Message: "must be at most 2 items"},
+			}},
+		{"password too long for bcrypt", "application/json",
+			`{"email":"walt@example.com","password":"` + strings.Repeat("é", 40) + `","address":{"city":"x"}}`,
+			400, apierror.CodeValidationFailed, []apierror.FieldError{{Field: "password", Message: "must be at most 72 bytes"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeRequest(t, tt.contentType, tt.body)
+			if err == nil {
+				t.Fatalf(`JSON() = nil, wanted %d %s`, tt.status, tt.code)
+			}
+			if err.Status != tt.status || err.Code != tt.code {
+				t.Fatalf(`JSON() = %d %s (%v), wanted %d %s`, err.Status, err.Code, err, tt.status, tt.code)
+			}
+			if tt.fields != nil && !reflect.DeepEqual(err.Fields, tt.fields) {
+				t.Fatalf(`JSON() fields = %+v, wanted %+v`, err.Fields, tt.fields)
+			}
+		})
+	}
+}
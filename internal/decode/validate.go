@@ -0,0 +1,144 @@
+package decode
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jamistoso/chirpy/internal/apierror"
+)
+
+// Validate checks the validate tags of v, a struct or a pointer to one, and
+// of the structs nested in it. Fields are named after their JSON keys. The
+// rules, separated by commas:
+//
+//	required      not the zero value; for slices, not empty
+//	email         a bare address, like a@example.com
+//	min=N, max=N  length in runes for strings, in elements for slices
+//	maxbytes=N    length in bytes for strings
+//	oneof=a b c   one of the listed strings
+//
+// Rules other than required pass for empty values, so that optional fields
+// only need checking when present.
+func Validate(v any) error {
+	var fields []apierror.FieldError
+	validateStruct(reflect.Indirect(reflect.ValueOf(v)), "", &fields)
+	if len(fields) > 0 {
+		return apierror.Validation(fields...)
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, fields *[]apierror.FieldError) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+		value := v.Field(i)
+
+		for _, rule := range splitRules(field.Tag.Get("validate")) {
+			message := check(rule, value)
+			if message != "" {
+				*fields = append(*fields, apierror.FieldError{Field: prefix + name, Message: message})
+				// One problem per field is enough to act on.
+				break
+			}
+		}
+
+		// Nested structs are checked too. Those without exported fields,
+		// like time.Time, have nothing to check.
+		if value.Kind() == reflect.Pointer && !value.IsNil() {
+			value = value.Elem()
+		}
+		validateStruct(value, prefix+name+".", fields)
+	}
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func splitRules(tag string) []string {
+	if tag == "" {
+		return nil
+	}
+	return strings.Split(tag, ",")
+}
+
+// check returns what is wrong with value under rule, or "" if nothing is.
+func check(rule string, value reflect.Value) string {
+	name, arg, _ := strings.Cut(rule, "=")
+	if name == "required" {
+		if value.IsZero() || (value.Kind() == reflect.Slice && value.Len() == 0) {
+			return "is required"
+		}
+		if value.Kind() == reflect.String && strings.TrimSpace(value.String()) == "" {
+			return "is required"
+		}
+		return ""
+	}
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+	if value.IsZero() {
+		return ""
+	}
+
+	switch name {
+	case "email":
+		address, err := mail.ParseAddress(value.String())
+		if err != nil || address.Address != value.String() {
+			return "must be an email address"
+		}
+	case "min", "max", "maxbytes":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			panic(fmt.Sprintf("decode: bad validate rule %q", rule))
+		}
+		length := value.Len()
+		unit := "characters"
+		switch {
+		case value.Kind() == reflect.String && name != "maxbytes":
+			length = utf8.RuneCountInString(value.String())
+		case value.Kind() == reflect.String:
+			unit = "bytes"
+		default:
+			unit = "items"
+		}
+		if name == "min" && length < n {
+			return fmt.Sprintf("must be at least %d %s", n, unit)
+		}
+		if name != "min" && length > n {
+			return fmt.Sprintf("must be at most %d %s", n, unit)
+		}
+	case "oneof":
+		options := strings.Fields(arg)
+		for _, option := range options {
+			if value.String() == option {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(options, ", ")
+	default:
+		panic(fmt.Sprintf("decode: unknown validate rule %q", rule))
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package entitlements
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	PlanFree = "free"
+	PlanRed  = "red"
+)
+
+// Limits are the features and quotas a plan grants.
+type Limits struct {
+	MaxChirpLength      int     `json:"max_chirp_length"`
+	CanEditChirps       bool    `json:"can_edit_chirps"`
+	RateLimitMultiplier float64 `json:"rate_limit_multiplier"`
+}
+
+type Config struct {
+	Plans map[string]Limits
+}
+
+func Default() Config {
+	return Config{
+		Plans: map[string]Limits{
+			PlanFree: {
+				MaxChirpLength:      140,
+				CanEditChirps:       false,
+				RateLimitMultiplier: 1,
+			},
+			PlanRed: {
+				MaxChirpLength:      280,
+				CanEditChirps:       true,
+				RateLimitMultiplier: 3,
+			},
+		},
+	}
+}
+
+// For returns the limits of plan. Unknown plans get the free tier, so a typo
+// in config can't hand out paid features.
+func (c Config) For(plan string) Limits {
+	if limits, ok := c.Plans[plan]; ok {
+		return limits
+	}
+	return c.Plans[PlanFree]
+}
+
+func (c Config) Has(plan string) bool {
+	_, ok := c.Plans[plan]
+	return ok
+}
+
+// Load reads plan limits from a JSON file shaped like
+//
+//	{"plans": {"red": {"max_chirp_length": 500}, "red_yearly": {...}}}
+//
+// Fields left out keep their default for known plans; new plans start from
+// the free tier.
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return Parse(data)
+}
+
+func Parse(data []byte) (Config, error) {
+	var file struct {
+		Plans map[string]json.RawMessage `json:"plans"`
+	}
+	err := json.Unmarshal(data, &file)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid entitlements config: %w", err)
+	}
+
+	cfg := Default()
+	for plan, raw := range file.Plans {
+		limits := cfg.For(plan)
+		err := json.Unmarshal(raw, &limits)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid limits for plan %q: %w", plan, err)
+		}
+		if limits.MaxChirpLength <= 0 {
+			return Config{}, fmt.Errorf("plan %q: max_chirp_length must be positive", plan)
+		}
+		if limits.RateLimitMultiplier <= 0 {
+			return Config{}, fmt.Errorf("plan %q: rate_limit_multiplier must be positive", plan)
+		}
+		cfg.Plans[plan] = limits
+	}
+	return cfg, nil
+}
@@ -0,0 +1,41 @@
+package entitlements
+
+import (
+	"testing"
+)
+
+func TestParseOverridesDefaults(t *testing.T) {
+	cfg, err := Parse([]byte(`{"plans": {"red": {"max_chirp_length": 500}, "red_yearly": {"can_edit_chirps": true}}}`))
+	if err != nil {
+		t.Fatalf(`Parse() = %v, wanted nil`, err)
+	}
+
+	red := cfg.For(PlanRed)
+	if red.MaxChirpLength != 500 || !red.CanEditChirps || red.RateLimitMultiplier != 3 {
+		t.Fatalf(`For("red") = %+v, wanted length 500 with other defaults kept`, red)
+	}
+
+	yearly := cfg.For("red_yearly")
+	if yearly.MaxChirpLength != 140 || !yearly.CanEditChirps {
+		t.Fatalf(`For("red_yearly") = %+v, wanted free tier plus editing`, yearly)
+	}
+}
+
+func TestUnknownPlanGetsFreeTier(t *testing.T) {
+	limits := Default().For("platinum")
+	if limits != Default().For(PlanFree) {
+		t.Fatalf(`For("platinum") = %+v, wanted free tier`, limits)
+	}
+}
+
+func TestParseRejectsInvalidLimits(t *testing.T) {
+	for _, data := range []string{
+		`{"plans": {"red": {"max_chirp_length": 0}}}`,
+		`{"plans": {"red": {"rate_limit_multiplier": -1}}}`,
+		`{"plans": []}`,
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Fatalf(`Parse(%s) = nil, wanted error`, data)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package health runs the dependency checks behind chirpy's readiness
+// probe. Results are cached for a while so that however often probes come,
+// the database sees at most one round of checks per TTL.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jamistoso/chirpy/internal/logging"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+	// StatusDegraded is the status of a report in which only informational
+	// checks failed. The instance is still ready.
+	StatusDegraded = "degraded"
+)
+
+// A Check returns nil when what it checks is usable. It should give up when
+// ctx is done.
+type Check func(ctx context.Context) error
+
+// Result is how a check went. Probes are unauthenticated, so Error only says
+// whether the check failed or timed out; why is logged.
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status    string            `json:"status"`
+	CheckedAt time.Time         `json:"checked_at"`
+	Checks    map[string]Result `json:"checks"`
+}
+
+// Healthy reports whether every check that isn't informational passed.
+func (r Report) Healthy() bool {
+	return r.Status != StatusFail
+}
+
+type check struct {
+	name          string
+	timeout       time.Duration
+	fn            Check
+	informational bool
+}
+
+// Checker is a registry of checks. Register them all before the first Run.
+type Checker struct {
+	ttl    time.Duration
+	checks []check
+	now    func() time.Time
+
+	// mu is held while the checks run, so that concurrent probes wait for
+	// one run and share its report.
+	mu     sync.Mutex
+	report Report
+}
+
+// New returns a checker that reuses a report for ttl.
+func New(ttl time.Duration) *Checker {
+	return &Checker{ttl: ttl, now: time.Now}
+}
+
+// Register adds a check, which fails if it takes longer than timeout.
+func (c *Checker) Register(name string, timeout time.Duration, fn Check) {
+	c.checks = append(c.checks, check{name: name, timeout: timeout, fn: fn})
+}
+
+// RegisterInformational adds a check whose failure only degrades the
+// report, for what is worth watching but isn't this instance's to fix.
+func (c *Checker) RegisterInformational(name string, timeout time.Duration, fn Check) {
+	c.checks = append(c.checks, check{name: name, timeout: timeout, fn: fn, informational: true})
+}
+
+// Run returns the latest report, running every check, concurrently, when it
+// is older than the TTL. The report is healthy when all checks that aren't
+// informational pass.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.report.CheckedAt.IsZero() && c.now().Sub(c.report.CheckedAt) < c.ttl {
+		return c.report
+	}
+
+	// The report is shared, so one probe hanging up mustn't fail it for
+	// the others.
+	ctx = context.WithoutCancel(ctx)
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, chk := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, chk)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:    StatusOK,
+		CheckedAt: c.now(),
+		Checks:    make(map[string]Result, len(c.checks)),
+	}
+	for i, chk := range c.checks {
+		switch {
+		case results[i].Status == StatusOK:
+		case chk.informational:
+			if report.Status == StatusOK {
+				report.Status = StatusDegraded
+			}
+		default:
+			report.Status = StatusFail
+		}
+		report.Checks[chk.name] = results[i]
+	}
+	c.report = report
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, chk check) Result {
+	ctx, cancel := context.WithTimeout(ctx, chk.timeout)
+	defer cancel()
+
+	start := c.now()
+	err := chk.fn(ctx)
+	result := Result{
+		Status:    StatusOK,
+		LatencyMs: float64(c.now().Sub(start).Microseconds()) / 1000,
+	}
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = "check failed"
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = "timed out"
+		}
+		logging.FromContext(ctx).Warn("health check failed", "check", chk.name, "err", err)
+	}
+	return result
+}
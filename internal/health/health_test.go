@@ -0,0 +1,105 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunReportsEachCheck(t *testing.T) {
+	c := New(time.Second)
+	c.Register("database", time.Second, func(ctx context.Context) error { return nil })
+	c.Register("jobs", time.Second, func(ctx context.Context) error { return errors.New("queue is 10m behind") })
+
+	report := c.Run(context.Background())
+	if report.Healthy() {
+		t.Fatalf(`Run().Status = %q, wanted a failure`, report.Status)
+	}
+	if got := report.Checks["database"]; got.Status != StatusOK || got.Error != "" {
+		t.Fatalf(`database result = %+v, wanted ok`, got)
+	}
+	// The reason is logged rather than shown to whoever probes.
+	if got := report.Checks["jobs"]; got.Status != StatusFail || got.Error != "check failed" {
+		t.Fatalf(`jobs result = %+v, wanted a failure without its reason`, got)
+	}
+}
+
+func TestInformationalChecksDegrade(t *testing.T) {
+	c := New(time.Second)
+	c.Register("database", time.Second, func(ctx context.Context) error { return nil })
+	c.RegisterInformational("jobs", time.Second, func(ctx context.Context) error { return errors.New("queue is 10m behind") })
+
+	report := c.Run(context.Background())
+	if !report.Healthy() || report.Status != StatusDegraded {
+		t.Fatalf(`Run().Status = %q, wanted %q and healthy`, report.Status, StatusDegraded)
+	}
+	if got := report.Checks["jobs"]; got.Status != StatusFail {
+		t.Fatalf(`jobs result = %+v, wanted a failure`, got)
+	}
+}
+
+func TestRunCachesReport(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New(5 * time.Second)
+	c.now = func() time.Time { return now }
+	var calls atomic.Int32
+	c.Register("database", time.Second, func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+
+	c.Run(context.Background())
+	now = now.Add(4 * time.Second)
+	c.Run(context.Background())
+	if n := calls.Load(); n != 1 {
+		t.Fatalf(`check ran %d times within the TTL, wanted 1`, n)
+	}
+	now = now.Add(time.Second)
+	c.Run(context.Background())
+	if n := calls.Load(); n != 2 {
+		t.Fatalf(`check ran %d times after the TTL, wanted 2`, n)
+	}
+}
+
+func TestConcurrentProbesShareARun(t *testing.T) {
+	c := New(time.Minute)
+	var calls atomic.Int32
+	c.Register("database", time.Second, func(ctx context.Context) error {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Run(context.Background())
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf(`check ran %d times for concurrent probes, wanted 1`, n)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	c := New(time.Minute)
+	c.Register("database", 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	// A probe that hangs up doesn't cut the checks short.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := c.Run(ctx)
+	got := report.Checks["database"]
+	if got.Status != StatusFail || got.Error != "timed out" {
+		t.Fatalf(`database result = %+v, wanted a timeout`, got)
+	}
+}
@@ -0,0 +1,42 @@
+// Package httpx holds HTTP helpers shared by chirpy's middlewares.
+package httpx
+
+import "net/http"
+
+// ResponseRecorder remembers the status and size of the response written
+// through it.
+type ResponseRecorder struct {
+	http.ResponseWriter
+	Status      int
+	Bytes       int64
+	wroteHeader bool
+}
+
+// Record returns a recorder for w. When w already is one, an outer
+// middleware is recording this response and the same recorder is shared.
+func Record(w http.ResponseWriter) *ResponseRecorder {
+	if recorder, ok := w.(*ResponseRecorder); ok {
+		return recorder
+	}
+	return &ResponseRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *ResponseRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.Status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *ResponseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.Bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,99 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jamistoso/chirpy/internal/database"
+)
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+const (
+	DefaultMaxAttempts = 5
+
+	baseBackoff = 10 * time.Second
+	maxBackoff  = time.Hour
+)
+
+// Kind names a type of job and ties it to its payload type, so Enqueue and
+// Register can't disagree about what a job carries.
+type Kind[T any] string
+
+// Enqueuer is satisfied by *database.Queries, including one bound to a
+// transaction with WithTx. Enqueueing through a transaction makes the job
+// part of it: it only runs if the transaction commits.
+type Enqueuer interface {
+	EnqueueJob(ctx context.Context, arg database.EnqueueJobParams) (database.Job, error)
+}
+
+type Option func(*database.EnqueueJobParams)
+
+// RunAt schedules the job for t instead of straight away.
+func RunAt(t time.Time) Option {
+	return func(p *database.EnqueueJobParams) {
+		p.RunAt = t
+	}
+}
+
+// MaxAttempts overrides DefaultMaxAttempts.
+func MaxAttempts(n int) Option {
+	return func(p *database.EnqueueJobParams) {
+		p.MaxAttempts = int32(n)
+	}
+}
+
+func Enqueue[T any](ctx context.Context, store Enqueuer, kind Kind[T], payload T, opts ...Option) (database.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return database.Job{}, err
+	}
+	params := database.EnqueueJobParams{
+		Kind:        string(kind),
+		Payload:     data,
+		MaxAttempts: DefaultMaxAttempts,
+		RunAt:       time.Now(),
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	return store.EnqueueJob(ctx, params)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying; the job is dead lettered
+// straight away.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Backoff is how long to wait before retrying after the given number of
+// failed attempts: 10s doubling each time, capped at an hour.
+func Backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,236 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/database"
+)
+
+// fakeStore mirrors the jobs queries in memory.
+type fakeStore struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*database.Job
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{jobs: map[uuid.UUID]*database.Job{}}
+}
+
+func (s *fakeStore) EnqueueJob(ctx context.Context, arg database.EnqueueJobParams) (database.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := database.Job{
+		ID:          uuid.New(),
+		Kind:        arg.Kind,
+		Payload:     arg.Payload,
+		Status:      StatusQueued,
+		MaxAttempts: arg.MaxAttempts,
+		RunAt:       arg.RunAt,
+	}
+	s.jobs[job.ID] = &job
+	return job, nil
+}
+
+func (s *fakeStore) ClaimJobs(ctx context.Context, arg database.ClaimJobsParams) ([]database.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*database.Job
+	for _, job := range s.jobs {
+		if (job.Status == StatusQueued || job.Status == StatusRunning) && !job.RunAt.After(arg.Now) {
+			due = append(due, job)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].RunAt.Before(due[j].RunAt) })
+	var claimed []database.Job
+	for _, job := range due {
+		if len(claimed) == int(arg.MaxJobs) {
+			break
+		}
+		job.Status = StatusRunning
+		job.Attempts++
+		job.RunAt = arg.LeaseUntil
+		claimed = append(claimed, *job)
+	}
+	return claimed, nil
+}
+
+func (s *fakeStore) FinishJob(ctx context.Context, arg database.FinishJobParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[arg.ID]
+	job.Status = arg.Status
+	job.RunAt = arg.RunAt
+	job.LastError = arg.LastError
+	return nil
+}
+
+func (s *fakeStore) DeleteFinishedJobs(ctx context.Context, updatedAt time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeStore) get(id uuid.UUID) database.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.jobs[id]
+}
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+const kindGreet Kind[greeting] = "greet"
+
+func TestEnqueueAndRun(t *testing.T) {
+	store := newFakeStore()
+	runner := NewRunner(store)
+	var got string
+	Register(runner, kindGreet, func(ctx context.Context, g greeting) error {
+		got = g.Name
+		return nil
+	})
+
+	job, err := Enqueue(context.Background(), store, kindGreet, greeting{Name: "chirpy"})
+	if err != nil {
+		t.Fatalf(`Enqueue() = %v, wanted nil`, err)
+	}
+	n, err := runner.RunOnce(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf(`RunOnce() = %d, %v, wanted 1, nil`, n, err)
+	}
+	if got != "chirpy" || store.get(job.ID).Status != StatusSucceeded {
+		t.Fatalf(`handler got %q, job %s, wanted "chirpy" and succeeded`, got, store.get(job.ID).Status)
+	}
+}
+
+func TestScheduledJobWaits(t *testing.T) {
+	store := newFakeStore()
+	runner := NewRunner(store)
+	Register(runner, kindGreet, func(ctx context.Context, g greeting) error { return nil })
+
+	Enqueue(context.Background(), store, kindGreet, greeting{}, RunAt(time.Now().Add(time.Hour)))
+	if n, _ := runner.RunOnce(context.Background()); n != 0 {
+		t.Fatalf(`RunOnce() = %d, wanted 0 before run_at`, n)
+	}
+}
+
+func TestRetryThenDead(t *testing.T) {
+	store := newFakeStore()
+	runner := NewRunner(store)
+	now := time.Now()
+	runner.now = func() time.Time { return now }
+	Register(runner, kindGreet, func(ctx context.Context, g greeting) error {
+		return errors.New("smtp unavailable")
+	})
+
+	job, _ := Enqueue(context.Background(), store, kindGreet, greeting{}, RunAt(now), MaxAttempts(2))
+	runner.RunOnce(context.Background())
+	got := store.get(job.ID)
+	if got.Status != StatusQueued || !got.RunAt.Equal(now.Add(Backoff(1))) || got.LastError != "smtp unavailable" {
+		t.Fatalf(`after first failure job = %+v, wanted queued for retry`, got)
+	}
+
+	now = now.Add(Backoff(1))
+	runner.RunOnce(context.Background())
+	if got := store.get(job.ID); got.Status != StatusDead || got.Attempts != 2 {
+		t.Fatalf(`after second failure job = %s after %d attempts, wanted dead after 2`, got.Status, got.Attempts)
+	}
+}
+
+func TestPermanentFailures(t *testing.T) {
+	store := newFakeStore()
+	runner := NewRunner(store)
+	Register(runner, kindGreet, func(ctx context.Context, g greeting) error {
+		return Permanent(errors.New("no such user"))
+	})
+
+	failing, _ := Enqueue(context.Background(), store, kindGreet, greeting{})
+	unknown, _ := Enqueue(context.Background(), store, Kind[greeting]("unknown"), greeting{})
+	undecodable, _ := store.EnqueueJob(context.Background(), database.EnqueueJobParams{
+		Kind:        "greet",
+		Payload:     []byte(`"not an object"`),
+		MaxAttempts: DefaultMaxAttempts,
+		RunAt:       time.Now(),
+	})
+	runner.RunOnce(context.Background())
+
+	for _, id := range []uuid.UUID{failing.ID, unknown.ID, undecodable.ID} {
+		if got := store.get(id); got.Status != StatusDead || got.Attempts != 1 {
+			t.Fatalf(`job %s = %s after %d attempts (%s), wanted dead after 1`, got.Kind, got.Status, got.Attempts, got.LastError)
+		}
+	}
+}
+
+func TestConcurrencyAndDrain(t *testing.T) {
+	store := newFakeStore()
+	runner := NewRunner(store)
+	runner.Concurrency = 2
+	runner.PollInterval = 10 * time.Millisecond
+
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	Register(runner, kindGreet, func(ctx context.Context, g greeting) error {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		running.Add(-1)
+		return nil
+	})
+
+	var ids []uuid.UUID
+	for i := 0; i < 3; i++ {
+		job, _ := Enqueue(context.Background(), store, kindGreet, greeting{})
+		ids = append(ids, job.ID)
+	}
+
+	runner.Start()
+	time.Sleep(50 * time.Millisecond)
+	if peak.Load() != 2 {
+		t.Fatalf(`peak concurrency = %d, wanted 2`, peak.Load())
+	}
+
+	stopped := make(chan error)
+	go func() { stopped <- runner.Stop(context.Background()) }()
+	<-runner.loopDone
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatalf(`Stop() = %v, wanted nil`, err)
+	}
+
+	succeeded := 0
+	for _, id := range ids {
+		if store.get(id).Status == StatusSucceeded {
+			succeeded++
+		}
+	}
+	if succeeded != 2 {
+		t.Fatalf(`%d jobs succeeded, wanted the 2 in flight at Stop`, succeeded)
+	}
+}
+
+func TestStopIsSafe(t *testing.T) {
+	runner := NewRunner(newFakeStore())
+	if err := runner.Stop(context.Background()); err != nil {
+		t.Fatalf(`Stop() before Start() = %v, wanted nil`, err)
+	}
+
+	runner.PollInterval = 10 * time.Millisecond
+	runner.Start()
+	runner.Start()
+	for i := 0; i < 2; i++ {
+		if err := runner.Stop(context.Background()); err != nil {
+			t.Fatalf(`Stop() #%d = %v, wanted nil`, i+1, err)
+		}
+	}
+}
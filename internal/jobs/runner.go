@@ -0,0 +1,239 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/logging"
+)
+
+type Store interface {
+	ClaimJobs(ctx context.Context, arg database.ClaimJobsParams) ([]database.Job, error)
+	FinishJob(ctx context.Context, arg database.FinishJobParams) error
+	DeleteFinishedJobs(ctx context.Context, updatedAt time.Time) (int64, error)
+}
+
+type handler func(ctx context.Context, payload json.RawMessage) error
+
+// Runner claims due jobs and runs them on up to Concurrency goroutines.
+//
+// A claimed job is leased until now+Lease: its handler's context expires then,
+// and if the runner dies mid-job another runner picks it up once the lease
+// has passed. Retention is how long succeeded jobs are kept.
+type Runner struct {
+	Store        Store
+	Concurrency  int
+	PollInterval time.Duration
+	Lease        time.Duration
+	Retention    time.Duration
+
+	handlers map[string]handler
+	now      func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	loopDone chan struct{}
+	cancel   context.CancelFunc
+	running  sync.WaitGroup
+}
+
+func NewRunner(store Store) *Runner {
+	return &Runner{
+		Store:        store,
+		Concurrency:  4,
+		PollInterval: time.Second,
+		Lease:        5 * time.Minute,
+		Retention:    24 * time.Hour,
+		handlers:     map[string]handler{},
+		now:          time.Now,
+	}
+}
+
+// Register sets fn as the handler for kind. A payload that doesn't decode is
+// a permanent failure.
+func Register[T any](r *Runner, kind Kind[T], fn func(ctx context.Context, payload T) error) {
+	r.handlers[string(kind)] = func(ctx context.Context, raw json.RawMessage) error {
+		var payload T
+		err := json.Unmarshal(raw, &payload)
+		if err != nil {
+			return Permanent(fmt.Errorf("decoding payload: %w", err))
+		}
+		return fn(ctx, payload)
+	}
+}
+
+// Start polls for jobs in the background until Stop is called. Starting a
+// runner again does nothing.
+func (r *Runner) Start() {
+	if r.stop != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.stop = make(chan struct{})
+	r.loopDone = make(chan struct{})
+	go r.loop(ctx)
+}
+
+// Stop stops claiming new jobs and waits for running ones to finish. If ctx
+// ends first, running jobs have their contexts cancelled and Stop returns
+// ctx's error once they have returned; their leases let them run again later.
+// Stopping a runner that was never started, or was already stopped, returns
+// nil straight away.
+func (r *Runner) Stop(ctx context.Context) error {
+	if r.stop == nil {
+		return nil
+	}
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.loopDone
+
+	drained := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		r.cancel()
+		return nil
+	case <-ctx.Done():
+		r.cancel()
+		<-drained
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) loop(ctx context.Context) {
+	defer close(r.loopDone)
+
+	slots := make(chan struct{}, r.Concurrency)
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	lastCleanup := r.now()
+
+	for {
+		free := cap(slots) - len(slots)
+		if free > 0 {
+			claimed, err := r.claim(ctx, free)
+			if err != nil {
+				slog.Error("error claiming jobs", "err", err)
+			}
+			for _, job := range claimed {
+				slots <- struct{}{}
+				r.running.Add(1)
+				go func(job database.Job) {
+					defer func() {
+						<-slots
+						r.running.Done()
+					}()
+					r.run(ctx, job)
+				}(job)
+			}
+			// A full batch means there is probably more waiting.
+			if len(claimed) == free {
+				select {
+				case <-r.stop:
+					return
+				default:
+					continue
+				}
+			}
+		}
+
+		if r.now().Sub(lastCleanup) > time.Hour {
+			lastCleanup = r.now()
+			_, err := r.Store.DeleteFinishedJobs(ctx, lastCleanup.Add(-r.Retention))
+			if err != nil {
+				slog.Error("error deleting finished jobs", "err", err)
+			}
+		}
+
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) claim(ctx context.Context, n int) ([]database.Job, error) {
+	now := r.now()
+	return r.Store.ClaimJobs(ctx, database.ClaimJobsParams{
+		LeaseUntil: now.Add(r.Lease),
+		Now:        now,
+		MaxJobs:    int32(n),
+	})
+}
+
+// RunOnce claims and runs up to Concurrency due jobs, waiting for them to
+// finish. It returns how many were run.
+func (r *Runner) RunOnce(ctx context.Context) (int, error) {
+	claimed, err := r.claim(ctx, r.Concurrency)
+	if err != nil {
+		return 0, err
+	}
+	var wg sync.WaitGroup
+	for _, job := range claimed {
+		wg.Add(1)
+		go func(job database.Job) {
+			defer wg.Done()
+			r.run(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+	return len(claimed), nil
+}
+
+func (r *Runner) run(ctx context.Context, job database.Job) {
+	logger := slog.Default().With("job_id", job.ID, "job_kind", job.Kind, "attempt", job.Attempts)
+	ctx = logging.NewContext(ctx, logger)
+	jobCtx, cancel := context.WithTimeout(ctx, r.Lease)
+	err := r.call(jobCtx, job)
+	cancel()
+
+	result := database.FinishJobParams{
+		ID:     job.ID,
+		Status: StatusSucceeded,
+		RunAt:  r.now(),
+	}
+	if err != nil {
+		result.LastError = err.Error()
+		result.Status = StatusQueued
+		result.RunAt = r.now().Add(Backoff(int(job.Attempts)))
+		if isPermanent(err) || job.Attempts >= job.MaxAttempts {
+			result.Status = StatusDead
+		}
+		logger.Warn("job failed", "status", result.Status, "err", err)
+	}
+
+	// Record the outcome even if the runner is being force stopped.
+	err = r.Store.FinishJob(context.WithoutCancel(ctx), result)
+	if err != nil {
+		logger.Error("error finishing job", "err", err)
+	}
+}
+
+func (r *Runner) call(ctx context.Context, job database.Job) (err error) {
+	if job.Attempts > job.MaxAttempts {
+		// Only reachable when runners kept dying mid-job until the lease ran
+		// out; don't try again.
+		return Permanent(errors.New("lease expired on final attempt"))
+	}
+	fn, ok := r.handlers[job.Kind]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler registered for %q", job.Kind))
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("handler panicked: %v", p)
+		}
+	}()
+	return fn(ctx, job.Payload)
+}
@@ -0,0 +1,164 @@
+// Package logging sets up chirpy's structured logs. Every request gets a
+// logger carrying its request ID, which handlers and the code they call find
+// in the request's context.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/httpx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+const redacted = "[redacted]"
+
+// sensitiveKeys are attribute keys whose values never reach the logs,
+// whatever group they are in.
+var sensitiveKeys = map[string]bool{
+	"authorization":   true,
+	"cookie":          true,
+	"set-cookie":      true,
+	"password":        true,
+	"hashed_password": true,
+	"token":           true,
+	"access_token":    true,
+	"refresh_token":   true,
+	"secret":          true,
+	"api_key":         true,
+	"polka-signature": true,
+}
+
+// New returns a logger writing to w in format, FormatText or FormatJSON,
+// that drops records below level and redacts secrets.
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}
+	switch format {
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	switch v := a.Value.Any().(type) {
+	case http.Header:
+		return slog.Any(a.Key, headerValue(v))
+	case string:
+		if strings.HasPrefix(v, "Bearer ") || strings.HasPrefix(v, "ApiKey ") {
+			return slog.String(a.Key, redacted)
+		}
+	}
+	return a
+}
+
+// headerValue logs h as a group of its headers. The sensitive ones are
+// redacted by redact when the group's attributes are written.
+func headerValue(h http.Header) slog.Value {
+	attrs := make([]slog.Attr, 0, len(h))
+	for name, values := range h {
+		attrs = append(attrs, slog.String(strings.ToLower(name), strings.Join(values, ", ")))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+type ctxKey struct{}
+
+// requestLog is what the middleware keeps in a request's context. It is a
+// pointer so that handlers can add to it for the final request log line.
+type requestLog struct {
+	logger *slog.Logger
+	userID string
+}
+
+// NewContext returns a copy of ctx that FromContext finds logger in.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &requestLog{logger: logger})
+}
+
+// FromContext returns the logger of ctx's request or job, or the default
+// logger when it has none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if rl, ok := ctx.Value(ctxKey{}).(*requestLog); ok {
+		return rl.logger
+	}
+	return slog.Default()
+}
+
+// SetUserID records who made the request, for its later log lines and the
+// line the middleware writes when it finishes.
+func SetUserID(ctx context.Context, userID uuid.UUID) {
+	if rl, ok := ctx.Value(ctxKey{}).(*requestLog); ok && rl.userID == "" {
+		rl.userID = userID.String()
+		rl.logger = rl.logger.With("user_id", rl.userID)
+	}
+}
+
+// Middleware makes sure every request carries an X-Request-ID, keeping one
+// set by an upstream proxy and echoing it back to the client. It gives the
+// request a logger tagged with that ID, and the trace ID when the request is
+// traced, and logs each request once it has been handled.
+func Middleware(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.NewString()
+			r.Header.Set("X-Request-ID", requestID)
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		reqLogger := logger.With("request_id", requestID)
+		if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+			reqLogger = reqLogger.With("trace_id", span.TraceID().String())
+		}
+		ctx := NewContext(r.Context(), reqLogger)
+		rl := ctx.Value(ctxKey{}).(*requestLog)
+		r = r.WithContext(ctx)
+		reqLogger.DebugContext(ctx, "request started",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"headers", r.Header,
+		)
+
+		recorder := httpx.Record(w)
+		next.ServeHTTP(recorder, r)
+
+		level := slog.LevelInfo
+		if recorder.Status >= 500 {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", r.Pattern),
+			slog.Int("status", recorder.Status),
+			slog.Int64("bytes", recorder.Bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote_addr", r.RemoteAddr),
+		}
+		if rl.userID != "" {
+			attrs = append(attrs, slog.String("user_id", rl.userID))
+		}
+		reqLogger.LogAttrs(ctx, level, "request", attrs...)
+	})
+}
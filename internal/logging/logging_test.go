@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, slog.LevelDebug)
+	if err != nil {
+		t.Fatalf(`New() = %v, wanted nil`, err)
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer abc.def.ghi")
+	header.Set("Accept", "application/json")
+	logger.Info("test",
+		"password", "hunter2",
+		slog.Group("params", "refresh_token", "r3fr3sh"),
+		"headers", header,
+		"note", "Bearer leaked",
+		"email", "a@example.com",
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "r3fr3sh", "abc.def.ghi", "leaked"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf(`log line %s contains %q`, out, secret)
+		}
+	}
+	for _, kept := range []string{`"email":"a@example.com"`, `"accept":"application/json"`, `"authorization":"[redacted]"`} {
+		if !strings.Contains(out, kept) {
+			t.Fatalf(`log line %s is missing %s`, out, kept)
+		}
+	}
+
+	_, err = New(&buf, "xml", slog.LevelInfo)
+	if err == nil {
+		t.Fatalf(`New() with an unknown format = nil, wanted an error`)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := New(&buf, FormatJSON, slog.LevelInfo)
+	userID := uuid.New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/chirps", func(w http.ResponseWriter, r *http.Request) {
+		SetUserID(r.Context(), userID)
+		FromContext(r.Context()).Info("handling")
+		w.WriteHeader(201)
+		w.Write([]byte("created"))
+	})
+	handler := Middleware(logger, mux)
+
+	rq := httptest.NewRequest("POST", "/api/chirps", nil)
+	rq.Header.Set("X-Request-ID", "req-42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, rq)
+	if got := rec.Header().Get("X-Request-ID"); got != "req-42" {
+		t.Fatalf(`X-Request-ID = %q, wanted the incoming "req-42"`, got)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf(`logged %d lines, wanted the handler's and the request's: %s`, len(lines), buf.String())
+	}
+	var handling, request map[string]any
+	json.Unmarshal([]byte(lines[0]), &handling)
+	json.Unmarshal([]byte(lines[1]), &request)
+	if handling["request_id"] != "req-42" || handling["user_id"] != userID.String() {
+		t.Fatalf(`handler log = %v, wanted the request and user ids`, handling)
+	}
+	if request["route"] != "POST /api/chirps" || request["status"] != float64(201) || request["bytes"] != float64(7) || request["user_id"] != userID.String() {
+		t.Fatalf(`request log = %v, wanted route, status, size and user`, request)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if _, err := uuid.Parse(rec.Header().Get("X-Request-ID")); err != nil {
+		t.Fatalf(`X-Request-ID = %q, wanted a generated uuid`, rec.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestFromContextDefault(t *testing.T) {
+	if FromContext(httptest.NewRequest("GET", "/", nil).Context()) != slog.Default() {
+		t.Fatalf(`FromContext() without a logger, wanted slog.Default()`)
+	}
+}
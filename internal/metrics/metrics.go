@@ -0,0 +1,177 @@
+// Package metrics collects chirpy's Prometheus metrics. Everything is
+// registered on one registry, served at /metrics and summarised on the
+// admin metrics page.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jamistoso/chirpy/internal/httpx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Reasons a request failed authentication, the values of the reason label
+// of chirpy_auth_failures_total.
+const (
+	AuthPassword       = "password"
+	AuthAccessToken    = "access_token"
+	AuthRefreshToken   = "refresh_token"
+	AuthPolkaSignature = "polka_signature"
+)
+
+// unmatchedRoute labels requests no route matched, so that scanners trying
+// random paths can't blow up the number of series.
+const unmatchedRoute = "unmatched"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+
+	// fileserverHits has no labels; it is a vector only so that the dev
+	// reset can zero it.
+	fileserverHits *prometheus.CounterVec
+	authFailures   *prometheus.CounterVec
+
+	ChirpsCreated prometheus.Counter
+	Signups       prometheus.Counter
+	RedUpgrades   prometheus.Counter
+}
+
+// New registers chirpy's metrics, plus the Go runtime, process and db
+// connection pool statistics.
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests handled, by route pattern and status code.",
+		}, []string{"route", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "Time taken to handle HTTP requests, by route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "chirpy_http_requests_in_flight",
+			Help: "HTTP requests currently being handled.",
+		}),
+		fileserverHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_fileserver_hits_total",
+			Help: "Requests for the static app under /app/.",
+		}, nil),
+		authFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_auth_failures_total",
+			Help: "Requests rejected for bad credentials, by what was wrong.",
+		}, []string{"reason"}),
+		ChirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_chirps_created_total",
+			Help: "Chirps posted.",
+		}),
+		Signups: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_signups_total",
+			Help: "Accounts created through the API.",
+		}),
+		RedUpgrades: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_red_upgrades_total",
+			Help: "Users who gained Chirpy Red.",
+		}),
+	}
+
+	// Start every known series at zero so rate() works from the first
+	// failure.
+	for _, reason := range []string{AuthPassword, AuthAccessToken, AuthRefreshToken, AuthPolkaSignature} {
+		m.authFailures.WithLabelValues(reason)
+	}
+	m.fileserverHits.WithLabelValues()
+
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		m.inFlight,
+		m.fileserverHits,
+		m.authFailures,
+		m.ChirpsCreated,
+		m.Signups,
+		m.RedUpgrades,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "chirpy"))
+	}
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// AuthFailure counts a request rejected for the given reason.
+func (m *Metrics) AuthFailure(reason string) {
+	m.authFailures.WithLabelValues(reason).Inc()
+}
+
+// FileserverHit counts a request for the static app.
+func (m *Metrics) FileserverHit() {
+	m.fileserverHits.WithLabelValues().Inc()
+}
+
+// ResetFileserverHits zeroes the fileserver hits for the dev reset
+// endpoint. Prometheus sees this like a restart.
+func (m *Metrics) ResetFileserverHits() {
+	m.fileserverHits.Reset()
+	m.fileserverHits.WithLabelValues()
+}
+
+// Instrument records the count, latency and status of every request next
+// handles. next should be a ServeMux: requests are labelled with the pattern
+// it matched.
+func (m *Metrics) Instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		start := time.Now()
+		recorder := httpx.Record(w)
+		next.ServeHTTP(recorder, r)
+
+		// ServeMux sets Pattern on the request it was given once it has
+		// picked a handler.
+		route := r.Pattern
+		if route == "" {
+			route = unmatchedRoute
+		}
+		m.requests.WithLabelValues(route, strconv.Itoa(recorder.Status)).Inc()
+		m.duration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Snapshot returns the current value of every counter and gauge, summed
+// across labels and keyed by metric name.
+func (m *Metrics) Snapshot() (map[string]float64, error) {
+	families, err := m.registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]float64, len(families))
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			switch {
+			case metric.Counter != nil:
+				values[family.GetName()] += metric.Counter.GetValue()
+			case metric.Gauge != nil:
+				values[family.GetName()] += metric.Gauge.GetValue()
+			}
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInstrumentLabelsByRoute(t *testing.T) {
+	m := New(nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+	})
+	mux.HandleFunc("POST /api/chirps", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("created"))
+	})
+	handler := m.Instrument(mux)
+
+	for _, rq := range []*http.Request{
+		httptest.NewRequest("GET", "/api/chirps/1", nil),
+		httptest.NewRequest("GET", "/api/chirps/2", nil),
+		httptest.NewRequest("POST", "/api/chirps", nil),
+		httptest.NewRequest("GET", "/wp-admin.php", nil),
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), rq)
+	}
+
+	out := scrape(t, m)
+	for _, want := range []string{
+		`chirpy_http_requests_total{code="404",route="GET /api/chirps/{chirpID}"} 2`,
+		`chirpy_http_requests_total{code="200",route="POST /api/chirps"} 1`,
+		`chirpy_http_requests_total{code="404",route="unmatched"} 1`,
+		`chirpy_http_request_duration_seconds_count{route="GET /api/chirps/{chirpID}"} 2`,
+		`chirpy_http_requests_in_flight 0`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf(`/metrics is missing %s:\n%s`, want, out)
+		}
+	}
+}
+
+func TestCountersAndSnapshot(t *testing.T) {
+	m := New(nil)
+	m.AuthFailure(AuthPassword)
+	m.AuthFailure(AuthPassword)
+	m.AuthFailure(AuthAccessToken)
+	m.Signups.Inc()
+	m.FileserverHit()
+	m.FileserverHit()
+
+	out := scrape(t, m)
+	for _, want := range []string{
+		`chirpy_auth_failures_total{reason="password"} 2`,
+		`chirpy_auth_failures_total{reason="polka_signature"} 0`,
+		`chirpy_signups_total 1`,
+		`chirpy_fileserver_hits_total 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf(`/metrics is missing %s:\n%s`, want, out)
+		}
+	}
+
+	values, err := m.Snapshot()
+	if err != nil {
+		t.Fatalf(`Snapshot() = %v, wanted nil`, err)
+	}
+	if values["chirpy_auth_failures_total"] != 3 || values["chirpy_fileserver_hits_total"] != 2 {
+		t.Fatalf(`Snapshot() = %v, wanted 3 auth failures and 2 hits`, values)
+	}
+
+	m.ResetFileserverHits()
+	values, _ = m.Snapshot()
+	if hits, ok := values["chirpy_fileserver_hits_total"]; !ok || hits != 0 {
+		t.Fatalf(`fileserver hits after reset = %v (present %v), wanted 0`, hits, ok)
+	}
+}
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
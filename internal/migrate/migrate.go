@@ -0,0 +1,416 @@
+// Package migrate applies goose-format SQL migrations.
+//
+// It reads the same files and keeps the same goose_db_version table as the
+// goose CLI, so a database can be migrated by either. Migrations run one
+// transaction each on a single connection, which on Postgres holds an
+// advisory lock for the duration so that instances starting together don't
+// race each other.
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// lockID is the Postgres advisory lock key held while migrating.
+const lockID int64 = 0x636869727079 // "chirpy"
+
+var ErrNoApplied = errors.New("no migrations applied")
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Load parses every NNN_name.sql file at the top of fsys, ordered by
+// version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []Migration{}
+	seen := map[int64]string{}
+	for _, name := range names {
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("%s: migration file names start with a version, as in 001_users.sql", name)
+		}
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("%s: invalid version %q", name, prefix)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("%s: version %d already used by %s", name, version, other)
+		}
+		seen[version] = name
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		up, down, err := Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    path.Base(name),
+			Up:      up,
+			Down:    down,
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Parse splits a goose migration into its Up and Down statements. As in
+// goose, a statement ends at a line ending in a semicolon, unless it is
+// wrapped in StatementBegin and StatementEnd annotations.
+func Parse(src string) (up, down []string, err error) {
+	var (
+		section *[]string
+		buf     strings.Builder
+		inBlock bool
+	)
+	flush := func() {
+		stmt := strings.TrimSpace(buf.String())
+		buf.Reset()
+		if stmt != "" && section != nil {
+			*section = append(*section, stmt)
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if annotation, ok := strings.CutPrefix(trimmed, "-- +goose "); ok {
+			switch strings.TrimSpace(annotation) {
+			case "Up":
+				flush()
+				section = &up
+			case "Down":
+				flush()
+				section = &down
+			case "StatementBegin":
+				flush()
+				inBlock = true
+			case "StatementEnd":
+				flush()
+				inBlock = false
+			default:
+				return nil, nil, fmt.Errorf("unsupported annotation %q", trimmed)
+			}
+			continue
+		}
+		if section == nil {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "--") && buf.Len() == 0 && !inBlock {
+			continue
+		}
+
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+		if !inBlock && strings.HasSuffix(trimmed, ";") {
+			flush()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if inBlock {
+		return nil, nil, errors.New("StatementBegin without StatementEnd")
+	}
+	if strings.TrimSpace(buf.String()) != "" {
+		return nil, nil, errors.New("last statement is missing its semicolon")
+	}
+	if section == nil {
+		return nil, nil, errors.New("no -- +goose Up annotation")
+	}
+	return up, down, nil
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+func New(db *sql.DB, dialect Dialect, fsys fs.FS) (*Migrator, error) {
+	if dialect != Postgres && dialect != SQLite {
+		return nil, fmt.Errorf("unknown dialect %q", dialect)
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up applies every pending migration in order and returns the ones it
+// applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			err := m.apply(ctx, conn, migration, true)
+			if err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	var rolledBack Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		migration, err := m.latest(ctx, conn)
+		if err != nil {
+			return err
+		}
+		rolledBack = migration
+		return m.apply(ctx, conn, migration, false)
+	})
+	return rolledBack, err
+}
+
+// Redo rolls back the most recently applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) (Migration, error) {
+	var redone Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		migration, err := m.latest(ctx, conn)
+		if err != nil {
+			return err
+		}
+		redone = migration
+		err = m.apply(ctx, conn, migration, false)
+		if err != nil {
+			return err
+		}
+		return m.apply(ctx, conn, migration, true)
+	})
+	return redone, err
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			appliedAt, ok := versions[migration.Version]
+			statuses = append(statuses, Status{
+				Migration: migration,
+				Applied:   ok,
+				AppliedAt: appliedAt,
+			})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// Pending returns the migrations in this build that the database lacks.
+// Unlike Status it doesn't take the migration lock, so it is cheap enough for
+// health checks and doesn't wait for a migration in progress.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	versions, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := versions[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// locked runs fn on one connection, holding the migration lock on Postgres.
+// SQLite needs no lock: its pool has a single connection, so holding it
+// already shuts out everyone else.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.dialect == Postgres {
+		_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID)
+		if err != nil {
+			return fmt.Errorf("taking migration lock: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+	}
+
+	err = m.ensureVersionTable(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) ensureVersionTable(ctx context.Context, conn *sql.Conn) error {
+	create := `CREATE TABLE IF NOT EXISTS goose_db_version (
+    id          SERIAL      PRIMARY KEY,
+    version_id  BIGINT      NOT NULL,
+    is_applied  BOOLEAN     NOT NULL,
+    tstamp      TIMESTAMP   DEFAULT NOW()
+)`
+	if m.dialect == SQLite {
+		create = `CREATE TABLE IF NOT EXISTS goose_db_version (
+    id          INTEGER     PRIMARY KEY AUTOINCREMENT,
+    version_id  INTEGER     NOT NULL,
+    is_applied  INTEGER     NOT NULL,
+    tstamp      TIMESTAMP   DEFAULT (datetime('now'))
+)`
+	}
+	_, err := conn.ExecContext(ctx, create)
+	if err != nil {
+		return fmt.Errorf("creating goose_db_version: %w", err)
+	}
+
+	// goose starts every database at version 0.
+	var n int
+	err = conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM goose_db_version`).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		_, err = conn.ExecContext(ctx, `INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, true)`)
+	}
+	return err
+}
+
+// applied returns when each applied migration was applied. Like goose, only
+// the newest row for a version counts.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version_id, is_applied, tstamp FROM goose_db_version ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := map[int64]bool{}
+	versions := map[int64]time.Time{}
+	for rows.Next() {
+		var (
+			version   int64
+			isApplied bool
+			tstamp    sql.NullTime
+		)
+		err := rows.Scan(&version, &isApplied, &tstamp)
+		if err != nil {
+			return nil, err
+		}
+		if version == 0 || seen[version] {
+			continue
+		}
+		seen[version] = true
+		if isApplied {
+			versions[version] = tstamp.Time
+		}
+	}
+	return versions, rows.Err()
+}
+
+func (m *Migrator) latest(ctx context.Context, conn *sql.Conn) (Migration, error) {
+	versions, err := m.applied(ctx, conn)
+	if err != nil {
+		return Migration{}, err
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		if _, ok := versions[m.migrations[i].Version]; ok {
+			return m.migrations[i], nil
+		}
+	}
+	if len(versions) > 0 {
+		return Migration{}, errors.New("the applied migrations are not in this build")
+	}
+	return Migration{}, ErrNoApplied
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	statements, direction := migration.Up, "up"
+	if !up {
+		statements, direction = migration.Down, "down"
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range statements {
+		_, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", migration.Name, direction, err)
+		}
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, true)`, migration.Version)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM goose_db_version WHERE version_id = $1`, migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s: recording version: %w", migration.Name, direction, err)
+	}
+	return tx.Commit()
+}
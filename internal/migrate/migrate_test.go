@@ -0,0 +1,265 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jamistoso/chirpy/internal/sqlite"
+	_ "github.com/lib/pq"
+)
+
+func TestParse(t *testing.T) {
+	src := `-- +goose Up
+-- A comment before the first statement.
+CREATE TABLE a (
+    id INTEGER
+);
+
+CREATE INDEX a_idx ON a(id);
+
+-- +goose StatementBegin
+CREATE FUNCTION f() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'no';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE a;
+`
+	up, down, err := Parse(src)
+	if err != nil {
+		t.Fatalf(`Parse() = %v, wanted nil`, err)
+	}
+	if len(up) != 3 {
+		t.Fatalf(`Parse() up = %q, wanted 3 statements`, up)
+	}
+	if !strings.HasPrefix(up[0], "CREATE TABLE a (") || !strings.HasSuffix(up[0], ");") {
+		t.Fatalf(`up[0] = %q, wanted the whole CREATE TABLE`, up[0])
+	}
+	if !strings.Contains(up[2], "RAISE EXCEPTION 'no';\nEND;") {
+		t.Fatalf(`up[2] = %q, wanted the function kept whole`, up[2])
+	}
+	if !reflect.DeepEqual(down, []string{"DROP TABLE a;"}) {
+		t.Fatalf(`Parse() down = %q, wanted [DROP TABLE a;]`, down)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"no up":        "CREATE TABLE a (id INTEGER);\n",
+		"unterminated": "-- +goose Up\nCREATE TABLE a (id INTEGER)\n",
+		"open block":   "-- +goose Up\n-- +goose StatementBegin\nSELECT 1;\n",
+		"unknown":      "-- +goose Up\n-- +goose ENVSUB ON\nSELECT 1;\n",
+	}
+	for name, src := range tests {
+		_, _, err := Parse(src)
+		if err == nil {
+			t.Fatalf(`Parse(%s) = nil, wanted error`, name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_b.sql": {Data: []byte("-- +goose Up\nSELECT 2;\n")},
+		"001_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		"README.md": {Data: []byte("not a migration")},
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf(`Load() = %v, wanted nil`, err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Name != "002_b.sql" {
+		t.Fatalf(`Load() = %+v, wanted 001_a.sql then 002_b.sql`, migrations)
+	}
+
+	fsys["01_dup.sql"] = &fstest.MapFile{Data: []byte("-- +goose Up\nSELECT 1;\n")}
+	_, err = Load(fsys)
+	if err == nil {
+		t.Fatalf(`Load(duplicate version) = nil, wanted error`)
+	}
+}
+
+func TestSQLiteRoundTrip(t *testing.T) {
+	db, err := sqlite.Open("sqlite::memory:")
+	if err != nil {
+		t.Fatalf(`Open() = %v, wanted nil`, err)
+	}
+	defer db.Close()
+
+	testRoundTrip(t, db, SQLite, "../../sql/sqlite", func() string {
+		return dump(t, db, `SELECT type || ' ' || name || ': ' || COALESCE(sql, '') FROM sqlite_master
+			WHERE name NOT LIKE 'sqlite_%' AND name <> 'goose_db_version' ORDER BY type, name`)
+	})
+}
+
+// TestPostgresRoundTrip needs a database it is allowed to empty, named by
+// CHIRPY_TEST_DB_URL. It leaves it fully migrated.
+func TestPostgresRoundTrip(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf(`sql.Open() = %v, wanted nil`, err)
+	}
+	defer db.Close()
+
+	testRoundTrip(t, db, Postgres, "../../sql/schema", func() string {
+		return dump(t, db, `SELECT table_name || '.' || column_name || ' ' || data_type || ' ' || is_nullable || ' ' || COALESCE(column_default, '')
+			FROM information_schema.columns
+			WHERE table_schema = 'public' AND table_name <> 'goose_db_version'
+			UNION ALL
+			SELECT 'index ' || indexname || ': ' || indexdef FROM pg_indexes WHERE schemaname = 'public'
+			UNION ALL
+			SELECT 'trigger ' || trigger_name || ' ' || event_manipulation || ' ' || event_object_table FROM information_schema.triggers
+			UNION ALL
+			SELECT 'function ' || routine_name FROM information_schema.routines WHERE routine_schema = 'public'
+			ORDER BY 1`)
+	})
+}
+
+// testRoundTrip rolls the database all the way down, then checks that every
+// migration's Down undoes exactly what its Up did.
+func testRoundTrip(t *testing.T, db *sql.DB, dialect Dialect, dir string, schema func() string) {
+	ctx := context.Background()
+	m, err := New(db, dialect, os.DirFS(dir))
+	if err != nil {
+		t.Fatalf(`New() = %v, wanted nil`, err)
+	}
+	if len(m.Migrations()) == 0 {
+		t.Fatalf(`no migrations in %s`, dir)
+	}
+
+	for {
+		_, err := m.Down(ctx)
+		if errors.Is(err, ErrNoApplied) {
+			break
+		}
+		if err != nil {
+			t.Fatalf(`Down() = %v, wanted nil`, err)
+		}
+	}
+
+	before := []string{schema()}
+	for _, migration := range m.Migrations() {
+		one, err := New(db, dialect, fstest.MapFS{})
+		if err != nil {
+			t.Fatalf(`New() = %v, wanted nil`, err)
+		}
+		one.migrations = []Migration{migration}
+		applied, err := one.Up(ctx)
+		if err != nil || len(applied) != 1 {
+			t.Fatalf(`Up(%s) = %v, %v, wanted it applied`, migration.Name, applied, err)
+		}
+		before = append(before, schema())
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf(`Status() = %v, wanted nil`, err)
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			t.Fatalf(`%s not applied after Up`, status.Name)
+		}
+	}
+
+	for i := len(m.Migrations()) - 1; i >= 0; i-- {
+		redone, err := m.Redo(ctx)
+		if err != nil {
+			t.Fatalf(`Redo() = %v, wanted nil`, err)
+		}
+		if got := schema(); got != before[i+1] {
+			t.Fatalf(`schema after redoing %s:\n%s\nwanted:\n%s`, redone.Name, got, before[i+1])
+		}
+
+		rolledBack, err := m.Down(ctx)
+		if err != nil {
+			t.Fatalf(`Down() = %v, wanted nil`, err)
+		}
+		if rolledBack.Version != m.Migrations()[i].Version {
+			t.Fatalf(`Down() rolled back %s, wanted %s`, rolledBack.Name, m.Migrations()[i].Name)
+		}
+		if got := schema(); got != before[i] {
+			t.Fatalf(`schema after rolling back %s:\n%s\nwanted:\n%s`, rolledBack.Name, got, before[i])
+		}
+	}
+
+	_, err = m.Down(ctx)
+	if !errors.Is(err, ErrNoApplied) {
+		t.Fatalf(`Down() with nothing applied = %v, wanted ErrNoApplied`, err)
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil || len(applied) != len(m.Migrations()) {
+		t.Fatalf(`Up() = %d applied, %v, wanted all %d`, len(applied), err, len(m.Migrations()))
+	}
+	applied, err = m.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf(`second Up() = %d applied, %v, wanted none`, len(applied), err)
+	}
+}
+
+func dump(t *testing.T, db *sql.DB, query string) string {
+	t.Helper()
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatalf(`dumping schema: %v`, err)
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		err := rows.Scan(&line)
+		if err != nil {
+			t.Fatalf(`dumping schema: %v`, err)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestPending(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqlite.Open("sqlite::memory:")
+	if err != nil {
+		t.Fatalf(`Open() = %v, wanted nil`, err)
+	}
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"001_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id INTEGER);\n-- +goose Down\nDROP TABLE a;\n")},
+		"002_b.sql": {Data: []byte("-- +goose Up\nCREATE TABLE b (id INTEGER);\n-- +goose Down\nDROP TABLE b;\n")},
+	}
+	m, err := New(db, SQLite, fsys)
+	if err != nil {
+		t.Fatalf(`New() = %v, wanted nil`, err)
+	}
+	_, err = m.Up(ctx)
+	if err != nil {
+		t.Fatalf(`Up() = %v, wanted nil`, err)
+	}
+	_, err = m.Down(ctx)
+	if err != nil {
+		t.Fatalf(`Down() = %v, wanted nil`, err)
+	}
+
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		t.Fatalf(`Pending() = %v, wanted nil`, err)
+	}
+	if len(pending) != 1 || pending[0].Version != 2 {
+		t.Fatalf(`Pending() = %v, wanted only version 2`, pending)
+	}
+}
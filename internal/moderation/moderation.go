@@ -0,0 +1,120 @@
+package moderation
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Action string
+
+const (
+	Allow      Action = "allow"
+	Hold       Action = "hold"
+	ShadowHide Action = "shadow_hide"
+	Reject     Action = "reject"
+)
+
+// Submission is a chirp about to be created along with the author's recent
+// activity, which scorers use to spot floods and repeats.
+type Submission struct {
+	UserID           uuid.UUID
+	Body             string
+	AccountCreatedAt time.Time
+	Now              time.Time
+	Recent           []Post
+}
+
+type Post struct {
+	Body      string
+	CreatedAt time.Time
+}
+
+// Signal is one scorer's opinion. Score is roughly 0 (clean) to 1 (certainly
+// spam); a zero score means the scorer found nothing.
+type Signal struct {
+	Rule   string
+	Score  float64
+	Reason string
+}
+
+type Scorer interface {
+	Score(ctx context.Context, sub Submission) Signal
+}
+
+type Decision struct {
+	Action  Action
+	Score   float64
+	Signals []Signal
+}
+
+func (d Decision) Reasons() []string {
+	reasons := make([]string, 0, len(d.Signals))
+	for _, signal := range d.Signals {
+		reasons = append(reasons, signal.Rule+": "+signal.Reason)
+	}
+	return reasons
+}
+
+// Thresholds map a combined score to an action. The highest threshold the
+// score reaches wins; anything below Hold is allowed.
+type Thresholds struct {
+	Hold       float64
+	ShadowHide float64
+	Reject     float64
+}
+
+var DefaultThresholds = Thresholds{
+	Hold:       0.5,
+	ShadowHide: 0.8,
+	Reject:     1.2,
+}
+
+type Pipeline struct {
+	Scorers    []Scorer
+	Thresholds Thresholds
+}
+
+// NewPipeline returns a pipeline running the built-in duplicate, link and
+// burst scorers with default thresholds.
+func NewPipeline() *Pipeline {
+	return &Pipeline{
+		Scorers: []Scorer{
+			DuplicateScorer{Window: 24 * time.Hour, MaxDistance: 3},
+			LinkScorer{MaxLinks: 2},
+			BurstScorer{NewAccountAge: 24 * time.Hour, Window: 10 * time.Minute, MaxPosts: 5, MaxPostsEstablished: 20},
+		},
+		Thresholds: DefaultThresholds,
+	}
+}
+
+func (p *Pipeline) Evaluate(ctx context.Context, sub Submission) Decision {
+	if sub.Now.IsZero() {
+		sub.Now = time.Now()
+	}
+
+	decision := Decision{Action: Allow}
+	for _, scorer := range p.Scorers {
+		signal := scorer.Score(ctx, sub)
+		if signal.Score <= 0 {
+			continue
+		}
+		decision.Score += signal.Score
+		decision.Signals = append(decision.Signals, signal)
+	}
+	sort.Slice(decision.Signals, func(i, j int) bool {
+		return decision.Signals[i].Score > decision.Signals[j].Score
+	})
+
+	switch {
+	case decision.Score >= p.Thresholds.Reject:
+		decision.Action = Reject
+	case decision.Score >= p.Thresholds.ShadowHide:
+		decision.Action = ShadowHide
+	case decision.Score >= p.Thresholds.Hold:
+		decision.Action = Hold
+	}
+	return decision
+}
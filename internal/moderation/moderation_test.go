@@ -0,0 +1,83 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSimhashNearDuplicates(t *testing.T) {
+	a := Simhash("Check out my amazing new crypto giveaway, limited spots available today!")
+	b := Simhash("check out my AMAZING new crypto giveaway!! limited spots available today")
+	c := Simhash("I had a lovely walk by the river this morning with the dog")
+	if d := HammingDistance(a, b); d > 3 {
+		t.Fatalf(`HammingDistance(near duplicates) = %d, wanted <= 3`, d)
+	}
+	if d := HammingDistance(a, c); d <= 3 {
+		t.Fatalf(`HammingDistance(unrelated) = %d, wanted > 3`, d)
+	}
+}
+
+func TestPipelineAllowsFirstChirp(t *testing.T) {
+	now := time.Now()
+	decision := NewPipeline().Evaluate(context.Background(), Submission{
+		Body:             "Hello Chirpy, this is my first chirp",
+		AccountCreatedAt: now.Add(-time.Minute),
+		Now:              now,
+	})
+	if decision.Action != Allow {
+		t.Fatalf(`Evaluate(first chirp) = %v, wanted %v`, decision.Action, Allow)
+	}
+}
+
+func TestPipelineEscalatesRepeats(t *testing.T) {
+	now := time.Now()
+	body := "Buy followers now at spam dot example, best prices guaranteed"
+	sub := Submission{
+		Body:             body,
+		AccountCreatedAt: now.Add(-30 * 24 * time.Hour),
+		Now:              now,
+	}
+
+	wanted := []Action{Allow, Hold, ShadowHide, Reject}
+	for i, want := range wanted {
+		decision := NewPipeline().Evaluate(context.Background(), sub)
+		if decision.Action != want {
+			t.Fatalf(`Evaluate after %d repeats = %v (score %.2f), wanted %v`, i, decision.Action, decision.Score, want)
+		}
+		sub.Recent = append(sub.Recent, Post{Body: body, CreatedAt: now.Add(-time.Duration(i+1) * time.Hour)})
+	}
+}
+
+func TestBurstFromNewAccount(t *testing.T) {
+	now := time.Now()
+	sub := Submission{
+		Body:             "totally different chirp",
+		AccountCreatedAt: now.Add(-time.Hour),
+		Now:              now,
+	}
+	bodies := []string{"one fish", "two fish", "red fish", "blue fish", "this one has a little star"}
+	for i, body := range bodies {
+		sub.Recent = append(sub.Recent, Post{Body: body, CreatedAt: now.Add(-time.Duration(i) * time.Minute)})
+	}
+
+	signal := BurstScorer{NewAccountAge: 24 * time.Hour, Window: 10 * time.Minute, MaxPosts: 5, MaxPostsEstablished: 20}.Score(context.Background(), sub)
+	if signal.Score == 0 {
+		t.Fatal(`BurstScorer.Score(new account, 5 posts) = 0, wanted a signal`)
+	}
+
+	sub.AccountCreatedAt = now.Add(-48 * time.Hour)
+	signal = BurstScorer{NewAccountAge: 24 * time.Hour, Window: 10 * time.Minute, MaxPosts: 5, MaxPostsEstablished: 20}.Score(context.Background(), sub)
+	if signal.Score != 0 {
+		t.Fatalf(`BurstScorer.Score(established account, 5 posts) = %v, wanted 0`, signal.Score)
+	}
+}
+
+func TestLinkHeavyChirp(t *testing.T) {
+	signal := LinkScorer{MaxLinks: 2}.Score(context.Background(), Submission{
+		Body: "https://a.example/x https://b.example/y https://c.example/z www.d.example",
+	})
+	if signal.Score < 0.5 {
+		t.Fatalf(`LinkScorer.Score(link heavy) = %v, wanted >= 0.5`, signal.Score)
+	}
+}
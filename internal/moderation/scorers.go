@@ -0,0 +1,111 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+)
+
+// DuplicateScorer flags bodies whose simhash is within MaxDistance bits of
+// something the same user posted inside Window.
+type DuplicateScorer struct {
+	Window      time.Duration
+	MaxDistance int
+}
+
+func (s DuplicateScorer) Score(ctx context.Context, sub Submission) Signal {
+	hash := Simhash(sub.Body)
+	matches := 0
+	for _, post := range sub.Recent {
+		if sub.Now.Sub(post.CreatedAt) > s.Window {
+			continue
+		}
+		if HammingDistance(hash, Simhash(post.Body)) <= s.MaxDistance {
+			matches++
+		}
+	}
+	if matches == 0 {
+		return Signal{}
+	}
+	return Signal{
+		Rule:   "duplicate",
+		Score:  math.Min(1.5, 0.3+0.3*float64(matches)),
+		Reason: fmt.Sprintf("near-duplicate of %d recent chirps", matches),
+	}
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// LinkScorer flags chirps carrying more than MaxLinks links, or that are
+// mostly made up of links.
+type LinkScorer struct {
+	MaxLinks int
+}
+
+func (s LinkScorer) Score(ctx context.Context, sub Submission) Signal {
+	links := linkPattern.FindAllString(sub.Body, -1)
+	if len(links) == 0 {
+		return Signal{}
+	}
+	linkChars := 0
+	for _, link := range links {
+		linkChars += len(link)
+	}
+	ratio := float64(linkChars) / float64(len(sub.Body))
+
+	score := 0.0
+	if len(links) > s.MaxLinks {
+		score += 0.3 * float64(len(links)-s.MaxLinks)
+	}
+	if ratio > 0.6 {
+		score += 0.3
+	}
+	if score == 0 {
+		return Signal{}
+	}
+	return Signal{
+		Rule:   "links",
+		Score:  math.Min(1, score),
+		Reason: fmt.Sprintf("%d links making up %.0f%% of the chirp", len(links), ratio*100),
+	}
+}
+
+// BurstScorer flags users posting faster than MaxPosts per Window while their
+// account is younger than NewAccountAge, and anyone at all posting faster than
+// MaxPostsEstablished per Window.
+type BurstScorer struct {
+	NewAccountAge       time.Duration
+	Window              time.Duration
+	MaxPosts            int
+	MaxPostsEstablished int
+}
+
+func (s BurstScorer) Score(ctx context.Context, sub Submission) Signal {
+	posts := 0
+	for _, post := range sub.Recent {
+		if sub.Now.Sub(post.CreatedAt) <= s.Window {
+			posts++
+		}
+	}
+
+	limit := s.MaxPostsEstablished
+	newAccount := sub.Now.Sub(sub.AccountCreatedAt) < s.NewAccountAge
+	if newAccount {
+		limit = s.MaxPosts
+	}
+	if posts < limit {
+		return Signal{}
+	}
+
+	reason := fmt.Sprintf("%d chirps in the last %s", posts, s.Window)
+	if newAccount {
+		reason += " from a new account"
+	}
+	return Signal{
+		Rule:   "burst",
+		Score:  0.6,
+		Reason: reason,
+	}
+}
@@ -0,0 +1,77 @@
+package moderation
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+const shingleSize = 4
+
+// Simhash fingerprints text so that near-identical bodies land a few bits
+// apart. Text is normalised to lowercase letters and digits, then hashed as
+// overlapping character shingles so small edits only move a few shingles.
+func Simhash(text string) uint64 {
+	shingles := shingle(normalize(text))
+	if len(shingles) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	for _, s := range shingles {
+		h := fnv.New64a()
+		h.Write([]byte(s))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func normalize(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+			space = false
+		case !space && b.Len() > 0:
+			b.WriteRune(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func shingle(text string) []string {
+	runes := []rune(text)
+	if len(runes) <= shingleSize {
+		if len(runes) == 0 {
+			return nil
+		}
+		return []string{text}
+	}
+	shingles := make([]string, 0, len(runes)-shingleSize+1)
+	for i := 0; i+shingleSize <= len(runes); i++ {
+		shingles = append(shingles, string(runes[i:i+shingleSize]))
+	}
+	return shingles
+}
@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jamistoso/chirpy/internal/apierror"
+	"github.com/jamistoso/chirpy/internal/auth"
+	"github.com/jamistoso/chirpy/internal/logging"
+)
+
+// Limiter wraps handlers with a token bucket per (policy, principal).
+// Multiplier, when set, scales the policy per request, e.g. to give paying
+// users a larger allowance.
+type Limiter struct {
+	Store      Store
+	Principal  func(*http.Request) string
+	Multiplier func(*http.Request) float64
+}
+
+func (l *Limiter) Limit(base Policy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := base
+		if l.Multiplier != nil {
+			if factor := l.Multiplier(r); factor > 0 && factor != 1 {
+				policy = base.Scale(factor)
+			}
+		}
+		key := policy.Name + ":" + l.Principal(r)
+		res, err := l.Store.Take(r.Context(), key, policy)
+		if err != nil {
+			// Fail open: an unavailable limiter store shouldn't take the API down with it.
+			logging.FromContext(r.Context()).Error("rate limit store error", "err", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		w.Header().Set("RateLimit-Policy", strconv.Itoa(policy.Burst)+";w="+strconv.Itoa(ceilSeconds(policy.Window())))
+
+		if !res.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			apierror.Write(w, r, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited, "rate limit exceeded"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// PrincipalResolver identifies who a request is rate limited as: the JWT
+// subject when an access token Keys accepts is present, otherwise the client
+// IP. Nothing a client can make up, like a new API key on every request,
+// gets it a fresh bucket.
+type PrincipalResolver struct {
+	Keys           *auth.Keyring
+	TrustedProxies []netip.Prefix
+}
+
+func (p PrincipalResolver) Key(r *http.Request) string {
+	if token, err := auth.GetBearerToken(r.Header); err == nil && p.Keys != nil {
+		if userID, err := p.Keys.ValidateJWT(token); err == nil {
+			return "user:" + userID.String()
+		}
+	}
+	return "ip:" + p.ClientIP(r)
+}
+
+// ClientIP returns the address of the client that sent r. X-Forwarded-For is
+// only honoured when the connection comes from a trusted proxy, and is walked
+// from the right so a client can't spoof its address by prepending entries.
+func (p PrincipalResolver) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	remote = remote.Unmap()
+	if !p.trusted(remote) {
+		return remote.String()
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		hop = hop.Unmap()
+		if !p.trusted(hop) {
+			return hop.String()
+		}
+		remote = hop
+	}
+	return remote.String()
+}
+
+func (p PrincipalResolver) trusted(addr netip.Addr) bool {
+	for _, prefix := range p.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTrustedProxies reads a comma separated list of CIDRs or bare addresses.
+func ParseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy is a token bucket holding at most Burst tokens, refilled at Rate
+// tokens per second. Name namespaces the bucket keys so that two routes
+// sharing a principal don't share a bucket.
+type Policy struct {
+	Name  string
+	Rate  float64
+	Burst int
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// ParsePolicy reads specs of the form "<count>/<interval>[:<burst>]", e.g.
+// "30/1m" or "5/10s:10". The burst defaults to the count.
+func ParsePolicy(name, spec string) (Policy, error) {
+	rateSpec, burstSpec, hasBurst := strings.Cut(spec, ":")
+	countSpec, intervalSpec, ok := strings.Cut(rateSpec, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("invalid rate limit %q: expected <count>/<interval>", spec)
+	}
+	count, err := strconv.Atoi(countSpec)
+	if err != nil || count <= 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit count %q", countSpec)
+	}
+	interval, err := time.ParseDuration(intervalSpec)
+	if err != nil || interval <= 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit interval %q", intervalSpec)
+	}
+	burst := count
+	if hasBurst {
+		burst, err = strconv.Atoi(burstSpec)
+		if err != nil || burst <= 0 {
+			return Policy{}, fmt.Errorf("invalid rate limit burst %q", burstSpec)
+		}
+	}
+	return Policy{
+		Name:  name,
+		Rate:  float64(count) / interval.Seconds(),
+		Burst: burst,
+	}, nil
+}
+
+// Scale returns p with its rate and burst multiplied by factor, keeping at
+// least one token of burst.
+func (p Policy) Scale(factor float64) Policy {
+	p.Rate *= factor
+	p.Burst = max(1, int(math.Round(float64(p.Burst)*factor)))
+	return p
+}
+
+// Window is the time an empty bucket takes to refill completely.
+func (p Policy) Window() time.Duration {
+	return secondsToDuration(float64(p.Burst) / p.Rate)
+}
+
+// resultFor builds the caller-facing Result from the bucket's token count
+// after a take attempt.
+func resultFor(policy Policy, tokens float64, allowed bool) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     policy.Burst,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     secondsToDuration((float64(policy.Burst) - tokens) / policy.Rate),
+	}
+	if !allowed {
+		res.RetryAfter = secondsToDuration((1 - tokens) / policy.Rate)
+	}
+	return res
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,162 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/auth"
+)
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("chirps", "30/1m:10")
+	if err != nil {
+		t.Fatalf(`ParsePolicy("chirps", "30/1m:10") = %v, wanted nil`, err)
+	}
+	if policy.Rate != 0.5 || policy.Burst != 10 {
+		t.Fatalf(`ParsePolicy("chirps", "30/1m:10") = %+v, wanted rate 0.5 burst 10`, policy)
+	}
+	for _, spec := range []string{"30", "0/1m", "30/nope", "30/1m:-1"} {
+		if _, err := ParsePolicy("chirps", spec); err == nil {
+			t.Fatalf(`ParsePolicy("chirps", %q) = nil, wanted error`, spec)
+		}
+	}
+}
+
+func TestMemoryStoreBucket(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	policy := Policy{Name: "test", Rate: 1, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		res, _ := store.Take(context.Background(), "k", policy)
+		if !res.Allowed {
+			t.Fatalf(`Take #%d allowed = false, wanted true`, i)
+		}
+	}
+	res, _ := store.Take(context.Background(), "k", policy)
+	if res.Allowed || res.RetryAfter != time.Second {
+		t.Fatalf(`Take after burst = %+v, wanted denied with 1s retry`, res)
+	}
+
+	now = now.Add(time.Second)
+	res, _ = store.Take(context.Background(), "k", policy)
+	if !res.Allowed || res.Remaining != 0 {
+		t.Fatalf(`Take after refill = %+v, wanted allowed with 0 remaining`, res)
+	}
+
+	res, _ = store.Take(context.Background(), "other", policy)
+	if !res.Allowed || res.Remaining != 1 {
+		t.Fatalf(`Take on other key = %+v, wanted allowed with 1 remaining`, res)
+	}
+}
+
+func TestClientIPTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatalf(`ParseTrustedProxies = %v, wanted nil`, err)
+	}
+	resolver := PrincipalResolver{TrustedProxies: proxies}
+
+	rq := httptest.NewRequest("GET", "/", nil)
+	rq.RemoteAddr = "10.1.2.3:4567"
+	rq.Header.Set("X-Forwarded-For", "6.6.6.6, 1.2.3.4, 192.168.1.1")
+	if ip := resolver.ClientIP(rq); ip != "1.2.3.4" {
+		t.Fatalf(`ClientIP via trusted proxies = %q, wanted "1.2.3.4"`, ip)
+	}
+
+	rq.RemoteAddr = "8.8.8.8:4567"
+	if ip := resolver.ClientIP(rq); ip != "8.8.8.8" {
+		t.Fatalf(`ClientIP via untrusted peer = %q, wanted "8.8.8.8"`, ip)
+	}
+}
+
+func TestPrincipalPrefersJWTSubject(t *testing.T) {
+	userID := uuid.New()
+	token, _ := auth.MakeJWT(userID, "secret", time.Hour)
+	resolver := PrincipalResolver{Keys: auth.NewKeyring("secret")}
+
+	rq := httptest.NewRequest("POST", "/api/chirps", nil)
+	rq.Header.Set("Authorization", "Bearer "+token)
+	if key := resolver.Key(rq); key != "user:"+userID.String() {
+		t.Fatalf(`Key with valid JWT = %q, wanted user principal`, key)
+	}
+
+	rq.Header.Set("Authorization", "Bearer garbage")
+	if key := resolver.Key(rq); key != "ip:192.0.2.1" {
+		t.Fatalf(`Key with invalid JWT = %q, wanted "ip:192.0.2.1"`, key)
+	}
+}
+
+func TestAPIKeyDoesNotResetLimit(t *testing.T) {
+	resolver := PrincipalResolver{Keys: auth.NewKeyring("secret")}
+	limiter := &Limiter{Store: NewMemoryStore(), Principal: resolver.Key}
+	handler := limiter.Limit(Policy{Name: "auth", Rate: 0.1, Burst: 2}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+
+	for i := 0; i < 3; i++ {
+		rq := httptest.NewRequest("POST", "/api/login", nil)
+		rq.Header.Set("Authorization", "ApiKey "+uuid.NewString())
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, rq)
+		want := 200
+		if i == 2 {
+			want = 429
+		}
+		if rec.Code != want {
+			t.Fatalf(`request %d with a new API key = %d, wanted %d`, i+1, rec.Code, want)
+		}
+	}
+}
+
+func TestLimitMiddleware(t *testing.T) {
+	limiter := &Limiter{
+		Store:     NewMemoryStore(),
+		Principal: func(*http.Request) string { return "ip:1.2.3.4" },
+	}
+	handler := limiter.Limit(Policy{Name: "test", Rate: 0.1, Burst: 1}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+	if rec.Code != 200 || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf(`first request = %d remaining %q, wanted 200 remaining "0"`, rec.Code, rec.Header().Get("RateLimit-Remaining"))
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+	if rec.Code != 429 || rec.Header().Get("Retry-After") != "10" {
+		t.Fatalf(`second request = %d retry-after %q, wanted 429 retry-after "10"`, rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestLimitMultiplier(t *testing.T) {
+	limiter := &Limiter{
+		Store:      NewMemoryStore(),
+		Principal:  func(*http.Request) string { return "user:red" },
+		Multiplier: func(*http.Request) float64 { return 3 },
+	}
+	handler := limiter.Limit(Policy{Name: "test", Rate: 0.1, Burst: 1}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+		if rec.Code != 200 || rec.Header().Get("RateLimit-Limit") != "3" {
+			t.Fatalf(`request %d = %d limit %q, wanted 200 limit "3"`, i+1, rec.Code, rec.Header().Get("RateLimit-Limit"))
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+	if rec.Code != 429 {
+		t.Fatalf(`fourth request = %d, wanted 429`, rec.Code)
+	}
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jamistoso/chirpy/internal/database"
+)
+
+// MemoryStore keeps buckets in process memory. Limits are not shared between
+// Chirpy instances; use PostgresStore for that.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens   float64
+	updated  time.Time
+	idleTime time.Duration
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, policy Policy) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(policy.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.idleTime = policy.Window()
+
+	elapsed := now.Sub(b.updated).Seconds()
+	tokens := math.Min(float64(policy.Burst), b.tokens+elapsed*policy.Rate)
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	b.tokens = tokens
+	b.updated = now
+
+	return resultFor(policy, tokens, allowed), nil
+}
+
+// sweep drops buckets that have been idle long enough to be full again, since
+// a missing bucket behaves exactly like a full one.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) > b.idleTime {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// PostgresStore keeps buckets in the rate_limits table so that every Chirpy
+// instance pointed at the same database enforces the same limits.
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, policy Policy) (Result, error) {
+	row, err := s.db.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		Key:   key,
+		Burst: float64(policy.Burst),
+		Rate:  policy.Rate,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return resultFor(policy, row.Tokens, row.Allowed), nil
+}
+
+// Cleanup removes buckets untouched since before. Buckets idle for longer than
+// their policy window are full, so deleting them doesn't change any limit.
+func (s *PostgresStore) Cleanup(ctx context.Context, before time.Time) error {
+	return s.db.DeleteStaleRateLimits(ctx, before)
+}
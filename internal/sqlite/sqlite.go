@@ -0,0 +1,261 @@
+// Package sqlite runs Chirpy's sqlc queries on SQLite.
+//
+// The queries are written for Postgres. Rather than keep a second set, this
+// package registers a database/sql driver that wraps the pure-Go SQLite
+// driver and bridges the differences as statements go through:
+//
+//   - $N placeholders become ?N.
+//   - FOR UPDATE SKIP LOCKED is dropped; SQLite serialises writers anyway.
+//   - NOW() becomes an extra parameter bound to the time of the call, so as
+//     in Postgres every NOW() in a statement agrees.
+//   - gen_random_uuid() is provided as an SQL function.
+//   - time.Time arguments are written as fixed-width UTC text, the same form
+//     NOW() is bound as, so timestamps compare correctly as strings.
+//
+// The schema lives in sql/sqlite, mirroring sql/schema migration for
+// migration.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+)
+
+// DriverName is the database/sql driver registered by this package.
+const DriverName = "chirpy-sqlite"
+
+// TimeFormat is how timestamps are stored. Microseconds match Postgres'
+// TIMESTAMP precision.
+const TimeFormat = "2006-01-02 15:04:05.000000"
+
+func init() {
+	// The functions below are registered on the driver modernc.org/sqlite
+	// registers as "sqlite", so connections must come from that instance.
+	db, err := sql.Open("sqlite", "")
+	if err != nil {
+		panic(fmt.Sprintf("sqlite: %s", err))
+	}
+	sql.Register(DriverName, &Driver{base: db.Driver()})
+	db.Close()
+
+	err = sqlite.RegisterScalarFunction("gen_random_uuid", 0, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		return uuid.NewString(), nil
+	})
+	if err != nil {
+		panic(fmt.Sprintf("sqlite: registering gen_random_uuid(): %s", err))
+	}
+}
+
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+// IsURL reports whether dbURL names a SQLite database.
+func IsURL(dbURL string) bool {
+	return strings.HasPrefix(dbURL, "sqlite:")
+}
+
+// Open opens the database named by dbURL: "sqlite:" followed by a file path,
+// as in sqlite:chirpy.db or sqlite:///var/lib/chirpy/chirpy.db, or
+// sqlite::memory: for a throwaway in-memory database.
+//
+// The pool is limited to one connection. SQLite allows a single writer, and
+// with one connection a write waits its turn in the pool instead of failing
+// with SQLITE_BUSY; it also keeps an in-memory database alive and shared.
+func Open(dbURL string) (*sql.DB, error) {
+	if !IsURL(dbURL) {
+		return nil, fmt.Errorf("not a sqlite url: %q", dbURL)
+	}
+	path := strings.TrimPrefix(strings.TrimPrefix(dbURL, "sqlite:"), "//")
+	if path == "" {
+		return nil, fmt.Errorf("sqlite url has no path: %q", dbURL)
+	}
+
+	pragmas := "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if path != ":memory:" {
+		pragmas += "&_pragma=journal_mode(WAL)"
+	}
+	dsn := "file:" + path + "?" + pragmas
+
+	db, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+	return db, nil
+}
+
+var (
+	placeholder = regexp.MustCompile(`\$(\d+)`)
+	rowLocking  = regexp.MustCompile(`(?i)\s+FOR\s+UPDATE(\s+SKIP\s+LOCKED)?`)
+	nowCall     = regexp.MustCompile(`(?i)\bNOW\(\)`)
+
+	rewrites sync.Map
+)
+
+// query is a Postgres query translated to SQLite.
+type query struct {
+	sql string
+	// nowOrdinal is the parameter NOW() was rewritten to, or 0 if the query
+	// doesn't call it.
+	nowOrdinal int
+}
+
+// Rewrite translates a Postgres query to SQLite.
+func Rewrite(pgQuery string) string {
+	return rewrite(pgQuery).sql
+}
+
+func rewrite(pgQuery string) query {
+	if cached, ok := rewrites.Load(pgQuery); ok {
+		return cached.(query)
+	}
+
+	q := query{sql: pgQuery}
+	if nowCall.MatchString(q.sql) {
+		// One past the caller's parameters, which sqlc numbers from 1.
+		q.nowOrdinal = 1
+		for _, match := range placeholder.FindAllStringSubmatch(q.sql, -1) {
+			n, _ := strconv.Atoi(match[1])
+			q.nowOrdinal = max(q.nowOrdinal, n+1)
+		}
+		q.sql = nowCall.ReplaceAllString(q.sql, "?"+strconv.Itoa(q.nowOrdinal))
+	}
+	q.sql = placeholder.ReplaceAllString(q.sql, "?$1")
+	q.sql = rowLocking.ReplaceAllString(q.sql, "")
+
+	rewrites.Store(pgQuery, q)
+	return q
+}
+
+// args formats time arguments and, if the query called NOW(), binds it.
+func (q query) args(args []driver.NamedValue) []driver.NamedValue {
+	for i, arg := range args {
+		if t, ok := arg.Value.(time.Time); ok {
+			args[i].Value = FormatTime(t)
+		}
+	}
+	if q.nowOrdinal != 0 {
+		args = append(args, driver.NamedValue{
+			Ordinal: q.nowOrdinal,
+			Value:   FormatTime(time.Now()),
+		})
+	}
+	return args
+}
+
+// baseConn is what the wrapped SQLite driver's connections implement.
+type baseConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+type Driver struct {
+	base driver.Driver
+}
+
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	c, err := d.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	bc, ok := c.(baseConn)
+	if !ok {
+		c.Close()
+		return nil, fmt.Errorf("sqlite: unexpected connection type %T", c)
+	}
+	return &conn{base: bc}, nil
+}
+
+type conn struct {
+	base baseConn
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, pgQuery string) (driver.Stmt, error) {
+	q := rewrite(pgQuery)
+	s, err := c.base.PrepareContext(ctx, q.sql)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{base: s, query: q}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, pgQuery string, args []driver.NamedValue) (driver.Result, error) {
+	q := rewrite(pgQuery)
+	return c.base.ExecContext(ctx, q.sql, q.args(args))
+}
+
+func (c *conn) QueryContext(ctx context.Context, pgQuery string, args []driver.NamedValue) (driver.Rows, error) {
+	q := rewrite(pgQuery)
+	return c.base.QueryContext(ctx, q.sql, q.args(args))
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.base.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.base.BeginTx(ctx, opts)
+}
+
+func (c *conn) Close() error                           { return c.base.Close() }
+func (c *conn) Ping(ctx context.Context) error         { return c.base.Ping(ctx) }
+func (c *conn) ResetSession(ctx context.Context) error { return c.base.ResetSession(ctx) }
+func (c *conn) IsValid() bool                          { return c.base.IsValid() }
+
+type stmt struct {
+	base  driver.Stmt
+	query query
+}
+
+func (s *stmt) Close() error { return s.base.Close() }
+
+// NumInput opts out of database/sql's argument count check, since the
+// statement may have a NOW() parameter the caller doesn't pass.
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), named(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.base.(driver.StmtExecContext).ExecContext(ctx, s.query.args(args))
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.base.(driver.StmtQueryContext).QueryContext(ctx, s.query.args(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	out := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		out[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return out
+}
@@ -0,0 +1,253 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/migrate"
+	"github.com/jamistoso/chirpy/internal/store"
+	"github.com/jamistoso/chirpy/internal/store/storetest"
+)
+
+// openTestDB opens a fresh in-memory database with the sql/sqlite migrations
+// applied.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := Open("sqlite::memory:")
+	if err != nil {
+		t.Fatalf(`Open() = %v, wanted nil`, err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrate.New(db, migrate.SQLite, os.DirFS("../../sql/sqlite"))
+	if err != nil {
+		t.Fatalf(`migrate.New() = %v, wanted nil`, err)
+	}
+	_, err = m.Up(context.Background())
+	if err != nil {
+		t.Fatalf(`migrating: %v`, err)
+	}
+	return db
+}
+
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`SELECT * FROM users WHERE id = $1`, `SELECT * FROM users WHERE id = ?1`},
+		{`WHERE ($1 IS NULL OR a = $1) AND b = $12`, `WHERE (?1 IS NULL OR a = ?1) AND b = ?12`},
+		{"SELECT id FROM jobs\n    LIMIT $3\n    FOR UPDATE SKIP LOCKED\n)", "SELECT id FROM jobs\n    LIMIT ?3\n)"},
+		{`SELECT 1 FOR UPDATE`, `SELECT 1`},
+		{`SET updated_at = NOW(), revoked_at = now()`, `SET updated_at = ?1, revoked_at = ?1`},
+		{`VALUES (gen_random_uuid(), NOW(), $1, $2)`, `VALUES (gen_random_uuid(), ?3, ?1, ?2)`},
+	}
+	for _, test := range tests {
+		got := Rewrite(test.query)
+		if got != test.want {
+			t.Fatalf(`Rewrite(%q) = %q, wanted %q`, test.query, got, test.want)
+		}
+	}
+}
+
+func TestIsURL(t *testing.T) {
+	tests := map[string]bool{
+		"sqlite:chirpy.db":                            true,
+		"sqlite:///var/lib/chirpy.db":                 true,
+		"sqlite::memory:":                             true,
+		"postgres://chirpy@localhost:5432/chirpy":     false,
+		"postgres://localhost/chirpy?sslmode=disable": false,
+		"": false,
+	}
+	for dbURL, want := range tests {
+		if got := IsURL(dbURL); got != want {
+			t.Fatalf(`IsURL(%q) = %v, wanted %v`, dbURL, got, want)
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Backend {
+		db := openTestDB(t)
+		return store.SQL{Queries: database.New(db), DB: db}
+	})
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	db := openTestDB(t)
+	queries := database.New(db)
+	ctx := context.Background()
+
+	entry, err := queries.CreateAuditEntry(ctx, database.CreateAuditEntryParams{
+		ActorType:  "system",
+		Action:     "test.action",
+		TargetType: "user",
+		TargetID:   "someone",
+		Before:     json.RawMessage(`{}`),
+		After:      json.RawMessage(`{"a":1}`),
+	})
+	if err != nil {
+		t.Fatalf(`CreateAuditEntry() = %v, wanted nil`, err)
+	}
+	if string(entry.After) != `{"a":1}` {
+		t.Fatalf(`entry.After = %s, wanted {"a":1}`, entry.After)
+	}
+
+	entries, err := queries.ListAuditEntries(ctx, database.ListAuditEntriesParams{
+		Action:     sql.NullString{String: "test.action", Valid: true},
+		Since:      sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+		MaxEntries: 10,
+	})
+	if err != nil || len(entries) != 1 || entries[0].ID != entry.ID {
+		t.Fatalf(`ListAuditEntries() = %v, %v, wanted the entry`, entries, err)
+	}
+
+	_, err = db.Exec(`UPDATE audit_log SET action = 'changed'`)
+	if err == nil {
+		t.Fatalf(`UPDATE audit_log succeeded, wanted an error`)
+	}
+	_, err = db.Exec(`DELETE FROM audit_log`)
+	if err == nil {
+		t.Fatalf(`DELETE FROM audit_log succeeded, wanted an error`)
+	}
+}
+
+func TestSubscriptionsSyncChirpyRed(t *testing.T) {
+	queries := database.New(openTestDB(t))
+	ctx := context.Background()
+
+	user, err := queries.CreateUser(ctx, database.CreateUserParams{Email: "red@example.com", HashedPassword: "x"})
+	if err != nil {
+		t.Fatalf(`CreateUser() = %v, wanted nil`, err)
+	}
+	if user.IsChirpyRed.Bool {
+		t.Fatalf(`new user is Chirpy Red`)
+	}
+
+	periodEnd := time.Now().Add(-time.Hour)
+	sub, err := queries.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+		UserID:           user.ID,
+		Plan:             "red",
+		Status:           "active",
+		CurrentPeriodEnd: periodEnd,
+	})
+	if err != nil {
+		t.Fatalf(`UpsertSubscription() = %v, wanted nil`, err)
+	}
+	if !sub.CurrentPeriodEnd.Equal(periodEnd.UTC().Truncate(time.Microsecond)) {
+		t.Fatalf(`CurrentPeriodEnd = %v, wanted %v`, sub.CurrentPeriodEnd, periodEnd)
+	}
+	user, _ = queries.GetUserFromID(ctx, user.ID)
+	if !user.IsChirpyRed.Bool {
+		t.Fatalf(`user is not Chirpy Red after subscribing`)
+	}
+
+	expired, err := queries.ExpireLapsedSubscriptions(ctx, time.Now())
+	if err != nil || len(expired) != 1 {
+		t.Fatalf(`ExpireLapsedSubscriptions() = %v, %v, wanted one subscription`, expired, err)
+	}
+	user, _ = queries.GetUserFromID(ctx, user.ID)
+	if user.IsChirpyRed.Bool {
+		t.Fatalf(`user is still Chirpy Red after expiry`)
+	}
+}
+
+func TestClaimJobs(t *testing.T) {
+	queries := database.New(openTestDB(t))
+	ctx := context.Background()
+	now := time.Now()
+
+	due, err := queries.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind:        "test",
+		Payload:     json.RawMessage(`{"n":1}`),
+		MaxAttempts: 3,
+		RunAt:       now.Add(-time.Second),
+	})
+	if err != nil {
+		t.Fatalf(`EnqueueJob() = %v, wanted nil`, err)
+	}
+	_, err = queries.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind:        "test",
+		Payload:     json.RawMessage(`{"n":2}`),
+		MaxAttempts: 3,
+		RunAt:       now.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf(`EnqueueJob() = %v, wanted nil`, err)
+	}
+
+	oldest, err := queries.OldestDueJob(ctx, now)
+	if err != nil || !oldest.Equal(due.RunAt) {
+		t.Fatalf(`OldestDueJob() = %v, %v, wanted the due job's %v`, oldest, err, due.RunAt)
+	}
+
+	claimed, err := queries.ClaimJobs(ctx, database.ClaimJobsParams{
+		LeaseUntil: now.Add(time.Minute),
+		Now:        now,
+		MaxJobs:    10,
+	})
+	if err != nil {
+		t.Fatalf(`ClaimJobs() = %v, wanted nil`, err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != due.ID || claimed[0].Attempts != 1 {
+		t.Fatalf(`ClaimJobs() = %+v, wanted only the due job on its first attempt`, claimed)
+	}
+	if string(claimed[0].Payload) != `{"n":1}` {
+		t.Fatalf(`Payload = %s, wanted {"n":1}`, claimed[0].Payload)
+	}
+
+	// The lease hides it from a second claim.
+	claimed, err = queries.ClaimJobs(ctx, database.ClaimJobsParams{
+		LeaseUntil: now.Add(time.Minute),
+		Now:        now,
+		MaxJobs:    10,
+	})
+	if err != nil || len(claimed) != 0 {
+		t.Fatalf(`second ClaimJobs() = %v, %v, wanted nothing`, claimed, err)
+	}
+	_, err = queries.OldestDueJob(ctx, now)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf(`OldestDueJob() while leased = %v, wanted sql.ErrNoRows`, err)
+	}
+}
+
+func TestRecordPolkaEventOnce(t *testing.T) {
+	queries := database.New(openTestDB(t))
+	ctx := context.Background()
+
+	for i, want := range []int64{1, 0} {
+		n, err := queries.RecordPolkaEvent(ctx, database.RecordPolkaEventParams{ID: "evt_1", Event: "user.upgraded"})
+		if err != nil || n != want {
+			t.Fatalf(`RecordPolkaEvent() #%d = %d, %v, wanted %d`, i+1, n, err, want)
+		}
+	}
+}
+
+func TestGeneratedIDs(t *testing.T) {
+	queries := database.New(openTestDB(t))
+	ctx := context.Background()
+
+	user, err := queries.CreateUser(ctx, database.CreateUserParams{Email: "ids@example.com", HashedPassword: "x"})
+	if err != nil {
+		t.Fatalf(`CreateUser() = %v, wanted nil`, err)
+	}
+	if user.ID == uuid.Nil || user.ID.Version() != 4 {
+		t.Fatalf(`user.ID = %v, wanted a random uuid`, user.ID)
+	}
+	if time.Since(user.CreatedAt) > time.Minute || user.CreatedAt.Location() != time.UTC {
+		t.Fatalf(`user.CreatedAt = %v, wanted about now in UTC`, user.CreatedAt)
+	}
+
+	_, err = queries.GetUserFromID(ctx, uuid.New())
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf(`GetUserFromID(unknown) = %v, wanted sql.ErrNoRows`, err)
+	}
+}
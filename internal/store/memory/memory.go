@@ -0,0 +1,444 @@
+// Package memory is an in-process store.Store with the same semantics as the
+// SQL schema: unique emails, foreign keys that cascade on user deletion, and
+// the same visibility rules and ordering in chirp listings.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/store"
+)
+
+var (
+	errDuplicateEmail = errors.New("memory: duplicate key value violates unique constraint on users.email")
+	errDuplicateToken = errors.New("memory: duplicate key value violates unique constraint on refresh_tokens.token")
+	errNoSuchUser     = errors.New("memory: insert violates foreign key constraint on user_id")
+)
+
+type Store struct {
+	mu sync.RWMutex
+	// txMu runs transactions one at a time.
+	txMu sync.Mutex
+
+	users  map[uuid.UUID]database.User
+	emails map[string]uuid.UUID
+	chirps map[uuid.UUID]database.Chirp
+	// chirpOrder keeps insertion order so chirps created in the same instant
+	// still list deterministically.
+	chirpOrder []uuid.UUID
+	tokens     map[string]database.RefreshToken
+	// jobs are the jobs queued with EnqueueJob, which nothing runs.
+	jobs []database.Job
+
+	now func() time.Time
+}
+
+var _ store.Transactor = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		users:  map[uuid.UUID]database.User{},
+		emails: map[string]uuid.UUID{},
+		chirps: map[uuid.UUID]database.Chirp{},
+		tokens: map[string]database.RefreshToken{},
+		now:    time.Now,
+	}
+}
+
+// timestamp returns the current time at the precision Postgres stores.
+func (s *Store) timestamp() time.Time {
+	return s.now().UTC().Truncate(time.Microsecond)
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.emails[arg.Email]; ok {
+		return database.User{}, errDuplicateEmail
+	}
+	now := s.timestamp()
+	user := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		IsChirpyRed:    sql.NullBool{Bool: false, Valid: true},
+		State:          "active",
+	}
+	s.users[user.ID] = user
+	s.emails[user.Email] = user.ID
+	return user, nil
+}
+
+func (s *Store) GetUserFromEmail(ctx context.Context, email string) (database.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.emails[email]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return s.users[id], nil
+}
+
+func (s *Store) GetUserFromID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+// UpdatePasswordAndEmail leaves updated_at alone, as the query does.
+func (s *Store) UpdatePasswordAndEmail(ctx context.Context, arg database.UpdatePasswordAndEmailParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	if owner, ok := s.emails[arg.Email]; ok && owner != user.ID {
+		return database.User{}, errDuplicateEmail
+	}
+	delete(s.emails, user.Email)
+	user.Email = arg.Email
+	user.HashedPassword = arg.HashedPassword
+	s.users[user.ID] = user
+	s.emails[user.Email] = user.ID
+	return user, nil
+}
+
+func (s *Store) SetUserState(ctx context.Context, arg database.SetUserStateParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.State = arg.State
+	user.StateReason = arg.StateReason
+	user.StateExpiresAt = arg.StateExpiresAt
+	user.UpdatedAt = s.timestamp()
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) Reset(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = map[uuid.UUID]database.User{}
+	s.emails = map[string]uuid.UUID{}
+	s.tokens = map[string]database.RefreshToken{}
+	// Chirps without an author have nothing to cascade from.
+	var kept []uuid.UUID
+	for _, id := range s.chirpOrder {
+		if s.chirps[id].UserID.Valid {
+			delete(s.chirps, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.chirpOrder = kept
+	return nil
+}
+
+func (s *Store) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if arg.UserID.Valid {
+		if _, ok := s.users[arg.UserID.UUID]; !ok {
+			return database.Chirp{}, errNoSuchUser
+		}
+	}
+	now := s.timestamp()
+	chirp := database.Chirp{
+		ID:         uuid.New(),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Body:       arg.Body,
+		UserID:     arg.UserID,
+		Visibility: arg.Visibility,
+	}
+	s.chirps[chirp.ID] = chirp
+	s.chirpOrder = append(s.chirpOrder, chirp.ID)
+	return chirp, nil
+}
+
+func (s *Store) GetOneChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chirp, ok := s.chirps[id]
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+// listable is the visibility filter of the listings: visible chirps by
+// authors who aren't shadow banned, plus everything the viewer wrote.
+func (s *Store) listable(chirp database.Chirp, viewerID uuid.NullUUID, now time.Time) bool {
+	if viewerID.Valid && chirp.UserID.Valid && chirp.UserID.UUID == viewerID.UUID {
+		return true
+	}
+	if chirp.Visibility != "visible" {
+		return false
+	}
+	author, ok := s.users[chirp.UserID.UUID]
+	if !chirp.UserID.Valid || !ok {
+		return true
+	}
+	shadowBanned := author.State == "shadow_banned" &&
+		(!author.StateExpiresAt.Valid || author.StateExpiresAt.Time.After(now))
+	return !shadowBanned
+}
+
+// chirpsWhere returns the chirps matching keep, oldest first.
+func (s *Store) chirpsWhere(keep func(database.Chirp) bool) []database.Chirp {
+	chirps := []database.Chirp{}
+	for _, id := range s.chirpOrder {
+		if chirp := s.chirps[id]; keep(chirp) {
+			chirps = append(chirps, chirp)
+		}
+	}
+	slices.SortStableFunc(chirps, func(a, b database.Chirp) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return chirps
+}
+
+// compareKeys orders chirps the way the listings do: by creation time, then
+// by ID, compared bytewise as Postgres compares UUIDs.
+func compareKeys(createdAt time.Time, id uuid.UUID, otherCreatedAt time.Time, otherID uuid.UUID) int {
+	if c := createdAt.Compare(otherCreatedAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(id[:], otherID[:])
+}
+
+// list returns up to max listable chirps for arg, the first of them sorting
+// after arg's key in the order of direction, 1 or -1.
+func (s *Store) list(arg database.ListChirpsParams, direction int) []database.Chirp {
+	now := s.timestamp()
+	chirps := s.chirpsWhere(func(chirp database.Chirp) bool {
+		return (!arg.AuthorID.Valid || chirp.UserID == arg.AuthorID) &&
+			s.listable(chirp, arg.ViewerID, now) &&
+			compareKeys(chirp.CreatedAt, chirp.ID, arg.AfterCreatedAt, arg.AfterID)*direction > 0
+	})
+	slices.SortFunc(chirps, func(a, b database.Chirp) int {
+		return compareKeys(a.CreatedAt, a.ID, b.CreatedAt, b.ID) * direction
+	})
+	if len(chirps) > int(arg.MaxChirps) {
+		chirps = chirps[:max(arg.MaxChirps, 0)]
+	}
+	return chirps
+}
+
+func (s *Store) ListChirps(ctx context.Context, arg database.ListChirpsParams) ([]database.Chirp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.list(arg, 1), nil
+}
+
+func (s *Store) ListChirpsDesc(ctx context.Context, arg database.ListChirpsDescParams) ([]database.Chirp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.list(database.ListChirpsParams(arg), -1), nil
+}
+
+// GetRecentChirpsFromAuthor returns newest first, unlike the listings.
+func (s *Store) GetRecentChirpsFromAuthor(ctx context.Context, arg database.GetRecentChirpsFromAuthorParams) ([]database.Chirp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chirps := s.chirpsWhere(func(chirp database.Chirp) bool {
+		return arg.UserID.Valid && chirp.UserID == arg.UserID && chirp.CreatedAt.After(arg.Since)
+	})
+	slices.Reverse(chirps)
+	return chirps, nil
+}
+
+func (s *Store) UpdateChirpBody(ctx context.Context, arg database.UpdateChirpBodyParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chirp, ok := s.chirps[arg.ID]
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	chirp.Body = arg.Body
+	chirp.Visibility = arg.Visibility
+	chirp.UpdatedAt = s.timestamp()
+	s.chirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+func (s *Store) SetChirpVisibility(ctx context.Context, arg database.SetChirpVisibilityParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chirp, ok := s.chirps[arg.ID]
+	if !ok {
+		return nil
+	}
+	chirp.Visibility = arg.Visibility
+	chirp.UpdatedAt = s.timestamp()
+	s.chirps[chirp.ID] = chirp
+	return nil
+}
+
+func (s *Store) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.chirps[id]; !ok {
+		return nil
+	}
+	delete(s.chirps, id)
+	s.chirpOrder = slices.DeleteFunc(s.chirpOrder, func(other uuid.UUID) bool { return other == id })
+	return nil
+}
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tokens[arg.Token]; ok {
+		return database.RefreshToken{}, errDuplicateToken
+	}
+	if arg.UserID.Valid {
+		if _, ok := s.users[arg.UserID.UUID]; !ok {
+			return database.RefreshToken{}, errNoSuchUser
+		}
+	}
+	now := s.timestamp()
+	token := database.RefreshToken{
+		Token:     arg.Token,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: arg.ExpiresAt.UTC().Truncate(time.Microsecond),
+		UserID:    arg.UserID,
+	}
+	s.tokens[token.Token] = token
+	return token, nil
+}
+
+func (s *Store) GetUserFromRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	refreshToken, ok := s.tokens[token]
+	if !ok {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return refreshToken, nil
+}
+
+func (s *Store) RevokeRefreshToken(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refreshToken, ok := s.tokens[token]
+	if !ok {
+		return nil
+	}
+	now := s.timestamp()
+	refreshToken.UpdatedAt = now
+	refreshToken.RevokedAt = sql.NullTime{Time: now, Valid: true}
+	s.tokens[token] = refreshToken
+	return nil
+}
+
+func (s *Store) EnqueueJob(ctx context.Context, arg database.EnqueueJobParams) (database.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.timestamp()
+	job := database.Job{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Kind:        arg.Kind,
+		Payload:     arg.Payload,
+		Status:      "queued",
+		MaxAttempts: arg.MaxAttempts,
+		RunAt:       arg.RunAt.UTC().Truncate(time.Microsecond),
+	}
+	s.jobs = append(s.jobs, job)
+	return job, nil
+}
+
+// Jobs returns the jobs queued so far, oldest first.
+func (s *Store) Jobs() []database.Job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.jobs)
+}
+
+// InTx runs fn against the store itself and undoes its changes if it fails.
+// Transactions run one at a time, but nothing keeps other callers from
+// changing the store meanwhile, and a rollback undoes their changes too; the
+// store is for tests, which don't do that.
+func (s *Store) InTx(ctx context.Context, fn func(tx store.Tx) error) error {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	s.mu.RLock()
+	saved := s.snapshot()
+	s.mu.RUnlock()
+
+	err := fn(s)
+	if err != nil {
+		s.mu.Lock()
+		s.restore(saved)
+		s.mu.Unlock()
+	}
+	return err
+}
+
+type snapshot struct {
+	users      map[uuid.UUID]database.User
+	emails     map[string]uuid.UUID
+	chirps     map[uuid.UUID]database.Chirp
+	chirpOrder []uuid.UUID
+	tokens     map[string]database.RefreshToken
+	jobs       []database.Job
+}
+
+func (s *Store) snapshot() snapshot {
+	return snapshot{
+		users:      maps.Clone(s.users),
+		emails:     maps.Clone(s.emails),
+		chirps:     maps.Clone(s.chirps),
+		chirpOrder: slices.Clone(s.chirpOrder),
+		tokens:     maps.Clone(s.tokens),
+		jobs:       slices.Clone(s.jobs),
+	}
+}
+
+func (s *Store) restore(saved snapshot) {
+	s.users = saved.users
+	s.emails = saved.emails
+	s.chirps = saved.chirps
+	s.chirpOrder = saved.chirpOrder
+	s.tokens = saved.tokens
+	s.jobs = saved.jobs
+}
@@ -0,0 +1,28 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/store"
+	"github.com/jamistoso/chirpy/internal/store/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Backend {
+		return New()
+	})
+}
+
+func TestRollbackDropsJobs(t *testing.T) {
+	s := New()
+	s.InTx(context.Background(), func(tx store.Tx) error {
+		tx.EnqueueJob(context.Background(), database.EnqueueJobParams{Kind: "greet"})
+		return errors.New("failed")
+	})
+	if jobs := s.Jobs(); len(jobs) != 0 {
+		t.Fatalf(`Jobs() after a rollback = %v, wanted none`, jobs)
+	}
+}
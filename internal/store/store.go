@@ -0,0 +1,108 @@
+// Package store defines the storage Chirpy's handlers depend on for users,
+// chirps and refresh tokens.
+//
+// The method sets mirror the sqlc queries, so *database.Queries is the SQL
+// implementation as-is; memory.Store is an in-process one for tests. Both are
+// held to the same behaviour by the storetest conformance suite. Lookups of a
+// missing row return sql.ErrNoRows from either.
+//
+// Only users, chirps, refresh tokens and the jobs queued with their changes
+// go through these interfaces. Moderation decisions, the audit log, Polka
+// events, subscriptions, webhooks and signing keys are still read and
+// written with *database.Queries, so a server needs a database for those
+// whichever store backs the rest.
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/database"
+)
+
+type UserStore interface {
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error)
+	GetUserFromEmail(ctx context.Context, email string) (database.User, error)
+	GetUserFromID(ctx context.Context, id uuid.UUID) (database.User, error)
+	UpdatePasswordAndEmail(ctx context.Context, arg database.UpdatePasswordAndEmailParams) (database.User, error)
+	SetUserState(ctx context.Context, arg database.SetUserStateParams) (database.User, error)
+	// Reset deletes every user, and with them their chirps and tokens.
+	Reset(ctx context.Context) error
+}
+
+type ChirpStore interface {
+	CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error)
+	GetOneChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	// ListChirps pages through chirps oldest first, after the chirp created
+	// at AfterCreatedAt with AfterID; chirps created in the same instant
+	// are ordered by ID. ListChirpsDesc pages newest first, before it.
+	ListChirps(ctx context.Context, arg database.ListChirpsParams) ([]database.Chirp, error)
+	ListChirpsDesc(ctx context.Context, arg database.ListChirpsDescParams) ([]database.Chirp, error)
+	GetRecentChirpsFromAuthor(ctx context.Context, arg database.GetRecentChirpsFromAuthorParams) ([]database.Chirp, error)
+	UpdateChirpBody(ctx context.Context, arg database.UpdateChirpBodyParams) (database.Chirp, error)
+	SetChirpVisibility(ctx context.Context, arg database.SetChirpVisibilityParams) error
+	DeleteChirp(ctx context.Context, id uuid.UUID) error
+}
+
+type TokenStore interface {
+	CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error)
+	GetUserFromRefreshToken(ctx context.Context, token string) (database.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, token string) error
+}
+
+// Store is all of the above, backed by one database so that deleting a user
+// cascades to their chirps and tokens.
+type Store interface {
+	UserStore
+	ChirpStore
+	TokenStore
+}
+
+// Tx is a Store whose changes are part of a transaction, along with any jobs
+// queued through it, so that a job only runs if the change it was queued
+// with commits.
+type Tx interface {
+	Store
+	EnqueueJob(ctx context.Context, arg database.EnqueueJobParams) (database.Job, error)
+}
+
+// Transactor runs fn in a transaction, which is committed if fn returns nil
+// and rolled back otherwise.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(tx Tx) error) error
+}
+
+// SQL is the Store and Transactor for a database/sql database.
+type SQL struct {
+	*database.Queries
+	DB *sql.DB
+	// WithTx returns the queries to run in tx. When it is nil, they are
+	// database.New(tx); set it to wrap tx the way Queries' database is
+	// wrapped, for tracing say.
+	WithTx func(tx *sql.Tx) *database.Queries
+}
+
+func (s SQL) InTx(ctx context.Context, fn func(tx Tx) error) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queries := database.New(tx)
+	if s.WithTx != nil {
+		queries = s.WithTx(tx)
+	}
+	err = fn(queries)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+var (
+	_ Store      = (*database.Queries)(nil)
+	_ Tx         = (*database.Queries)(nil)
+	_ Transactor = SQL{}
+)
@@ -0,0 +1,36 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/store"
+	"github.com/jamistoso/chirpy/internal/store/storetest"
+	_ "github.com/lib/pq"
+)
+
+// TestPostgresConformance runs the suite against the sqlc queries. It needs a
+// migrated database it is allowed to empty, named by CHIRPY_TEST_DB_URL.
+func TestPostgresConformance(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf(`sql.Open() = %v, wanted nil`, err)
+	}
+	defer db.Close()
+
+	sqlStore := store.SQL{Queries: database.New(db), DB: db}
+	storetest.Run(t, func(t *testing.T) storetest.Backend {
+		err := sqlStore.Reset(context.Background())
+		if err != nil {
+			t.Fatalf(`Reset() = %v, wanted nil`, err)
+		}
+		return sqlStore
+	})
+}
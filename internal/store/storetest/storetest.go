@@ -0,0 +1,532 @@
+// Package storetest is a conformance suite for store.Store implementations.
+// Every implementation must pass it so handlers behave the same whichever
+// backend they run on.
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/store"
+)
+
+// Backend is a store that can run transactions, as the server needs.
+type Backend interface {
+	store.Store
+	store.Transactor
+}
+
+// Run runs the suite. newStore is called once per test and must return an
+// empty store; tests don't run in parallel, so a shared database can be
+// emptied with Reset.
+func Run(t *testing.T, newStore func(t *testing.T) Backend) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Store)
+	}{
+		{"UserLookups", testUserLookups},
+		{"UniqueEmail", testUniqueEmail},
+		{"UpdatePasswordAndEmail", testUpdatePasswordAndEmail},
+		{"SetUserState", testSetUserState},
+		{"MissingRows", testMissingRows},
+		{"ChirpForeignKey", testChirpForeignKey},
+		{"ChirpOrdering", testChirpOrdering},
+		{"ChirpPages", testChirpPages},
+		{"ChirpVisibility", testChirpVisibility},
+		{"ShadowBan", testShadowBan},
+		{"RecentChirps", testRecentChirps},
+		{"UpdateAndDeleteChirp", testUpdateAndDeleteChirp},
+		{"RefreshTokens", testRefreshTokens},
+		{"ResetCascades", testResetCascades},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+	t.Run("Transactions", func(t *testing.T) {
+		testTransactions(t, newStore(t))
+	})
+}
+
+func createUser(t *testing.T, s store.Store, email string) database.User {
+	t.Helper()
+	user, err := s.CreateUser(context.Background(), database.CreateUserParams{
+		Email:          email,
+		HashedPassword: "hash",
+	})
+	if err != nil {
+		t.Fatalf(`CreateUser(%q) = %v, wanted nil`, email, err)
+	}
+	return user
+}
+
+func createChirp(t *testing.T, s store.Store, author database.User, body, visibility string) database.Chirp {
+	t.Helper()
+	chirp, err := s.CreateChirp(context.Background(), database.CreateChirpParams{
+		Body:       body,
+		UserID:     uuid.NullUUID{UUID: author.ID, Valid: true},
+		Visibility: visibility,
+	})
+	if err != nil {
+		t.Fatalf(`CreateChirp(%q) = %v, wanted nil`, body, err)
+	}
+	// Keep created_at strictly increasing so ordering assertions hold on
+	// backends with coarse clocks.
+	time.Sleep(2 * time.Millisecond)
+	return chirp
+}
+
+func bodies(chirps []database.Chirp) []string {
+	var out []string
+	for _, chirp := range chirps {
+		out = append(out, chirp.Body)
+	}
+	return out
+}
+
+func assertBodies(t *testing.T, name string, chirps []database.Chirp, want ...string) {
+	t.Helper()
+	got := bodies(chirps)
+	if len(got) != len(want) {
+		t.Fatalf(`%s = %q, wanted %q`, name, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf(`%s = %q, wanted %q`, name, got, want)
+		}
+	}
+}
+
+func viewer(user database.User) uuid.NullUUID {
+	return uuid.NullUUID{UUID: user.ID, Valid: true}
+}
+
+// listChirps lists every chirp by author, or by anyone if author is null,
+// oldest first, as viewerID sees them.
+func listChirps(s store.Store, author, viewerID uuid.NullUUID) ([]database.Chirp, error) {
+	return s.ListChirps(context.Background(), database.ListChirpsParams{
+		AuthorID:  author,
+		ViewerID:  viewerID,
+		MaxChirps: math.MaxInt32,
+	})
+}
+
+func testUserLookups(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "walt@example.com")
+	if user.ID == uuid.Nil || user.CreatedAt.IsZero() || !user.UpdatedAt.Equal(user.CreatedAt) {
+		t.Fatalf(`CreateUser() = %+v, wanted ID and matching timestamps`, user)
+	}
+	if user.State != "active" || user.StateReason != "" || user.StateExpiresAt.Valid || user.IsAdmin {
+		t.Fatalf(`CreateUser() = %+v, wanted active non-admin`, user)
+	}
+	if user.IsChirpyRed.Bool {
+		t.Fatalf(`CreateUser() is_chirpy_red = true, wanted false`)
+	}
+
+	byEmail, err := s.GetUserFromEmail(ctx, "walt@example.com")
+	if err != nil || byEmail.ID != user.ID || byEmail.HashedPassword != "hash" {
+		t.Fatalf(`GetUserFromEmail() = %+v, %v, wanted %v`, byEmail, err, user.ID)
+	}
+	byID, err := s.GetUserFromID(ctx, user.ID)
+	if err != nil || byID.Email != user.Email {
+		t.Fatalf(`GetUserFromID() = %+v, %v, wanted %q`, byID, err, user.Email)
+	}
+}
+
+func testUniqueEmail(t *testing.T, s store.Store) {
+	createUser(t, s, "walt@example.com")
+	_, err := s.CreateUser(context.Background(), database.CreateUserParams{Email: "walt@example.com", HashedPassword: "x"})
+	if err == nil {
+		t.Fatal(`CreateUser(duplicate email) = nil, wanted error`)
+	}
+}
+
+func testUpdatePasswordAndEmail(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@example.com")
+	createUser(t, s, "jesse@example.com")
+
+	updated, err := s.UpdatePasswordAndEmail(ctx, database.UpdatePasswordAndEmailParams{
+		HashedPassword: "new-hash",
+		Email:          "heisenberg@example.com",
+		ID:             walt.ID,
+	})
+	if err != nil || updated.Email != "heisenberg@example.com" || updated.HashedPassword != "new-hash" {
+		t.Fatalf(`UpdatePasswordAndEmail() = %+v, %v, wanted new email and hash`, updated, err)
+	}
+	if _, err := s.GetUserFromEmail(ctx, "walt@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf(`GetUserFromEmail(old email) = %v, wanted sql.ErrNoRows`, err)
+	}
+	if _, err := s.GetUserFromEmail(ctx, "heisenberg@example.com"); err != nil {
+		t.Fatalf(`GetUserFromEmail(new email) = %v, wanted nil`, err)
+	}
+
+	_, err = s.UpdatePasswordAndEmail(ctx, database.UpdatePasswordAndEmailParams{
+		HashedPassword: "x",
+		Email:          "jesse@example.com",
+		ID:             walt.ID,
+	})
+	if err == nil {
+		t.Fatal(`UpdatePasswordAndEmail(taken email) = nil, wanted error`)
+	}
+
+	// Keeping your own email is not a conflict.
+	_, err = s.UpdatePasswordAndEmail(ctx, database.UpdatePasswordAndEmailParams{
+		HashedPassword: "x",
+		Email:          "heisenberg@example.com",
+		ID:             walt.ID,
+	})
+	if err != nil {
+		t.Fatalf(`UpdatePasswordAndEmail(same email) = %v, wanted nil`, err)
+	}
+}
+
+func testSetUserState(t *testing.T, s store.Store) {
+	user := createUser(t, s, "walt@example.com")
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	updated, err := s.SetUserState(context.Background(), database.SetUserStateParams{
+		State:          "suspended",
+		StateReason:    "spam",
+		StateExpiresAt: sql.NullTime{Time: expires, Valid: true},
+		ID:             user.ID,
+	})
+	if err != nil {
+		t.Fatalf(`SetUserState() = %v, wanted nil`, err)
+	}
+	if updated.State != "suspended" || updated.StateReason != "spam" || !updated.StateExpiresAt.Time.Equal(expires) {
+		t.Fatalf(`SetUserState() = %+v, wanted suspended for spam until %v`, updated, expires)
+	}
+	if updated.UpdatedAt.Before(user.UpdatedAt) {
+		t.Fatalf(`SetUserState() updated_at = %v, wanted at or after %v`, updated.UpdatedAt, user.UpdatedAt)
+	}
+}
+
+func testMissingRows(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	missing := uuid.New()
+	checks := map[string]error{}
+	_, checks["GetUserFromEmail"] = s.GetUserFromEmail(ctx, "nobody@example.com")
+	_, checks["GetUserFromID"] = s.GetUserFromID(ctx, missing)
+	_, checks["UpdatePasswordAndEmail"] = s.UpdatePasswordAndEmail(ctx, database.UpdatePasswordAndEmailParams{Email: "x@example.com", ID: missing})
+	_, checks["SetUserState"] = s.SetUserState(ctx, database.SetUserStateParams{State: "active", ID: missing})
+	_, checks["GetOneChirp"] = s.GetOneChirp(ctx, missing)
+	_, checks["UpdateChirpBody"] = s.UpdateChirpBody(ctx, database.UpdateChirpBodyParams{Body: "x", Visibility: "visible", ID: missing})
+	_, checks["GetUserFromRefreshToken"] = s.GetUserFromRefreshToken(ctx, "missing")
+	for name, err := range checks {
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf(`%s(missing) = %v, wanted sql.ErrNoRows`, name, err)
+		}
+	}
+
+	// Writes that match nothing are not errors.
+	if err := s.DeleteChirp(ctx, missing); err != nil {
+		t.Fatalf(`DeleteChirp(missing) = %v, wanted nil`, err)
+	}
+	if err := s.SetChirpVisibility(ctx, database.SetChirpVisibilityParams{Visibility: "held", ID: missing}); err != nil {
+		t.Fatalf(`SetChirpVisibility(missing) = %v, wanted nil`, err)
+	}
+	if err := s.RevokeRefreshToken(ctx, "missing"); err != nil {
+		t.Fatalf(`RevokeRefreshToken(missing) = %v, wanted nil`, err)
+	}
+}
+
+func testChirpForeignKey(t *testing.T, s store.Store) {
+	_, err := s.CreateChirp(context.Background(), database.CreateChirpParams{
+		Body:       "orphan",
+		UserID:     uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		Visibility: "visible",
+	})
+	if err == nil {
+		t.Fatal(`CreateChirp(unknown user) = nil, wanted error`)
+	}
+}
+
+func testChirpOrdering(t *testing.T, s store.Store) {
+	walt := createUser(t, s, "walt@example.com")
+	jesse := createUser(t, s, "jesse@example.com")
+	createChirp(t, s, walt, "one", "visible")
+	createChirp(t, s, jesse, "two", "visible")
+	createChirp(t, s, walt, "three", "visible")
+
+	all, err := listChirps(s, uuid.NullUUID{}, uuid.NullUUID{})
+	if err != nil {
+		t.Fatalf(`ListChirps() = %v, wanted nil`, err)
+	}
+	assertBodies(t, "ListChirps()", all, "one", "two", "three")
+
+	fromWalt, err := listChirps(s, viewer(walt), uuid.NullUUID{})
+	if err != nil {
+		t.Fatalf(`ListChirps(author) = %v, wanted nil`, err)
+	}
+	assertBodies(t, "ListChirps(walt)", fromWalt, "one", "three")
+}
+
+func testChirpPages(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@example.com")
+	var chirps []database.Chirp
+	for _, body := range []string{"one", "two", "three", "four", "five"} {
+		chirps = append(chirps, createChirp(t, s, walt, body, "visible"))
+	}
+
+	first, err := s.ListChirps(ctx, database.ListChirpsParams{MaxChirps: 2})
+	if err != nil {
+		t.Fatalf(`ListChirps(first page) = %v, wanted nil`, err)
+	}
+	assertBodies(t, "ListChirps(first page)", first, "one", "two")
+	next, _ := s.ListChirps(ctx, database.ListChirpsParams{
+		AfterCreatedAt: first[1].CreatedAt,
+		AfterID:        first[1].ID,
+		MaxChirps:      2,
+	})
+	assertBodies(t, "ListChirps(after two)", next, "three", "four")
+
+	newest, err := s.ListChirpsDesc(ctx, database.ListChirpsDescParams{
+		AfterCreatedAt: time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC),
+		AfterID:        uuid.Max,
+		MaxChirps:      2,
+	})
+	if err != nil {
+		t.Fatalf(`ListChirpsDesc(first page) = %v, wanted nil`, err)
+	}
+	assertBodies(t, "ListChirpsDesc(first page)", newest, "five", "four")
+
+	// A page can start after a chirp that has since been deleted.
+	err = s.DeleteChirp(ctx, chirps[3].ID)
+	if err != nil {
+		t.Fatalf(`DeleteChirp() = %v, wanted nil`, err)
+	}
+	older, _ := s.ListChirpsDesc(ctx, database.ListChirpsDescParams{
+		AfterCreatedAt: chirps[3].CreatedAt,
+		AfterID:        chirps[3].ID,
+		MaxChirps:      2,
+	})
+	assertBodies(t, "ListChirpsDesc(before deleted four)", older, "three", "two")
+}
+
+func testChirpVisibility(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@example.com")
+	jesse := createUser(t, s, "jesse@example.com")
+	createChirp(t, s, walt, "visible", "visible")
+	createChirp(t, s, walt, "held", "held")
+	createChirp(t, s, walt, "hidden", "hidden")
+
+	anonymous, _ := listChirps(s, uuid.NullUUID{}, uuid.NullUUID{})
+	assertBodies(t, "ListChirps(anonymous)", anonymous, "visible")
+	other, _ := listChirps(s, uuid.NullUUID{}, viewer(jesse))
+	assertBodies(t, "ListChirps(other user)", other, "visible")
+	own, _ := listChirps(s, uuid.NullUUID{}, viewer(walt))
+	assertBodies(t, "ListChirps(author)", own, "visible", "held", "hidden")
+
+	fromWalt, _ := listChirps(s, viewer(walt), viewer(jesse))
+	assertBodies(t, "ListChirps(walt, other user)", fromWalt, "visible")
+	fromWalt, _ = listChirps(s, viewer(walt), viewer(walt))
+	assertBodies(t, "ListChirps(walt, walt)", fromWalt, "visible", "held", "hidden")
+
+	// Visibility only filters listings; a direct lookup finds anything.
+	held, _ := listChirps(s, viewer(walt), viewer(walt))
+	if _, err := s.GetOneChirp(ctx, held[1].ID); err != nil {
+		t.Fatalf(`GetOneChirp(held) = %v, wanted nil`, err)
+	}
+}
+
+func testShadowBan(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@example.com")
+	jesse := createUser(t, s, "jesse@example.com")
+	createChirp(t, s, walt, "from walt", "visible")
+	createChirp(t, s, jesse, "from jesse", "visible")
+
+	_, err := s.SetUserState(ctx, database.SetUserStateParams{State: "shadow_banned", ID: walt.ID})
+	if err != nil {
+		t.Fatalf(`SetUserState() = %v, wanted nil`, err)
+	}
+	others, _ := listChirps(s, uuid.NullUUID{}, viewer(jesse))
+	assertBodies(t, "ListChirps(shadow banned author)", others, "from jesse")
+	own, _ := listChirps(s, uuid.NullUUID{}, viewer(walt))
+	assertBodies(t, "ListChirps(shadow banned viewer)", own, "from walt", "from jesse")
+
+	// An expired ban no longer hides anything.
+	_, err = s.SetUserState(ctx, database.SetUserStateParams{
+		State:          "shadow_banned",
+		StateExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Hour).UTC(), Valid: true},
+		ID:             walt.ID,
+	})
+	if err != nil {
+		t.Fatalf(`SetUserState() = %v, wanted nil`, err)
+	}
+	others, _ = listChirps(s, uuid.NullUUID{}, viewer(jesse))
+	assertBodies(t, "ListChirps(expired shadow ban)", others, "from walt", "from jesse")
+}
+
+func testRecentChirps(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@example.com")
+	jesse := createUser(t, s, "jesse@example.com")
+	old := createChirp(t, s, walt, "old", "visible")
+	createChirp(t, s, walt, "new", "held")
+	createChirp(t, s, jesse, "other", "visible")
+
+	recent, err := s.GetRecentChirpsFromAuthor(ctx, database.GetRecentChirpsFromAuthorParams{
+		UserID: viewer(walt),
+		Since:  old.CreatedAt,
+	})
+	if err != nil {
+		t.Fatalf(`GetRecentChirpsFromAuthor() = %v, wanted nil`, err)
+	}
+	assertBodies(t, "GetRecentChirpsFromAuthor(since old)", recent, "new")
+
+	recent, _ = s.GetRecentChirpsFromAuthor(ctx, database.GetRecentChirpsFromAuthorParams{
+		UserID: viewer(walt),
+		Since:  old.CreatedAt.Add(-time.Hour),
+	})
+	assertBodies(t, "GetRecentChirpsFromAuthor(last hour)", recent, "new", "old")
+}
+
+func testUpdateAndDeleteChirp(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@example.com")
+	chirp := createChirp(t, s, walt, "draft", "visible")
+
+	updated, err := s.UpdateChirpBody(ctx, database.UpdateChirpBodyParams{Body: "final", Visibility: "held", ID: chirp.ID})
+	if err != nil || updated.Body != "final" || updated.Visibility != "held" || !updated.CreatedAt.Equal(chirp.CreatedAt) {
+		t.Fatalf(`UpdateChirpBody() = %+v, %v, wanted held "final" with the original created_at`, updated, err)
+	}
+	if updated.UpdatedAt.Before(chirp.UpdatedAt) {
+		t.Fatalf(`UpdateChirpBody() updated_at = %v, wanted at or after %v`, updated.UpdatedAt, chirp.UpdatedAt)
+	}
+
+	err = s.SetChirpVisibility(ctx, database.SetChirpVisibilityParams{Visibility: "visible", ID: chirp.ID})
+	if err != nil {
+		t.Fatalf(`SetChirpVisibility() = %v, wanted nil`, err)
+	}
+	got, _ := s.GetOneChirp(ctx, chirp.ID)
+	if got.Visibility != "visible" {
+		t.Fatalf(`visibility after SetChirpVisibility = %q, wanted "visible"`, got.Visibility)
+	}
+
+	err = s.DeleteChirp(ctx, chirp.ID)
+	if err != nil {
+		t.Fatalf(`DeleteChirp() = %v, wanted nil`, err)
+	}
+	if _, err := s.GetOneChirp(ctx, chirp.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf(`GetOneChirp(deleted) = %v, wanted sql.ErrNoRows`, err)
+	}
+	all, _ := listChirps(s, uuid.NullUUID{}, viewer(walt))
+	assertBodies(t, "ListChirps(after delete)", all)
+}
+
+func testRefreshTokens(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@example.com")
+	expires := time.Now().Add(60 * 24 * time.Hour).UTC().Truncate(time.Second)
+
+	token, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     "abc",
+		UserID:    viewer(walt),
+		ExpiresAt: expires,
+	})
+	if err != nil || token.Token != "abc" || token.RevokedAt.Valid || !token.ExpiresAt.Equal(expires) {
+		t.Fatalf(`CreateRefreshToken() = %+v, %v, wanted unrevoked token expiring %v`, token, err, expires)
+	}
+
+	_, err = s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "abc", UserID: viewer(walt), ExpiresAt: expires})
+	if err == nil {
+		t.Fatal(`CreateRefreshToken(duplicate) = nil, wanted error`)
+	}
+	_, err = s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     "orphan",
+		UserID:    uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		ExpiresAt: expires,
+	})
+	if err == nil {
+		t.Fatal(`CreateRefreshToken(unknown user) = nil, wanted error`)
+	}
+
+	err = s.RevokeRefreshToken(ctx, "abc")
+	if err != nil {
+		t.Fatalf(`RevokeRefreshToken() = %v, wanted nil`, err)
+	}
+	got, err := s.GetUserFromRefreshToken(ctx, "abc")
+	if err != nil || !got.RevokedAt.Valid || got.UserID.UUID != walt.ID {
+		t.Fatalf(`GetUserFromRefreshToken(revoked) = %+v, %v, wanted revoked token for %v`, got, err, walt.ID)
+	}
+}
+
+func testResetCascades(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@example.com")
+	chirp := createChirp(t, s, walt, "gone soon", "visible")
+	_, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     "abc",
+		UserID:    viewer(walt),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf(`CreateRefreshToken() = %v, wanted nil`, err)
+	}
+
+	err = s.Reset(ctx)
+	if err != nil {
+		t.Fatalf(`Reset() = %v, wanted nil`, err)
+	}
+	if _, err := s.GetUserFromID(ctx, walt.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf(`GetUserFromID(after reset) = %v, wanted sql.ErrNoRows`, err)
+	}
+	if _, err := s.GetOneChirp(ctx, chirp.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf(`GetOneChirp(after reset) = %v, wanted sql.ErrNoRows`, err)
+	}
+	if _, err := s.GetUserFromRefreshToken(ctx, "abc"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf(`GetUserFromRefreshToken(after reset) = %v, wanted sql.ErrNoRows`, err)
+	}
+
+	// The email is free again.
+	createUser(t, s, "walt@example.com")
+}
+
+func testTransactions(t *testing.T, s Backend) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@example.com")
+	chirp := createChirp(t, s, walt, "deleted in a transaction", "visible")
+
+	failed := errors.New("failed")
+	err := s.InTx(ctx, func(tx store.Tx) error {
+		_, err := tx.CreateUser(ctx, database.CreateUserParams{Email: "jesse@example.com", HashedPassword: "hash"})
+		if err != nil {
+			return err
+		}
+		err = tx.DeleteChirp(ctx, chirp.ID)
+		if err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf(`InTx() = %v, wanted fn's error`, err)
+	}
+	if _, err := s.GetUserFromEmail(ctx, "jesse@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf(`GetUserFromEmail(created in a rolled back transaction) = %v, wanted sql.ErrNoRows`, err)
+	}
+	if _, err := s.GetOneChirp(ctx, chirp.ID); err != nil {
+		t.Fatalf(`GetOneChirp(deleted in a rolled back transaction) = %v, wanted nil`, err)
+	}
+
+	err = s.InTx(ctx, func(tx store.Tx) error {
+		return tx.DeleteChirp(ctx, chirp.ID)
+	})
+	if err != nil {
+		t.Fatalf(`InTx() = %v, wanted nil`, err)
+	}
+	if _, err := s.GetOneChirp(ctx, chirp.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf(`GetOneChirp(deleted in a committed transaction) = %v, wanted sql.ErrNoRows`, err)
+	}
+}
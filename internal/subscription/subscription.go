@@ -0,0 +1,129 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	StatusActive   = "active"
+	StatusPastDue  = "past_due"
+	StatusCanceled = "canceled"
+	StatusExpired  = "expired"
+)
+
+// Polka event types that change a subscription.
+const (
+	EventUpgraded      = "user.upgraded"
+	EventDowngraded    = "user.downgraded"
+	EventPaymentFailed = "payment.failed"
+	EventRenewed       = "subscription.renewed"
+	EventExpired       = "subscription.expired"
+)
+
+const (
+	DefaultPlan   = "red"
+	BillingPeriod = 30 * 24 * time.Hour
+)
+
+// ErrInvalidTransition is returned by Apply for an event the subscription
+// can't take in its current state.
+var ErrInvalidTransition = errors.New("invalid subscription transition")
+
+// State is the part of a subscription that events change.
+type State struct {
+	Plan             string     `json:"plan"`
+	Status           string     `json:"status"`
+	CurrentPeriodEnd time.Time  `json:"current_period_end"`
+	CancelAt         *time.Time `json:"cancel_at"`
+}
+
+// Event is a billing event. Plan and PeriodEnd are optional; when Polka
+// doesn't send them the current plan is kept and periods run BillingPeriod.
+type Event struct {
+	Type      string
+	Plan      string
+	PeriodEnd time.Time
+}
+
+// Handles reports whether eventType changes subscription state.
+func Handles(eventType string) bool {
+	switch eventType {
+	case EventUpgraded, EventDowngraded, EventPaymentFailed, EventRenewed:
+		return true
+	}
+	return false
+}
+
+// Apply returns the state after ev. current is nil for users who have never
+// subscribed; only an upgrade can start a subscription.
+//
+//   - upgraded starts (or restarts) an active subscription.
+//   - renewed extends the period and clears any failed payment or pending
+//     cancellation, reactivating the subscription if it had already expired.
+//   - payment.failed marks the subscription past due; it stays Red until the
+//     period ends unless a renewal arrives.
+//   - downgraded cancels at the end of the current period.
+func Apply(current *State, ev Event, now time.Time) (State, error) {
+	if current == nil {
+		if ev.Type != EventUpgraded {
+			return State{}, fmt.Errorf("%w: %s for user without a subscription", ErrInvalidTransition, ev.Type)
+		}
+		current = &State{Plan: DefaultPlan, Status: StatusExpired}
+	}
+
+	next := *current
+	if ev.Plan != "" {
+		next.Plan = ev.Plan
+	}
+
+	switch ev.Type {
+	case EventUpgraded:
+		next.Status = StatusActive
+		next.CancelAt = nil
+		next.CurrentPeriodEnd = periodEnd(ev, now, now)
+	case EventRenewed:
+		next.Status = StatusActive
+		next.CancelAt = nil
+		base := current.CurrentPeriodEnd
+		if base.Before(now) {
+			base = now
+		}
+		next.CurrentPeriodEnd = periodEnd(ev, base, now)
+	case EventPaymentFailed:
+		if current.Status == StatusExpired || current.Status == StatusCanceled {
+			return *current, nil
+		}
+		next.Status = StatusPastDue
+	case EventDowngraded:
+		if current.Status == StatusExpired {
+			return *current, nil
+		}
+		next.Status = StatusCanceled
+		cancelAt := current.CurrentPeriodEnd
+		next.CancelAt = &cancelAt
+	default:
+		return State{}, fmt.Errorf("unknown subscription event %q", ev.Type)
+	}
+	return next, nil
+}
+
+// IsRed reports whether a subscription in this state grants Chirpy Red.
+func IsRed(s State, now time.Time) bool {
+	if s.Status == StatusExpired {
+		return false
+	}
+	end := s.CurrentPeriodEnd
+	if s.CancelAt != nil && s.CancelAt.Before(end) {
+		end = *s.CancelAt
+	}
+	return now.Before(end)
+}
+
+func periodEnd(ev Event, base, now time.Time) time.Time {
+	if !ev.PeriodEnd.IsZero() && ev.PeriodEnd.After(now) {
+		return ev.PeriodEnd
+	}
+	return base.Add(BillingPeriod)
+}
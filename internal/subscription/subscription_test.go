@@ -0,0 +1,85 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUpgradeStartsSubscription(t *testing.T) {
+	now := time.Now()
+	state, err := Apply(nil, Event{Type: EventUpgraded}, now)
+	if err != nil {
+		t.Fatalf(`Apply(nil, upgraded) = %v, wanted nil`, err)
+	}
+	if state.Status != StatusActive || !state.CurrentPeriodEnd.Equal(now.Add(BillingPeriod)) {
+		t.Fatalf(`Apply(nil, upgraded) = %+v, wanted active for one period`, state)
+	}
+	if !IsRed(state, now) {
+		t.Fatal(`IsRed(upgraded) = false, wanted true`)
+	}
+}
+
+func TestEventsWithoutSubscription(t *testing.T) {
+	for _, eventType := range []string{EventDowngraded, EventPaymentFailed, EventRenewed} {
+		if _, err := Apply(nil, Event{Type: eventType}, time.Now()); !errors.Is(err, ErrInvalidTransition) {
+			t.Fatalf(`Apply(nil, %s) = %v, wanted ErrInvalidTransition`, eventType, err)
+		}
+	}
+}
+
+func TestDowngradeKeepsRedUntilPeriodEnd(t *testing.T) {
+	now := time.Now()
+	state, _ := Apply(nil, Event{Type: EventUpgraded}, now)
+	state, err := Apply(&state, Event{Type: EventDowngraded}, now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf(`Apply(downgraded) = %v, wanted nil`, err)
+	}
+	if state.Status != StatusCanceled || state.CancelAt == nil || !state.CancelAt.Equal(state.CurrentPeriodEnd) {
+		t.Fatalf(`Apply(downgraded) = %+v, wanted canceled at period end`, state)
+	}
+	if !IsRed(state, now.Add(48*time.Hour)) {
+		t.Fatal(`IsRed(canceled, mid period) = false, wanted true`)
+	}
+	if IsRed(state, state.CurrentPeriodEnd.Add(time.Second)) {
+		t.Fatal(`IsRed(canceled, after period) = true, wanted false`)
+	}
+}
+
+func TestPaymentFailedThenRenewed(t *testing.T) {
+	now := time.Now()
+	state, _ := Apply(nil, Event{Type: EventUpgraded}, now)
+	periodEnd := state.CurrentPeriodEnd
+
+	state, _ = Apply(&state, Event{Type: EventPaymentFailed}, now)
+	if state.Status != StatusPastDue || !IsRed(state, now) {
+		t.Fatalf(`Apply(payment.failed) = %+v, wanted past_due and still red`, state)
+	}
+
+	state, _ = Apply(&state, Event{Type: EventRenewed}, now)
+	if state.Status != StatusActive || !state.CurrentPeriodEnd.Equal(periodEnd.Add(BillingPeriod)) {
+		t.Fatalf(`Apply(renewed) = %+v, wanted active and extended one period`, state)
+	}
+}
+
+func TestRenewUsesPolkaPeriodEnd(t *testing.T) {
+	now := time.Now()
+	state, _ := Apply(nil, Event{Type: EventUpgraded}, now)
+	end := now.Add(365 * 24 * time.Hour)
+	state, _ = Apply(&state, Event{Type: EventRenewed, Plan: "red_yearly", PeriodEnd: end}, now)
+	if !state.CurrentPeriodEnd.Equal(end) || state.Plan != "red_yearly" {
+		t.Fatalf(`Apply(renewed with period end) = %+v, wanted red_yearly ending %v`, state, end)
+	}
+}
+
+func TestRenewReactivatesExpired(t *testing.T) {
+	now := time.Now()
+	state := State{Plan: DefaultPlan, Status: StatusExpired, CurrentPeriodEnd: now.Add(-time.Hour)}
+	state, err := Apply(&state, Event{Type: EventRenewed}, now)
+	if err != nil {
+		t.Fatalf(`Apply(expired, renewed) = %v, wanted nil`, err)
+	}
+	if state.Status != StatusActive || !state.CurrentPeriodEnd.Equal(now.Add(BillingPeriod)) {
+		t.Fatalf(`Apply(expired, renewed) = %+v, wanted active for one period from now`, state)
+	}
+}
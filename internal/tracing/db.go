@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/jamistoso/chirpy/internal/database"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Database systems for DB, as the db.system attribute has them.
+var (
+	SystemPostgres = semconv.DBSystemPostgreSQL
+	SystemSQLite   = semconv.DBSystemSqlite
+)
+
+// DB wraps db so that every query run through it is a span, named after the
+// sqlc query ("-- name: GetUser :one") when there is one. Use it for
+// transactions too: database.New(tracing.DB(tx, system)).
+func DB(db database.DBTX, system attribute.KeyValue) database.DBTX {
+	return tracedDB{db: db, system: system}
+}
+
+type tracedDB struct {
+	db     database.DBTX
+	system attribute.KeyValue
+}
+
+func (t tracedDB) start(ctx context.Context, query string) (context.Context, trace.Span) {
+	name, text := queryName(query)
+	return tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			t.system,
+			semconv.DBOperationName(name),
+			semconv.DBQueryText(text),
+		),
+	)
+}
+
+func (t tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := t.start(ctx, query)
+	defer span.End()
+	result, err := t.db.ExecContext(ctx, query, args...)
+	RecordError(span, err)
+	return result, err
+}
+
+func (t tracedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := t.start(ctx, query)
+	defer span.End()
+	stmt, err := t.db.PrepareContext(ctx, query)
+	RecordError(span, err)
+	return stmt, err
+}
+
+// QueryContext's span ends once the query has run, before the caller reads
+// the rows.
+func (t tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := t.start(ctx, query)
+	defer span.End()
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	RecordError(span, err)
+	return rows, err
+}
+
+func (t tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := t.start(ctx, query)
+	defer span.End()
+	row := t.db.QueryRowContext(ctx, query, args...)
+	RecordError(span, row.Err())
+	return row
+}
+
+// queryName splits the sqlc name comment off query.
+func queryName(query string) (name, text string) {
+	const prefix = "-- name: "
+	if !strings.HasPrefix(query, prefix) {
+		return "db.query", query
+	}
+	line, rest, _ := strings.Cut(query, "\n")
+	fields := strings.Fields(strings.TrimPrefix(line, prefix))
+	if len(fields) == 0 {
+		return "db.query", rest
+	}
+	return fields[0], strings.TrimSpace(rest)
+}
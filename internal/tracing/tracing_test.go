@@ -0,0 +1,166 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/migrate"
+	"github.com/jamistoso/chirpy/internal/sqlite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	incomingTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	incomingSpanID  = "00f067aa0ba902b7"
+)
+
+// recordSpans installs a provider that keeps finished spans in memory. The
+// returned function flushes and returns them.
+func recordSpans(t *testing.T) func() tracetest.SpanStubs {
+	t.Helper()
+	_, err := Setup(context.Background(), ExporterNone, 1)
+	if err != nil {
+		t.Fatalf(`Setup() = %v, wanted nil`, err)
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewProvider(exporter, 1)
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return func() tracetest.SpanStubs {
+		provider.ForceFlush(context.Background())
+		return exporter.GetSpans()
+	}
+}
+
+func openTestDB(t *testing.T) *database.Queries {
+	t.Helper()
+	db, err := sqlite.Open("sqlite::memory:")
+	if err != nil {
+		t.Fatalf(`Open() = %v, wanted nil`, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	m, err := migrate.New(db, migrate.SQLite, os.DirFS("../../sql/sqlite"))
+	if err != nil {
+		t.Fatalf(`migrate.New() = %v, wanted nil`, err)
+	}
+	_, err = m.Up(context.Background())
+	if err != nil {
+		t.Fatalf(`migrating: %v`, err)
+	}
+	return database.New(DB(db, SystemSQLite))
+}
+
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf(`no span named %q in %d spans`, name, len(spans))
+	return tracetest.SpanStub{}
+}
+
+func TestRequestAndQuerySpans(t *testing.T) {
+	spans := recordSpans(t)
+	queries := openTestDB(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users/{email}", func(w http.ResponseWriter, r *http.Request) {
+		_, err := queries.GetUserFromEmail(r.Context(), r.PathValue("email"))
+		if err != nil {
+			w.WriteHeader(404)
+		}
+	})
+	mux.HandleFunc("GET /boom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+	})
+	handler := Middleware(mux)
+
+	rq := httptest.NewRequest("GET", "/api/users/nobody@example.com", nil)
+	rq.Header.Set("traceparent", "00-"+incomingTraceID+"-"+incomingSpanID+"-01")
+	handler.ServeHTTP(httptest.NewRecorder(), rq)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/boom", nil))
+
+	got := spans()
+	server := findSpan(t, got, "GET /api/users/{email}")
+	if server.SpanKind != trace.SpanKindServer {
+		t.Fatalf(`server span kind = %v, wanted server`, server.SpanKind)
+	}
+	if server.SpanContext.TraceID().String() != incomingTraceID || server.Parent.SpanID().String() != incomingSpanID {
+		t.Fatalf(`server span trace, parent = %s, %s, wanted the incoming traceparent's`, server.SpanContext.TraceID(), server.Parent.SpanID())
+	}
+	if !hasAttribute(server, semconv.HTTPResponseStatusCode(404)) || !hasAttribute(server, semconv.HTTPRoute("GET /api/users/{email}")) {
+		t.Fatalf(`server span attributes = %v, wanted status and route`, server.Attributes)
+	}
+
+	query := findSpan(t, got, "GetUserFromEmail")
+	if query.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Fatalf(`query span parent = %s, wanted the server span %s`, query.Parent.SpanID(), server.SpanContext.SpanID())
+	}
+	if !hasAttribute(query, semconv.DBSystemSqlite) || !hasAttribute(query, semconv.DBOperationName("GetUserFromEmail")) {
+		t.Fatalf(`query span attributes = %v, wanted the db system and query name`, query.Attributes)
+	}
+
+	failed := findSpan(t, got, "GET /boom")
+	if failed.Status.Code != codes.Error || failed.Parent.IsValid() {
+		t.Fatalf(`GET /boom span status, parent = %v, %v, wanted an error on a root span`, failed.Status, failed.Parent)
+	}
+}
+
+func TestInjectHeaders(t *testing.T) {
+	spans := recordSpans(t)
+
+	ctx, span := StartClientSpan(context.Background(), "POST webhook")
+	header := http.Header{}
+	InjectHeaders(ctx, header)
+	span.End()
+
+	traceparent := header.Get("traceparent")
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String()
+	if !strings.HasPrefix(traceparent, want) {
+		t.Fatalf(`traceparent = %q, wanted it to start with %q`, traceparent, want)
+	}
+	if got := findSpan(t, spans(), "POST webhook"); got.SpanKind != trace.SpanKindClient {
+		t.Fatalf(`span kind = %v, wanted client`, got.SpanKind)
+	}
+}
+
+func TestQueryName(t *testing.T) {
+	name, text := queryName("-- name: CreateUser :one\nINSERT INTO users VALUES ($1)\n")
+	if name != "CreateUser" || text != "INSERT INTO users VALUES ($1)" {
+		t.Fatalf(`queryName() = %q, %q, wanted the sqlc name and the statement`, name, text)
+	}
+	name, text = queryName("SELECT 1")
+	if name != "db.query" || text != "SELECT 1" {
+		t.Fatalf(`queryName() = %q, %q, wanted a generic name for an unnamed query`, name, text)
+	}
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), "zipkin", 1)
+	if err == nil {
+		t.Fatalf(`Setup("zipkin") = nil, wanted an error`)
+	}
+}
+
+func hasAttribute(span tracetest.SpanStub, want attribute.KeyValue) bool {
+	for _, attr := range span.Attributes {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is why a delivery won't connect to an address inside
+// our own network. Endpoints are registered by users, who would otherwise
+// get to make chirpy POST to internal services and read back the results in
+// the delivery log.
+var ErrForbiddenAddress = errors.New("address is not publicly routable")
+
+// reservedPrefixes are ranges that aren't for public hosts, besides those the
+// netip.Addr methods in checkAddr cover.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// checkAddr returns ErrForbiddenAddress for loopback, private, link-local,
+// unspecified, multicast and reserved addresses. Loopback is let through
+// when allowLoopback is set, for receivers running on the same machine in
+// development and tests.
+func checkAddr(addr netip.Addr, allowLoopback bool) error {
+	addr = addr.Unmap()
+	if addr.IsLoopback() && allowLoopback {
+		return nil
+	}
+	forbidden := addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast()
+	for _, prefix := range reservedPrefixes {
+		forbidden = forbidden || prefix.Contains(addr)
+	}
+	if forbidden {
+		return fmt.Errorf("%s: %w", addr, ErrForbiddenAddress)
+	}
+	return nil
+}
+
+// ValidateURL checks that rawURL is an absolute http or https URL naming its
+// host rather than an IP address. Where the name resolves to is only known
+// when a delivery connects, which is where NewClient checks it.
+func ValidateURL(rawURL string, allowLoopback bool) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url must use http or https")
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("url must have a host")
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if allowLoopback && addr.Unmap().IsLoopback() {
+			return nil
+		}
+		return fmt.Errorf("url must have a host name, not an IP address")
+	}
+	if !allowLoopback && (host == "localhost" || strings.HasSuffix(host, ".localhost")) {
+		return fmt.Errorf("url must have a public host name")
+	}
+	return nil
+}
+
+// NewClient returns the client deliveries are sent with. It refuses to
+// connect to the addresses checkAddr forbids, which it checks for every
+// connection after the name is resolved, so a host name that resolves to
+// an internal address, even only some of the time, can't get around it.
+func NewClient(timeout time.Duration, allowLoopback bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			return checkAddr(addrPort.Addr(), allowLoopback)
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect on our behalf, to wherever it was asked to.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
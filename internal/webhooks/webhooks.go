@@ -0,0 +1,157 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/database"
+)
+
+// Event types endpoints can subscribe to. Only add one once something emits
+// it: endpoints could subscribe to it and never hear of it.
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpDeleted = "chirp.deleted"
+)
+
+var EventTypes = []string{EventChirpCreated, EventChirpDeleted}
+
+// plannedEventTypes were asked for with webhooks but are out of their scope:
+// chirpy has neither follows nor mentions to emit them from. Subscribing to
+// one says so rather than calling it unknown.
+var plannedEventTypes = map[string]string{
+	"user.followed": "chirpy has no follows yet",
+	"mention":       "chirpy has no mentions yet",
+}
+
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+// Headers sent with every delivery. The signature has the same format as
+// Polka's: "sha256=" followed by the hex HMAC of "<timestamp>.<body>".
+const (
+	HeaderSignature = "Chirpy-Signature"
+	HeaderTimestamp = "Chirpy-Timestamp"
+	HeaderEvent     = "Chirpy-Event"
+	HeaderDelivery  = "Chirpy-Delivery"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it is dead
+	// lettered.
+	MaxAttempts = 8
+
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+// Payload is the JSON body POSTed to endpoints.
+type Payload struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type Store interface {
+	GetWebhookEndpointsFromUser(ctx context.Context, userID uuid.UUID) ([]database.WebhookEndpoint, error)
+	CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) error
+}
+
+// ParseEventTypes validates and de-duplicates eventTypes, returning them in
+// the comma separated form stored on the endpoint.
+func ParseEventTypes(eventTypes []string) (string, error) {
+	if len(eventTypes) == 0 {
+		return "", fmt.Errorf("at least one event type is required")
+	}
+	var valid []string
+	for _, eventType := range eventTypes {
+		if reason, ok := plannedEventTypes[eventType]; ok {
+			return "", fmt.Errorf("event type %q isn't available: %s", eventType, reason)
+		}
+		if !slices.Contains(EventTypes, eventType) {
+			return "", fmt.Errorf("unknown event type %q", eventType)
+		}
+		if !slices.Contains(valid, eventType) {
+			valid = append(valid, eventType)
+		}
+	}
+	return strings.Join(valid, ","), nil
+}
+
+// Subscribes reports whether endpoint wants events of eventType.
+func Subscribes(endpoint database.WebhookEndpoint, eventType string) bool {
+	return slices.Contains(strings.Split(endpoint.EventTypes, ","), eventType)
+}
+
+// NewSecret returns a random signing secret for a new endpoint.
+func NewSecret() (string, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(key), nil
+}
+
+// Backoff is how long to wait before retrying after the given number of
+// failed attempts: 30s doubling each time, capped at 6h.
+func Backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+// Enqueue queues a delivery of the event to each of userID's endpoints that
+// subscribes to eventType. Every endpoint receives eventID so receivers can
+// de-duplicate, and an endpoint that already has a delivery of eventID isn't
+// given another, so calling Enqueue again for the same event is harmless.
+func Enqueue(ctx context.Context, store Store, eventID, userID uuid.UUID, eventType string, data any) error {
+	endpoints, err := store.GetWebhookEndpointsFromUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	for _, endpoint := range endpoints {
+		if !Subscribes(endpoint, eventType) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(Payload{
+				ID:        eventID,
+				Type:      eventType,
+				CreatedAt: time.Now().UTC(),
+				Data:      data,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err = store.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			EndpointID: endpoint.ID,
+			EventID:    eventID,
+			EventType:  eventType,
+			Payload:    payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,394 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/auth"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeStore keeps endpoints and deliveries in memory, enough to drive
+// Enqueue and Worker without a database.
+type fakeStore struct {
+	mu         sync.Mutex
+	endpoints  []database.WebhookEndpoint
+	deliveries map[uuid.UUID]*database.WebhookDelivery
+	attempts   []database.CreateWebhookDeliveryAttemptParams
+}
+
+func newFakeStore(endpoints ...database.WebhookEndpoint) *fakeStore {
+	return &fakeStore{
+		endpoints:  endpoints,
+		deliveries: map[uuid.UUID]*database.WebhookDelivery{},
+	}
+}
+
+func (s *fakeStore) GetWebhookEndpointsFromUser(ctx context.Context, userID uuid.UUID) ([]database.WebhookEndpoint, error) {
+	var endpoints []database.WebhookEndpoint
+	for _, endpoint := range s.endpoints {
+		if endpoint.UserID == userID {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints, nil
+}
+
+func (s *fakeStore) GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (database.WebhookEndpoint, error) {
+	for _, endpoint := range s.endpoints {
+		if endpoint.ID == id {
+			return endpoint, nil
+		}
+	}
+	return database.WebhookEndpoint{}, io.EOF
+}
+
+func (s *fakeStore) CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, delivery := range s.deliveries {
+		if delivery.EndpointID == arg.EndpointID && delivery.EventID == arg.EventID {
+			return nil
+		}
+	}
+	delivery := database.WebhookDelivery{
+		ID:            uuid.New(),
+		EndpointID:    arg.EndpointID,
+		EventID:       arg.EventID,
+		EventType:     arg.EventType,
+		Payload:       arg.Payload,
+		Status:        StatusPending,
+		NextAttemptAt: time.Time{},
+	}
+	s.deliveries[delivery.ID] = &delivery
+	return nil
+}
+
+func (s *fakeStore) ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var claimed []database.WebhookDelivery
+	for _, delivery := range s.deliveries {
+		if delivery.Status == StatusPending && !delivery.NextAttemptAt.After(arg.Now) {
+			delivery.NextAttemptAt = arg.LeaseUntil
+			claimed = append(claimed, *delivery)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *fakeStore) RecordWebhookDeliveryResult(ctx context.Context, arg database.RecordWebhookDeliveryResultParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delivery := s.deliveries[arg.ID]
+	delivery.Status = arg.Status
+	delivery.Attempts = arg.Attempts
+	delivery.NextAttemptAt = arg.NextAttemptAt
+	return nil
+}
+
+func (s *fakeStore) CreateWebhookDeliveryAttempt(ctx context.Context, arg database.CreateWebhookDeliveryAttemptParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts = append(s.attempts, arg)
+	return nil
+}
+
+func (s *fakeStore) only(t *testing.T) database.WebhookDelivery {
+	t.Helper()
+	if len(s.deliveries) != 1 {
+		t.Fatalf(`store has %d deliveries, wanted 1`, len(s.deliveries))
+	}
+	for _, delivery := range s.deliveries {
+		return *delivery
+	}
+	return database.WebhookDelivery{}
+}
+
+func TestParseEventTypes(t *testing.T) {
+	types, err := ParseEventTypes([]string{EventChirpCreated, EventChirpDeleted, EventChirpCreated})
+	if err != nil || types != "chirp.created,chirp.deleted" {
+		t.Fatalf(`ParseEventTypes() = %q, %v, wanted "chirp.created,chirp.deleted"`, types, err)
+	}
+	if _, err := ParseEventTypes([]string{"mention"}); err == nil || !strings.Contains(err.Error(), "isn't available") {
+		t.Fatalf(`ParseEventTypes(mention) = %v, wanted it to be unavailable`, err)
+	}
+	if _, err := ParseEventTypes([]string{"chirp.liked"}); err == nil {
+		t.Fatal(`ParseEventTypes(chirp.liked) = nil, wanted error`)
+	}
+	if _, err := ParseEventTypes(nil); err == nil {
+		t.Fatal(`ParseEventTypes(nil) = nil, wanted error`)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if Backoff(1) != 30*time.Second || Backoff(3) != 2*time.Minute {
+		t.Fatalf(`Backoff(1), Backoff(3) = %v, %v, wanted 30s, 2m`, Backoff(1), Backoff(3))
+	}
+	if Backoff(30) != maxBackoff {
+		t.Fatalf(`Backoff(30) = %v, wanted %v`, Backoff(30), maxBackoff)
+	}
+}
+
+func TestEnqueueOnlySubscribedEndpoints(t *testing.T) {
+	userID := uuid.New()
+	store := newFakeStore(
+		database.WebhookEndpoint{ID: uuid.New(), UserID: userID, EventTypes: "chirp.created"},
+		database.WebhookEndpoint{ID: uuid.New(), UserID: userID, EventTypes: "chirp.deleted"},
+		database.WebhookEndpoint{ID: uuid.New(), UserID: uuid.New(), EventTypes: "chirp.created"},
+	)
+
+	err := Enqueue(context.Background(), store, uuid.New(), userID, EventChirpCreated, map[string]string{"body": "hi"})
+	if err != nil {
+		t.Fatalf(`Enqueue() = %v, wanted nil`, err)
+	}
+	delivery := store.only(t)
+	if delivery.EndpointID != store.endpoints[0].ID {
+		t.Fatalf(`delivery went to %v, wanted %v`, delivery.EndpointID, store.endpoints[0].ID)
+	}
+}
+
+func TestEnqueueIsIdempotent(t *testing.T) {
+	userID := uuid.New()
+	store := newFakeStore(
+		database.WebhookEndpoint{ID: uuid.New(), UserID: userID, EventTypes: "chirp.created"},
+	)
+	eventID := uuid.New()
+	for range 2 {
+		err := Enqueue(context.Background(), store, eventID, userID, EventChirpCreated, nil)
+		if err != nil {
+			t.Fatalf(`Enqueue() = %v, wanted nil`, err)
+		}
+	}
+	store.endpoints = append(store.endpoints, database.WebhookEndpoint{ID: uuid.New(), UserID: userID, EventTypes: "chirp.created"})
+	Enqueue(context.Background(), store, eventID, userID, EventChirpCreated, nil)
+	if len(store.deliveries) != 2 {
+		t.Fatalf(`store has %d deliveries after enqueueing an event three times, wanted one per endpoint`, len(store.deliveries))
+	}
+}
+
+func TestWorkerDeliversSignedPayload(t *testing.T) {
+	secret := "whsec_test"
+	var gotSignature, gotTimestamp string
+	var gotBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSignature = r.Header.Get(HeaderSignature)
+		gotTimestamp = r.Header.Get(HeaderTimestamp)
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(204)
+	}))
+	defer receiver.Close()
+
+	userID := uuid.New()
+	store := newFakeStore(database.WebhookEndpoint{ID: uuid.New(), UserID: userID, Url: receiver.URL, Secret: secret, EventTypes: "chirp.created"})
+	Enqueue(context.Background(), store, uuid.New(), userID, EventChirpCreated, map[string]string{"body": "hi"})
+
+	n, err := NewWorker(store, true).RunOnce(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf(`RunOnce() = %d, %v, wanted 1, nil`, n, err)
+	}
+
+	err = auth.VerifyWebhookSignature(gotSignature, gotTimestamp, gotBody, []string{secret}, time.Now(), time.Minute)
+	if err != nil {
+		t.Fatalf(`VerifyWebhookSignature() = %v, wanted nil`, err)
+	}
+	var payload Payload
+	json.Unmarshal(gotBody, &payload)
+	if payload.Type != EventChirpCreated {
+		t.Fatalf(`payload type = %q, wanted %q`, payload.Type, EventChirpCreated)
+	}
+
+	delivery := store.only(t)
+	if delivery.Status != StatusSucceeded || delivery.Attempts != 1 {
+		t.Fatalf(`delivery = %s after %d attempts, wanted succeeded after 1`, delivery.Status, delivery.Attempts)
+	}
+	if len(store.attempts) != 1 || store.attempts[0].ResponseStatus.Int32 != 204 {
+		t.Fatalf(`attempts = %+v, wanted one with status 204`, store.attempts)
+	}
+}
+
+func TestWorkerPropagatesTraceContext(t *testing.T) {
+	_, err := tracing.Setup(context.Background(), tracing.ExporterNone, 1)
+	if err != nil {
+		t.Fatalf(`Setup() = %v, wanted nil`, err)
+	}
+	var gotTraceparent string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTraceparent = r.Header.Get("traceparent")
+		w.WriteHeader(204)
+	}))
+	defer receiver.Close()
+
+	userID := uuid.New()
+	store := newFakeStore(database.WebhookEndpoint{ID: uuid.New(), UserID: userID, Url: receiver.URL, EventTypes: "chirp.created"})
+	Enqueue(context.Background(), store, uuid.New(), userID, EventChirpCreated, map[string]string{"body": "hi"})
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	NewWorker(store, true).RunOnce(ctx)
+
+	if !strings.HasPrefix(gotTraceparent, "00-"+traceID.String()+"-") {
+		t.Fatalf(`traceparent = %q, wanted trace %s`, gotTraceparent, traceID)
+	}
+}
+
+func TestWorkerRetriesThenDeadLetters(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer receiver.Close()
+
+	userID := uuid.New()
+	store := newFakeStore(database.WebhookEndpoint{ID: uuid.New(), UserID: userID, Url: receiver.URL, EventTypes: "chirp.deleted"})
+	Enqueue(context.Background(), store, uuid.New(), userID, EventChirpDeleted, nil)
+
+	now := time.Now()
+	worker := NewWorker(store, true)
+	worker.now = func() time.Time { return now }
+
+	worker.RunOnce(context.Background())
+	delivery := store.only(t)
+	if delivery.Status != StatusPending || !delivery.NextAttemptAt.Equal(now.Add(Backoff(1))) {
+		t.Fatalf(`delivery = %s next at %v, wanted pending retry after %v`, delivery.Status, delivery.NextAttemptAt, Backoff(1))
+	}
+
+	if n, _ := worker.RunOnce(context.Background()); n != 0 {
+		t.Fatalf(`RunOnce() before backoff = %d, wanted 0`, n)
+	}
+
+	for i := 1; i < MaxAttempts; i++ {
+		now = now.Add(maxBackoff)
+		worker.RunOnce(context.Background())
+	}
+	delivery = store.only(t)
+	if delivery.Status != StatusDead || delivery.Attempts != MaxAttempts {
+		t.Fatalf(`delivery = %s after %d attempts, wanted dead after %d`, delivery.Status, delivery.Attempts, MaxAttempts)
+	}
+	if len(store.attempts) != MaxAttempts || store.attempts[0].Error == "" {
+		t.Fatalf(`logged %d attempts, wanted %d with errors`, len(store.attempts), MaxAttempts)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, rawURL := range []string{"https://example.com/hook", "http://hooks.example.com:8080/x?y=z"} {
+		if err := ValidateURL(rawURL, false); err != nil {
+			t.Fatalf(`ValidateURL(%q) = %v, wanted nil`, rawURL, err)
+		}
+	}
+	for _, rawURL := range []string{
+		"ftp://example.com/hook",
+		"/hook",
+		"http://127.0.0.1:8080/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.1/hook",
+		"http://[::1]/hook",
+		"http://93.184.216.34/hook",
+		"http://localhost:8080/hook",
+		"http://api.localhost/hook",
+	} {
+		if err := ValidateURL(rawURL, false); err == nil {
+			t.Fatalf(`ValidateURL(%q) = nil, wanted an error`, rawURL)
+		}
+	}
+
+	if err := ValidateURL("http://127.0.0.1:8080/hook", true); err != nil {
+		t.Fatalf(`ValidateURL(loopback) allowing loopback = %v, wanted nil`, err)
+	}
+	if err := ValidateURL("http://10.0.0.1/hook", true); err == nil {
+		t.Fatalf(`ValidateURL(private) allowing loopback = nil, wanted an error`)
+	}
+}
+
+func TestCheckAddr(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "::1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"fe80::1", "fd00::1", "0.0.0.0", "::", "224.0.0.1", "ff02::1", "100.100.100.200", "::ffff:127.0.0.1"} {
+		if err := checkAddr(netip.MustParseAddr(addr), false); !errors.Is(err, ErrForbiddenAddress) {
+			t.Fatalf(`checkAddr(%s) = %v, wanted ErrForbiddenAddress`, addr, err)
+		}
+	}
+	for _, addr := range []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"} {
+		if err := checkAddr(netip.MustParseAddr(addr), false); err != nil {
+			t.Fatalf(`checkAddr(%s) = %v, wanted nil`, addr, err)
+		}
+	}
+}
+
+func TestWorkerRefusesInternalAddresses(t *testing.T) {
+	hit := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+		w.WriteHeader(204)
+	}))
+	defer receiver.Close()
+
+	userID := uuid.New()
+	store := newFakeStore(database.WebhookEndpoint{ID: uuid.New(), UserID: userID, Url: receiver.URL, EventTypes: "chirp.created"})
+	Enqueue(context.Background(), store, uuid.New(), userID, EventChirpCreated, nil)
+
+	_, err := NewWorker(store, false).RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf(`RunOnce() = %v, wanted nil`, err)
+	}
+	if hit {
+		t.Fatalf(`the worker connected to %s, wanted it refused`, receiver.URL)
+	}
+	delivery := store.only(t)
+	if delivery.Status != StatusPending || len(store.attempts) != 1 || !strings.Contains(store.attempts[0].Error, ErrForbiddenAddress.Error()) {
+		t.Fatalf(`delivery = %s with attempts %+v, wanted a failed attempt to retry`, delivery.Status, store.attempts)
+	}
+}
+
+func TestWorkerStopsWhenLeaseRunsOut(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Now()
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	worker := NewWorker(nil, true)
+	// Each request takes as long as it is allowed to.
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		now = now.Add(worker.Client.Timeout)
+		mu.Unlock()
+		w.WriteHeader(204)
+	}))
+	defer receiver.Close()
+
+	userID := uuid.New()
+	store := newFakeStore(database.WebhookEndpoint{ID: uuid.New(), UserID: userID, Url: receiver.URL, EventTypes: "chirp.created"})
+	Enqueue(context.Background(), store, uuid.New(), userID, EventChirpCreated, nil)
+	Enqueue(context.Background(), store, uuid.New(), userID, EventChirpCreated, nil)
+	worker.Store = store
+	worker.now = clock
+	worker.Lease = worker.Client.Timeout * 3 / 2
+	leaseUntil := now.Add(worker.Lease)
+
+	n, err := worker.RunOnce(context.Background())
+	if n != 1 || err != nil {
+		t.Fatalf(`RunOnce() = %d, %v, wanted 1 delivery attempted before the lease ran out`, n, err)
+	}
+	for _, delivery := range store.deliveries {
+		if delivery.Status == StatusPending && !delivery.NextAttemptAt.Equal(leaseUntil) {
+			t.Fatalf(`unattempted delivery next at %v, wanted it left leased until %v`, delivery.NextAttemptAt, leaseUntil)
+		}
+	}
+}
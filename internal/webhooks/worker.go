@@ -0,0 +1,181 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/auth"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/logging"
+	"github.com/jamistoso/chirpy/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+type WorkerStore interface {
+	ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.WebhookDelivery, error)
+	GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (database.WebhookEndpoint, error)
+	RecordWebhookDeliveryResult(ctx context.Context, arg database.RecordWebhookDeliveryResultParams) error
+	CreateWebhookDeliveryAttempt(ctx context.Context, arg database.CreateWebhookDeliveryAttemptParams) error
+}
+
+// Worker sends queued deliveries. Claimed deliveries are leased rather than
+// locked, so several workers can share the table and a delivery whose worker
+// died is picked up again once its lease runs out. A batch is only worked on
+// while its lease lasts, so Lease should leave room for BatchSize requests
+// of up to Client.Timeout each.
+type Worker struct {
+	Store     WorkerStore
+	Client    *http.Client
+	BatchSize int32
+	Lease     time.Duration
+
+	now func() time.Time
+}
+
+// NewWorker returns a worker sending with NewClient. allowLoopback is
+// NewClient's. Its batches of 5 deliveries timing out after 10s each fit
+// in its 1 minute lease.
+func NewWorker(store WorkerStore, allowLoopback bool) *Worker {
+	return &Worker{
+		Store:     store,
+		Client:    NewClient(10*time.Second, allowLoopback),
+		BatchSize: 5,
+		Lease:     time.Minute,
+		now:       time.Now,
+	}
+}
+
+// Run delivers due webhooks every interval until ctx is done.
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, err := w.RunOnce(ctx)
+		if err != nil {
+			logging.FromContext(ctx).Error("error delivering webhooks", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims one batch of due deliveries and attempts each of them,
+// returning how many were attempted. It stops early when the lease has too
+// little time left for another request, since another worker may claim the
+// rest once it runs out; they are attempted when that happens.
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	now := w.now()
+	leaseUntil := now.Add(w.Lease)
+	deliveries, err := w.Store.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseUntil:    leaseUntil,
+		Now:           now,
+		MaxDeliveries: w.BatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+	for i, delivery := range deliveries {
+		if w.now().Add(w.Client.Timeout).After(leaseUntil) {
+			return i, nil
+		}
+		err := w.deliver(ctx, delivery)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+func (w *Worker) deliver(ctx context.Context, delivery database.WebhookDelivery) error {
+	endpoint, err := w.Store.GetWebhookEndpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		return err
+	}
+
+	start := w.now()
+	status, sendErr := w.send(ctx, endpoint, delivery, start)
+	attempt := database.CreateWebhookDeliveryAttemptParams{
+		DeliveryID: delivery.ID,
+		DurationMs: w.now().Sub(start).Milliseconds(),
+	}
+	if status != 0 {
+		attempt.ResponseStatus = sql.NullInt32{Int32: int32(status), Valid: true}
+	}
+	if sendErr != nil {
+		attempt.Error = sendErr.Error()
+	}
+	err = w.Store.CreateWebhookDeliveryAttempt(ctx, attempt)
+	if err != nil {
+		return err
+	}
+
+	result := database.RecordWebhookDeliveryResultParams{
+		ID:            delivery.ID,
+		Attempts:      delivery.Attempts + 1,
+		Status:        StatusSucceeded,
+		NextAttemptAt: delivery.NextAttemptAt,
+	}
+	if sendErr != nil {
+		result.Status = StatusPending
+		result.NextAttemptAt = w.now().Add(Backoff(int(result.Attempts)))
+		if result.Attempts >= MaxAttempts {
+			result.Status = StatusDead
+		}
+	}
+	return w.Store.RecordWebhookDeliveryResult(ctx, result)
+}
+
+// send POSTs the delivery to endpoint, returning the response status if one
+// was received and an error unless it was a 2xx. The request carries the
+// trace context of its span, so receivers can join the trace.
+func (w *Worker) send(ctx context.Context, endpoint database.WebhookEndpoint, delivery database.WebhookDelivery, now time.Time) (status int, err error) {
+	ctx, span := tracing.StartClientSpan(ctx, "POST webhook",
+		semconv.HTTPRequestMethodKey.String("POST"),
+		attribute.String("webhook.event", delivery.EventType),
+		attribute.String("webhook.delivery_id", delivery.ID.String()),
+	)
+	defer func() {
+		if status != 0 {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		}
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	// Only the host: webhook URLs often have a secret in the path.
+	span.SetAttributes(semconv.ServerAddress(req.URL.Hostname()))
+	tracing.InjectHeaders(ctx, req.Header)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID.String())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, auth.SignWebhook(delivery.Payload, endpoint.Secret, now))
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -1,77 +1,206 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
 	"os"
-	"sort"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/apierror"
+	"github.com/jamistoso/chirpy/internal/audit"
 	"github.com/jamistoso/chirpy/internal/auth"
+	"github.com/jamistoso/chirpy/internal/config"
 	"github.com/jamistoso/chirpy/internal/database"
-	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/jamistoso/chirpy/internal/decode"
+	"github.com/jamistoso/chirpy/internal/entitlements"
+	"github.com/jamistoso/chirpy/internal/health"
+	"github.com/jamistoso/chirpy/internal/jobs"
+	"github.com/jamistoso/chirpy/internal/logging"
+	"github.com/jamistoso/chirpy/internal/metrics"
+	"github.com/jamistoso/chirpy/internal/moderation"
+	"github.com/jamistoso/chirpy/internal/ratelimit"
+	"github.com/jamistoso/chirpy/internal/sqlite"
+	"github.com/jamistoso/chirpy/internal/store"
+	"github.com/jamistoso/chirpy/internal/tracing"
+	"github.com/jamistoso/chirpy/internal/webhooks"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// accessTokenTTL is how long access tokens last, and so also how long a
+// retired signing key must keep validating the tokens it signed.
+const accessTokenTTL = time.Hour
+
+// maxChirpPageSize is the largest limit GET /api/chirps accepts.
+const maxChirpPageSize = 100
+
+const (
+	chirpVisible	= "visible"
+	chirpHeld		= "held"
+	chirpHidden		= "hidden"
 )
 
 type apiConfig struct {
-	fileserverHits 	atomic.Int32
+	metrics			*metrics.Metrics
+	db				*sql.DB
 	dbQueries 		*database.Queries
+	dbSystem		attribute.KeyValue
+	users			store.UserStore
+	chirps			store.ChirpStore
+	tokens			store.TokenStore
+	transactor		store.Transactor
 	platform 		string
-	jwtSecret 		string
-	polkaKey		string
+	jwtKeys			*auth.Keyring
+	polkaKeys		[]string
+	limiter			*ratelimit.Limiter
+	moderator		*moderation.Pipeline
+	auditor			*audit.Auditor
+	entitlements	entitlements.Config
+	webhookLoopback	bool
+	health			*health.Checker
+	ready			atomic.Bool
+}
+
+// withTx returns queries that run in tx, traced like cfg.dbQueries.
+func (cfg *apiConfig) withTx(tx *sql.Tx) *database.Queries {
+	return database.New(tracing.DB(tx, cfg.dbSystem))
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cfg.fileserverHits.Add(1)
+		cfg.metrics.FileserverHit()
 		next.ServeHTTP(w, r)
 	})
 }
 
+// metricsHandler is a human readable summary of what /metrics serves on the
+// metrics listener.
 func (cfg *apiConfig) metricsHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	values, err := cfg.metrics.Snapshot()
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("gathering metrics: %w", err))
+		return
+	}
 	rWriter.Header().Add("Content-Type", "text/html" )
 	rWriter.Write([]byte(fmt.Sprintf(`<html>
   	<body>
     <h1>Welcome, Chirpy Admin</h1>
-    <p>Chirpy has been visited %d times!</p></body>
-	</html>`, cfg.fileserverHits.Load())))
+    <p>Chirpy has been visited %d times!</p>
+    <ul>
+      <li>Signups: %d</li>
+      <li>Chirps created: %d</li>
+      <li>Chirpy Red upgrades: %d</li>
+      <li>Authentication failures: %d</li>
+      <li>Requests in flight: %d</li>
+    </ul>
+    <p>Full metrics are at /metrics on the metrics address.</p></body>
+	</html>`,
+		int(values["chirpy_fileserver_hits_total"]),
+		int(values["chirpy_signups_total"]),
+		int(values["chirpy_chirps_created_total"]),
+		int(values["chirpy_red_upgrades_total"]),
+		int(values["chirpy_auth_failures_total"]),
+		int(values["chirpy_http_requests_in_flight"]),
+	)))
 }
 
 func (cfg *apiConfig) resetHandler(rWriter http.ResponseWriter, rq *http.Request) {
 
 	if cfg.platform != "dev" {
-		respondWithError(rWriter, 403, "reset attempted on non-dev platform")
+		respondWithError(rWriter, rq, apierror.Forbidden(apierror.CodeForbidden, "reset is only allowed on the dev platform"))
 		return
 	}
-	cfg.dbQueries.Reset(rq.Context())
-	fmt.Println("Reset")
-	cfg.fileserverHits.Store(0)
+	cfg.users.Reset(rq.Context())
+	values, _ := cfg.metrics.Snapshot()
+	cfg.audit(rq, audit.Event{
+		ActorType:	audit.ActorAnonymous,
+		Action:		"admin.reset",
+		TargetType:	"platform",
+		TargetID:	cfg.platform,
+		Before:		map[string]int{"fileserver_hits": int(values["chirpy_fileserver_hits_total"])},
+	})
+	cfg.metrics.ResetFileserverHits()
 	respondWithJSON(rWriter, 200, "File server hits reset to 0")
 }
 
+type userResponse struct {
+	Id 					uuid.UUID	`json:"id"`
+	Created_at 			time.Time 	`json:"created_at"`
+	Updated_at 			time.Time 	`json:"updated_at"`
+	Email				string		`json:"email"`
+	Is_chirpy_red		bool		`json:"is_chirpy_red"`
+}
+
+func newUserResponse(dbUser database.User) userResponse {
+	return userResponse{
+		Id:					dbUser.ID,
+		Created_at: 		dbUser.CreatedAt,
+		Updated_at: 		dbUser.UpdatedAt,
+		Email:				dbUser.Email,
+		Is_chirpy_red:		dbUser.IsChirpyRed.Bool,
+	}
+}
+
+type loginResponse struct {
+	userResponse
+	Token				string		`json:"token"`
+	Refresh_token		string		`json:"refresh_token"`
+}
+
+type tokenResponse struct {
+	Token	string	`json:"token"`
+}
+
+type chirpResponse struct {
+	ID 			uuid.UUID	`json:"id"`
+	Created_at 	time.Time 	`json:"created_at"`
+	Updated_at	time.Time	`json:"updated_at"`
+	Body		string		`json:"body"`
+	User_id		uuid.UUID	`json:"user_id"`
+}
+
+func newChirpResponse(chirp database.Chirp) chirpResponse {
+	return chirpResponse{
+		ID: 		chirp.ID,
+		Created_at: chirp.CreatedAt,
+		Updated_at: chirp.UpdatedAt,
+		Body: 		chirp.Body,
+		User_id: 	chirp.UserID.UUID,
+	}
+}
+
 func (cfg *apiConfig) usersHandler(rWriter http.ResponseWriter, rq *http.Request) {
 	type parameters struct {
-		Password 	string `json:"password"`
-		Email 		string `json:"email"`
+		Password 	string `json:"password" validate:"required,maxbytes=72"`
+		Email 		string `json:"email" validate:"required,email,max=254"`
 	}
 
-	decoder := json.NewDecoder(rq.Body)
 	rqParams := parameters{}
-	err := decoder.Decode(&rqParams)
+	err := decode.JSON(rWriter, rq, &rqParams)
 	if err != nil {
-		respondWithError(rWriter, 500, "Error decoding parameters")
+		respondWithError(rWriter, rq, err)
 		return
 	}
 
 	hashPass, err := auth.HashPassword(rqParams.Password)
 	if err != nil {
-		respondWithError(rWriter, 500, "Error hashing password")
+		respondWithError(rWriter, rq, fmt.Errorf("hashing password: %w", err))
+		return
 	}
 
 	userParams := database.CreateUserParams{
@@ -79,66 +208,82 @@ func (cfg *apiConfig) usersHandler(rWriter http.ResponseWriter, rq *http.Request
 		HashedPassword: hashPass,
 	}
 
-	dbUser, err := cfg.dbQueries.CreateUser(rq.Context(), userParams)
+	dbUser, err := cfg.users.CreateUser(rq.Context(), userParams)
 	if err != nil {
-		respondWithError(rWriter, 500, "Error creating user")
+		respondWithError(rWriter, rq, fmt.Errorf("creating user: %w", err))
 		return
 	}
+	cfg.metrics.Signups.Inc()
 
-	type returnVals struct {
-		Id 					uuid.UUID	`json:"id"`
-		Created_at 			time.Time 	`json:"created_at"`
-		Updated_at 			time.Time 	`json:"updated_at"`
-		Email				string		`json:"email"`
-		Is_chirpy_red		bool		`json:"is_chirpy_red"`
-	}
+	cfg.audit(rq, audit.Event{
+		ActorID:	dbUser.ID,
+		ActorType:	audit.ActorUser,
+		Action:		"user.signup",
+		TargetType:	"user",
+		TargetID:	dbUser.ID.String(),
+		After:		map[string]string{"email": dbUser.Email},
+	})
 
-	respBody := returnVals{
-		Id:					dbUser.ID,
-		Created_at: 		dbUser.CreatedAt,
-		Updated_at: 		dbUser.UpdatedAt,
-		Email:				dbUser.Email,
-		Is_chirpy_red:		dbUser.IsChirpyRed.Bool,
-	}
-	
-	respondWithJSON(rWriter, 201, respBody)
+	respondWithJSON(rWriter, 201, newUserResponse(dbUser))
 }
 
 func (cfg *apiConfig) loginHandler(rWriter http.ResponseWriter, rq *http.Request) {
 	type parameters struct {
-		Password 			string 	`json:"password"`
-		Email 				string 	`json:"email"`
+		Password 			string 	`json:"password" validate:"required"`
+		Email 				string 	`json:"email" validate:"required"`
 	}
 
-	decoder := json.NewDecoder(rq.Body)
 	rqParams := parameters{}
-	err := decoder.Decode(&rqParams)
+	err := decode.JSON(rWriter, rq, &rqParams)
 	if err != nil {
-		respondWithError(rWriter, 500, "error decoding parameters")
+		respondWithError(rWriter, rq, err)
 		return
 	}
 
-	dbUser, err := cfg.dbQueries.GetUserFromEmail(rq.Context(), rqParams.Email)
+	dbUser, err := cfg.users.GetUserFromEmail(rq.Context(), rqParams.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rWriter, rq, fmt.Errorf("looking up user: %w", err))
+		return
+	}
 	if err != nil {
-		respondWithError(rWriter, 500, "error creating user")
+		cfg.audit(rq, audit.Event{
+			ActorType:	audit.ActorAnonymous,
+			Action:		"auth.login_failed",
+			TargetType:	"email",
+			TargetID:	rqParams.Email,
+		})
+		cfg.metrics.AuthFailure(metrics.AuthPassword)
+		respondWithError(rWriter, rq, apierror.Unauthorized(apierror.CodeInvalidCredentials, "incorrect email or password"))
 		return
 	}
 
 	err = auth.CheckPasswordHash(rqParams.Password, dbUser.HashedPassword)
 	if err != nil {
-		respondWithError(rWriter, 401, "incorrect email or password")
+		cfg.audit(rq, audit.Event{
+			ActorType:	audit.ActorAnonymous,
+			Action:		"auth.login_failed",
+			TargetType:	"user",
+			TargetID:	dbUser.ID.String(),
+		})
+		cfg.metrics.AuthFailure(metrics.AuthPassword)
+		respondWithError(rWriter, rq, apierror.Unauthorized(apierror.CodeInvalidCredentials, "incorrect email or password"))
 		return
 	}
+	logging.SetUserID(rq.Context(), dbUser.ID)
 
-	jwtToken, err := auth.MakeJWT(dbUser.ID, cfg.jwtSecret, time.Duration(1) * time.Hour)
+	if !checkAccountState(rWriter, rq, dbUser) {
+		return
+	}
+
+	jwtToken, err := cfg.jwtKeys.MakeJWT(dbUser.ID, accessTokenTTL)
 	if err != nil {
-		respondWithError(rWriter, 500, "jwt token creation failed")
+		respondWithError(rWriter, rq, fmt.Errorf("creating access token: %w", err))
 		return
 	}
 
 	refreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
-		respondWithError(rWriter, 500, "refresh token creation failed")
+		respondWithError(rWriter, rq, fmt.Errorf("creating refresh token: %w", err))
 		return
 	}
 
@@ -153,30 +298,24 @@ func (cfg *apiConfig) loginHandler(rWriter http.ResponseWriter, rq *http.Request
 		ExpiresAt: refreshExpirationTime,
 	}
 
-	_, err = cfg.dbQueries.CreateRefreshToken(rq.Context(), params)
+	_, err = cfg.tokens.CreateRefreshToken(rq.Context(), params)
 	if err != nil {
-		respondWithError(rWriter, 500, err.Error())
+		respondWithError(rWriter, rq, fmt.Errorf("storing refresh token: %w", err))
 		return
 	}
 
-	type returnVals struct {
-		Id 					uuid.UUID	`json:"id"`
-		Created_at 			time.Time 	`json:"created_at"`
-		Updated_at 			time.Time 	`json:"updated_at"`
-		Email				string		`json:"email"`
-		Token				string		`json:"token"`
-		Refresh_token		string		`json:"refresh_token"`
-		Is_chirpy_red		bool		`json:"is_chirpy_red"`
-	}
+	cfg.audit(rq, audit.Event{
+		ActorID:	dbUser.ID,
+		ActorType:	audit.ActorUser,
+		Action:		"auth.login",
+		TargetType:	"user",
+		TargetID:	dbUser.ID.String(),
+	})
 
-	respBody := returnVals{
-		Id:					dbUser.ID,
-		Created_at: 		dbUser.CreatedAt,
-		Updated_at: 		dbUser.UpdatedAt,
-		Email:				dbUser.Email,
+	respBody := loginResponse{
+		userResponse:		newUserResponse(dbUser),
 		Token:				jwtToken,
 		Refresh_token:		refreshToken,
-		Is_chirpy_red:		dbUser.IsChirpyRed.Bool,
 	}
 	
 	respondWithJSON(rWriter, 200, respBody)
@@ -185,28 +324,43 @@ func (cfg *apiConfig) loginHandler(rWriter http.ResponseWriter, rq *http.Request
 func (cfg *apiConfig) refreshHandler(rWriter http.ResponseWriter, rq *http.Request) {
 	refreshToken, err := auth.GetBearerToken(rq.Header)
 	if err != nil {
-		respondWithError(rWriter, 401, err.Error())
+		cfg.metrics.AuthFailure(metrics.AuthRefreshToken)
+		respondWithError(rWriter, rq, apierror.Unauthorized(apierror.CodeUnauthorized, "missing bearer token"))
+		return
 	}
 
-	dbToken, err := cfg.dbQueries.GetUserFromRefreshToken(rq.Context(), refreshToken)
-	if err != nil {
-		respondWithError(rWriter, 401, err.Error())
+	dbToken, err := cfg.tokens.GetUserFromRefreshToken(rq.Context(), refreshToken)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rWriter, rq, fmt.Errorf("looking up refresh token: %w", err))
+		return
 	}
-	if dbToken.RevokedAt.Valid {
-		respondWithError(rWriter, 401, "invalid refresh token")
+	if err != nil || dbToken.RevokedAt.Valid || time.Now().After(dbToken.ExpiresAt) {
+		cfg.metrics.AuthFailure(metrics.AuthRefreshToken)
+		respondWithError(rWriter, rq, apierror.Unauthorized(apierror.CodeInvalidToken, "invalid refresh token"))
+		return
 	}
 
-	jwtToken, err := auth.MakeJWT(dbToken.UserID.UUID, cfg.jwtSecret, time.Duration(1) * time.Hour)
+	dbUser, err := cfg.users.GetUserFromID(rq.Context(), dbToken.UserID.UUID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rWriter, rq, fmt.Errorf("looking up user: %w", err))
+		return
+	}
 	if err != nil {
-		respondWithError(rWriter, 500, "jwt token creation failed")
+		cfg.metrics.AuthFailure(metrics.AuthRefreshToken)
+		respondWithError(rWriter, rq, apierror.Unauthorized(apierror.CodeInvalidToken, "invalid refresh token"))
+		return
+	}
+	if !checkAccountState(rWriter, rq, dbUser) {
 		return
 	}
 
-	type returnVals struct {
-		Token	string	`json:"token"`
+	jwtToken, err := cfg.jwtKeys.MakeJWT(dbToken.UserID.UUID, accessTokenTTL)
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("creating access token: %w", err))
+		return
 	}
 
-	respBody := returnVals{
+	respBody := tokenResponse{
 		Token:	jwtToken,
 	}
 
@@ -216,150 +370,231 @@ func (cfg *apiConfig) refreshHandler(rWriter http.ResponseWriter, rq *http.Reque
 func (cfg *apiConfig) revokeHandler(rWriter http.ResponseWriter, rq *http.Request) {
 	refreshToken, err := auth.GetBearerToken(rq.Header)
 	if err != nil {
-		respondWithError(rWriter, 401, err.Error())
+		cfg.metrics.AuthFailure(metrics.AuthRefreshToken)
+		respondWithError(rWriter, rq, apierror.Unauthorized(apierror.CodeUnauthorized, "missing bearer token"))
+		return
+	}
+
+	dbToken, err := cfg.tokens.GetUserFromRefreshToken(rq.Context(), refreshToken)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rWriter, rq, fmt.Errorf("looking up refresh token: %w", err))
+		return
+	}
+	if err != nil {
+		cfg.metrics.AuthFailure(metrics.AuthRefreshToken)
+		respondWithError(rWriter, rq, apierror.Unauthorized(apierror.CodeInvalidToken, "invalid refresh token"))
+		return
 	}
 
-	err = cfg.dbQueries.RevokeRefreshToken(rq.Context(), refreshToken)
+	err = cfg.tokens.RevokeRefreshToken(rq.Context(), refreshToken)
 	if err != nil {
-		respondWithError(rWriter, 500, err.Error())
+		respondWithError(rWriter, rq, fmt.Errorf("revoking refresh token: %w", err))
+		return
 	}
+
+	cfg.audit(rq, audit.Event{
+		ActorID:	dbToken.UserID.UUID,
+		ActorType:	audit.ActorUser,
+		Action:		"auth.revoke",
+		TargetType:	"user",
+		TargetID:	dbToken.UserID.UUID.String(),
+	})
 	
 	respondWithJSON(rWriter, 204, nil)
 }
 
 func (cfg *apiConfig) postChirpsHandler(rWriter http.ResponseWriter, rq *http.Request) {
 	type parameters struct {
-		Body 	string 		`json:"body"`
+		Body 	string 		`json:"body" validate:"required"`
+	}
+
+	dbUser, ok := cfg.authenticate(rWriter, rq)
+	if !ok {
+		return
 	}
 
-	decoder := json.NewDecoder(rq.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err := decode.JSON(rWriter, rq, &params)
 	if err != nil {
-		respondWithError(rWriter, 500, "error decoding parameters")
+		respondWithError(rWriter, rq, err)
 		return
 	}
 
-	if len(params.Body) > 140 {
-		respondWithError(rWriter, 400, "chirp is too long")
+	limits := cfg.limitsFor(rq.Context(), dbUser)
+	if utf8.RuneCountInString(params.Body) > limits.MaxChirpLength {
+		respondWithError(rWriter, rq, apierror.Validation(apierror.FieldError{
+			Field:		"body",
+			Message:	fmt.Sprintf("must be at most %d characters", limits.MaxChirpLength),
+		}))
 		return
 	}
 
 	params.Body = profanityFilter(params.Body)
 
-	jwtToken, err := auth.GetBearerToken(rq.Header)
+	decision, err := cfg.moderateChirp(rq.Context(), dbUser, params.Body, uuid.Nil)
 	if err != nil {
-		respondWithError(rWriter, 401, err.Error())
+		respondWithError(rWriter, rq, fmt.Errorf("checking chirp: %w", err))
 		return
 	}
 
-	authID, err := auth.ValidateJWT(jwtToken, cfg.jwtSecret)
-	if err != nil{
-		respondWithError(rWriter, 401, err.Error())
+	visibility := chirpVisible
+	switch decision.Action {
+	case moderation.Reject:
+		err = cfg.recordModerationDecision(rq.Context(), dbUser.ID, uuid.NullUUID{}, params.Body, decision)
+		if err != nil {
+			logging.FromContext(rq.Context()).Error("error recording moderation decision", "err", err)
+		}
+		respondWithError(rWriter, rq, apierror.New(400, apierror.CodeContentRejected, "chirp rejected as spam"))
 		return
-	}
+	case moderation.Hold:
+		visibility = chirpHeld
+	case moderation.ShadowHide:
+		visibility = chirpHidden
+	}
+
+	var chirp database.Chirp
+	err = cfg.transactor.InTx(rq.Context(), func(tx store.Tx) error {
+		chirp, err = tx.CreateChirp(rq.Context(), database.CreateChirpParams{
+			Body: params.Body,
+			UserID: uuid.NullUUID{
+				UUID: dbUser.ID,
+				Valid: true,
+			},
+			Visibility: visibility,
+		})
+		if err != nil {
+			return err
+		}
 
-	chirp, err := cfg.dbQueries.CreateChirp(rq.Context(), database.CreateChirpParams{
-		Body: params.Body,
-		UserID: uuid.NullUUID{
-			UUID: authID,
-			Valid: true,
-		},
+		if chirp.Visibility != chirpVisible {
+			return nil
+		}
+		return emitWebhook(rq.Context(), tx, dbUser.ID, webhooks.EventChirpCreated, newChirpEventData(chirp))
 	})
-
 	if err != nil {
-		respondWithError(rWriter, 500, "Error creating chirp")
+		respondWithError(rWriter, rq, fmt.Errorf("creating chirp: %w", err))
 		return
 	}
+	cfg.metrics.ChirpsCreated.Inc()
 
-	type returnVals struct {
-		ID 			uuid.UUID	`json:"id"`
-		Created_at 	time.Time 	`json:"created_at"`
-		Updated_at	time.Time	`json:"updated_at"`
-		Body		string		`json:"body"`
-		User_id		uuid.UUID	`json:"user_id"`
+	if decision.Action != moderation.Allow {
+		chirpID := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+		err = cfg.recordModerationDecision(rq.Context(), dbUser.ID, chirpID, chirp.Body, decision)
+		if err != nil {
+			logging.FromContext(rq.Context()).Error("error recording moderation decision", "err", err)
+		}
 	}
 
-	respBody := returnVals{
-		ID: 		chirp.ID,
-		Created_at: chirp.CreatedAt,
-		Updated_at: chirp.UpdatedAt,
-		Body: 		chirp.Body,
-		User_id: 	chirp.UserID.UUID,
-	}
-	
-	respondWithJSON(rWriter, 201, respBody)
+	respondWithJSON(rWriter, 201, newChirpResponse(chirp))
 }
 
+// getMultipleChirpsHandler lists chirps, all of them unless a limit is given.
+// A limited page that has more after it links to the next one in a Link
+// header; its cursor names the page's last chirp by its creation time and
+// id, so it stays good after that chirp is deleted.
 func (cfg *apiConfig) getMultipleChirpsHandler(rWriter http.ResponseWriter, rq *http.Request) {
-
-	type returnVals struct {
-		ID 			uuid.UUID	`json:"id"`
-		Created_at 	time.Time 	`json:"created_at"`
-		Updated_at	time.Time	`json:"updated_at"`
-		Body		string		`json:"body"`
-		User_id		uuid.UUID	`json:"user_id"`
-	}
-
 	authorID := rq.URL.Query().Get("author_id")
 	sortParameter := rq.URL.Query().Get("sort")
 
-	var chirps []database.Chirp
-	var err error
+	limit := 0
+	if value := rq.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxChirpPageSize {
+			respondWithError(rWriter, rq, apierror.InvalidParameter("limit", "must be between 1 and "+strconv.Itoa(maxChirpPageSize)))
+			return
+		}
+		limit = n
+	}
 
+	params := database.ListChirpsParams{
+		ViewerID:	cfg.viewerID(rq),
+		MaxChirps:	math.MaxInt32,
+	}
 	if authorID != "" {
 		author_uuid, err := uuid.Parse(authorID)
 		if err != nil {
-			respondWithError(rWriter, 500, "error parsing author id")
+			respondWithError(rWriter, rq, apierror.InvalidParameter("author_id", "must be a UUID"))
 			return
 		}
-		dbUUID := uuid.NullUUID{
+		params.AuthorID = uuid.NullUUID{
 			UUID: author_uuid,
 			Valid: true,
 		}
-		chirps, err = cfg.dbQueries.GetChirpsFromAuthor(rq.Context(), dbUUID)
-		if err != nil {
-			respondWithError(rWriter, 500, "error retrieving chirps")
+	}
+	if sortParameter == "desc" {
+		// The first page starts before every chirp there can be.
+		params.AfterCreatedAt = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+		params.AfterID = uuid.Max
+	}
+	if cursor := rq.URL.Query().Get("cursor"); cursor != "" {
+		var ok bool
+		params.AfterCreatedAt, params.AfterID, ok = decodeChirpCursor(cursor)
+		if !ok {
+			respondWithError(rWriter, rq, apierror.InvalidParameter("cursor", "must be a cursor from a previous page"))
 			return
 		}
+	}
+	if limit > 0 {
+		// One more than the page tells whether there is a next one.
+		params.MaxChirps = int32(limit) + 1
+	}
+
+	var chirps []database.Chirp
+	var err error
+	if sortParameter == "desc" {
+		chirps, err = cfg.chirps.ListChirpsDesc(rq.Context(), database.ListChirpsDescParams(params))
 	} else {
-		chirps, err = cfg.dbQueries.GetAllChirps(rq.Context())
-		if err != nil {
-			respondWithError(rWriter, 500, "error retrieving chirps")
-			return
-		}
+		chirps, err = cfg.chirps.ListChirps(rq.Context(), params)
+	}
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("retrieving chirps: %w", err))
+		return
 	}
 
-	var returnArr []returnVals
+	if limit > 0 && len(chirps) > limit {
+		chirps = chirps[:limit]
+		last := chirps[limit-1]
+		next := *rq.URL
+		query := next.Query()
+		query.Set("cursor", encodeChirpCursor(last.CreatedAt, last.ID))
+		next.RawQuery = query.Encode()
+		rWriter.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
 
+	returnArr := []chirpResponse{}
 	for _, chirp := range chirps {
-		respBody := returnVals{
-			ID: 		chirp.ID,
-			Created_at: chirp.CreatedAt,
-			Updated_at: chirp.UpdatedAt,
-			Body: 		chirp.Body,
-			User_id: 	chirp.UserID.UUID,
-		}
-		returnArr = append(returnArr, respBody)
+		returnArr = append(returnArr, newChirpResponse(chirp))
 	}
+	respondWithJSON(rWriter, 200, returnArr)
+}
 
-	if sortParameter == "desc" {
-		sortAlgorithm := func(i, j int) bool {
-			idOne := returnArr[i].Created_at
-			idTwo := returnArr[j].Created_at
-			return idOne.String() > idTwo.String() 
-		}
-		sort.Slice(returnArr[:], sortAlgorithm)
-	} else if sortParameter == "asc" || sortParameter == "" {
-		sortAlgorithm := func(i, j int) bool {
-			idOne := returnArr[i].Created_at
-			idTwo := returnArr[j].Created_at
-			return idOne.String() < idTwo.String() 
-		}
-		sort.Slice(returnArr[:], sortAlgorithm)
-	}
+// encodeChirpCursor returns the cursor of a page ending with the chirp
+// created at createdAt with id. Clients treat it as opaque.
+func encodeChirpCursor(createdAt time.Time, id uuid.UUID) string {
+	key := createdAt.UTC().Format(time.RFC3339Nano) + "," + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
 
-	respondWithJSON(rWriter, 200, returnArr)
+// decodeChirpCursor reverses encodeChirpCursor. ok is false for anything it
+// didn't return.
+func decodeChirpCursor(cursor string) (createdAt time.Time, id uuid.UUID, ok bool) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, false
+	}
+	timestamp, rawID, found := strings.Cut(string(key), ",")
+	if !found {
+		return time.Time{}, uuid.Nil, false
+	}
+	createdAt, err = time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return time.Time{}, uuid.Nil, false
+	}
+	id, err = uuid.Parse(rawID)
+	if err != nil {
+		return time.Time{}, uuid.Nil, false
+	}
+	return createdAt, id, true
 }
 
 func (cfg *apiConfig) getOneChirpHandler(rWriter http.ResponseWriter, rq *http.Request) {
@@ -367,269 +602,540 @@ func (cfg *apiConfig) getOneChirpHandler(rWriter http.ResponseWriter, rq *http.R
 	id := rq.PathValue("chirpID")
 	chirpId, err := uuid.Parse(id)
 	if err != nil {
-		respondWithError(rWriter, 500, "Error parsing chirp id")
+		respondWithError(rWriter, rq, apierror.InvalidParameter("chirpID", "must be a UUID"))
 		return
 	}
 	
-	chirp, err := cfg.dbQueries.GetOneChirp(rq.Context(), chirpId)
+	chirp, err := cfg.chirps.GetOneChirp(rq.Context(), chirpId)
 	if err != nil {
-		respondWithError(rWriter, 404, "No chirp found")
+		respondWithError(rWriter, rq, apierror.NoRows(err, apierror.NotFound("chirp not found")))
 		return
 	}
 
-	type returnVals struct {
-		ID 			uuid.UUID	`json:"id"`
-		Created_at 	time.Time 	`json:"created_at"`
-		Updated_at	time.Time	`json:"updated_at"`
-		Body		string		`json:"body"`
-		User_id		uuid.UUID	`json:"user_id"`
+	author, err := cfg.users.GetUserFromID(rq.Context(), chirp.UserID.UUID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rWriter, rq, fmt.Errorf("looking up author: %w", err))
+		return
 	}
-
-	respBody := returnVals{
-		ID: 		chirp.ID,
-		Created_at: chirp.CreatedAt,
-		Updated_at: chirp.UpdatedAt,
-		Body: 		chirp.Body,
-		User_id: 	chirp.UserID.UUID,
+	if err != nil || !chirpVisibleTo(chirp, author, cfg.viewerID(rq)) {
+		respondWithError(rWriter, rq, apierror.NotFound("chirp not found"))
+		return
 	}
 
-	respondWithJSON(rWriter, 200, respBody)
+	respondWithJSON(rWriter, 200, newChirpResponse(chirp))
 }
 
 func (cfg *apiConfig) deleteOneChirpHandler(rWriter http.ResponseWriter, rq *http.Request) {
 	
 
-	jwtToken, err := auth.GetBearerToken(rq.Header)
-	if err != nil {
-		respondWithError(rWriter, 401, err.Error())
-		return
-	}
-
-	authID, err := auth.ValidateJWT(jwtToken, cfg.jwtSecret)
-	if err != nil{
-		respondWithError(rWriter, 401, err.Error())
+	dbUser, ok := cfg.authenticate(rWriter, rq)
+	if !ok {
 		return
 	}
+	authID := dbUser.ID
 
 	id := rq.PathValue("chirpID")
 	chirpId, err := uuid.Parse(id)
 	if err != nil {
-		respondWithError(rWriter, 500, "error parsing chirp id")
+		respondWithError(rWriter, rq, apierror.InvalidParameter("chirpID", "must be a UUID"))
 		return
 	}
 
-	chirp, err := cfg.dbQueries.GetOneChirp(rq.Context(), chirpId)
+	chirp, err := cfg.chirps.GetOneChirp(rq.Context(), chirpId)
 	if err != nil {
-		respondWithError(rWriter, 404, "chirp not found")
+		respondWithError(rWriter, rq, apierror.NoRows(err, apierror.NotFound("chirp not found")))
 		return
 	}
 
 	if chirp.UserID.UUID != authID {
-		respondWithError(rWriter, 403, "unauthorized user")
+		respondWithError(rWriter, rq, apierror.Forbidden(apierror.CodeForbidden, "chirp belongs to another user"))
 		return
 	}
 
-	err = cfg.dbQueries.DeleteChirp(rq.Context(), chirp.ID)
+	err = cfg.transactor.InTx(rq.Context(), func(tx store.Tx) error {
+		err := tx.DeleteChirp(rq.Context(), chirp.ID)
+		if err != nil {
+			return err
+		}
+		return emitWebhook(rq.Context(), tx, authID, webhooks.EventChirpDeleted, newChirpEventData(chirp))
+	})
 	if err != nil {
-		respondWithError(rWriter, 500, "error deleting chirp")
+		respondWithError(rWriter, rq, fmt.Errorf("deleting chirp: %w", err))
 		return
 	}
 
+	cfg.audit(rq, audit.Event{
+		ActorID:	authID,
+		ActorType:	audit.ActorUser,
+		Action:		"chirp.delete",
+		TargetType:	"chirp",
+		TargetID:	chirp.ID.String(),
+		Before:		map[string]string{"body": chirp.Body, "user_id": chirp.UserID.UUID.String()},
+	})
+
 	respondWithJSON(rWriter, 204, nil)
 }
 
-func (cfg *apiConfig) usersPutHandler(rWriter http.ResponseWriter, rq *http.Request) {
+func (cfg *apiConfig) editChirpHandler(rWriter http.ResponseWriter, rq *http.Request) {
 	type parameters struct {
-		Password 	string `json:"password"`
-		Email 		string `json:"email"`
+		Body 	string 		`json:"body" validate:"required"`
 	}
 
-	
-	jwtToken, err := auth.GetBearerToken(rq.Header)
-	if err != nil {
-		respondWithError(rWriter, 401, err.Error())
+	dbUser, ok := cfg.authenticate(rWriter, rq)
+	if !ok {
 		return
 	}
 
-	authID, err := auth.ValidateJWT(jwtToken, cfg.jwtSecret)
-	if err != nil{
-		respondWithError(rWriter, 401, err.Error())
+	chirpId, err := uuid.Parse(rq.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(rWriter, rq, apierror.InvalidParameter("chirpID", "must be a UUID"))
 		return
 	}
 
-	decoder := json.NewDecoder(rq.Body)
-	rqParams := parameters{}
-	err = decoder.Decode(&rqParams)
+	chirp, err := cfg.chirps.GetOneChirp(rq.Context(), chirpId)
 	if err != nil {
-		respondWithError(rWriter, 500, "error decoding parameters")
+		respondWithError(rWriter, rq, apierror.NoRows(err, apierror.NotFound("chirp not found")))
 		return
 	}
 
-	hashPass, err := auth.HashPassword(rqParams.Password)
+	if chirp.UserID.UUID != dbUser.ID {
+		respondWithError(rWriter, rq, apierror.Forbidden(apierror.CodeForbidden, "chirp belongs to another user"))
+		return
+	}
+
+	limits := cfg.limitsFor(rq.Context(), dbUser)
+	if !limits.CanEditChirps {
+		respondWithError(rWriter, rq, apierror.Forbidden(apierror.CodePlanRequired, "editing chirps requires Chirpy Red"))
+		return
+	}
+
+	params := parameters{}
+	err = decode.JSON(rWriter, rq, &params)
 	if err != nil {
-		respondWithError(rWriter, 500, "error hashing password")
+		respondWithError(rWriter, rq, err)
 		return
 	}
 
-	dbParams := database.UpdatePasswordAndEmailParams{
-		HashedPassword: hashPass,
-		Email:			rqParams.Email,
-		ID:				authID,
+	if utf8.RuneCountInString(params.Body) > limits.MaxChirpLength {
+		respondWithError(rWriter, rq, apierror.Validation(apierror.FieldError{
+			Field:		"body",
+			Message:	fmt.Sprintf("must be at most %d characters", limits.MaxChirpLength),
+		}))
+		return
 	}
 
-	dbUser, err := cfg.dbQueries.UpdatePasswordAndEmail(rq.Context(), dbParams)
+	params.Body = profanityFilter(params.Body)
+
+	// An edit is a new submission as far as moderation is concerned, but a
+	// chirp an admin has held or hidden stays that way.
+	decision, err := cfg.moderateChirp(rq.Context(), dbUser, params.Body, chirp.ID)
 	if err != nil {
-		respondWithError(rWriter, 500, "error updating password and email")
+		respondWithError(rWriter, rq, fmt.Errorf("checking chirp: %w", err))
 		return
 	}
 
-	type returnVals struct {
-		Id 					uuid.UUID	`json:"id"`
-		Created_at 			time.Time 	`json:"created_at"`
-		Updated_at 			time.Time 	`json:"updated_at"`
-		Email				string		`json:"email"`
-		Is_chirpy_red		bool		`json:"is_chirpy_red"`
+	visibility := chirp.Visibility
+	switch decision.Action {
+	case moderation.Reject:
+		err = cfg.recordModerationDecision(rq.Context(), dbUser.ID, uuid.NullUUID{}, params.Body, decision)
+		if err != nil {
+			logging.FromContext(rq.Context()).Error("error recording moderation decision", "err", err)
+		}
+		respondWithError(rWriter, rq, apierror.New(400, apierror.CodeContentRejected, "chirp rejected as spam"))
+		return
+	case moderation.Hold:
+		if visibility == chirpVisible {
+			visibility = chirpHeld
+		}
+	case moderation.ShadowHide:
+		visibility = chirpHidden
 	}
 
-	respBody := returnVals{
-		Id:					dbUser.ID,
-		Created_at: 		dbUser.CreatedAt,
-		Updated_at: 		dbUser.UpdatedAt,
-		Email:				dbUser.Email,
-		Is_chirpy_red:		dbUser.IsChirpyRed.Bool,
+	updated, err := cfg.chirps.UpdateChirpBody(rq.Context(), database.UpdateChirpBodyParams{
+		Body:		params.Body,
+		Visibility:	visibility,
+		ID:			chirp.ID,
+	})
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("updating chirp: %w", err))
+		return
 	}
-	
-	respondWithJSON(rWriter, 200, respBody)
-}
 
+	if decision.Action != moderation.Allow {
+		chirpID := uuid.NullUUID{UUID: updated.ID, Valid: true}
+		err = cfg.recordModerationDecision(rq.Context(), dbUser.ID, chirpID, updated.Body, decision)
+		if err != nil {
+			logging.FromContext(rq.Context()).Error("error recording moderation decision", "err", err)
+		}
+	}
+
+	cfg.audit(rq, audit.Event{
+		ActorID:	dbUser.ID,
+		ActorType:	audit.ActorUser,
+		Action:		"chirp.edit",
+		TargetType:	"chirp",
+		TargetID:	chirp.ID.String(),
+		Before:		map[string]string{"body": chirp.Body},
+		After:		map[string]string{"body": updated.Body},
+	})
+
+	respondWithJSON(rWriter, 200, newChirpResponse(updated))
+}
 
-func (cfg *apiConfig) polkaWebhooksHandler(rWriter http.ResponseWriter, rq *http.Request) {
+func (cfg *apiConfig) usersPutHandler(rWriter http.ResponseWriter, rq *http.Request) {
 	type parameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
+		Password 	string `json:"password" validate:"required,maxbytes=72"`
+		Email 		string `json:"email" validate:"required,email,max=254"`
 	}
 
-	decoder := json.NewDecoder(rq.Body)
-	rqParams := parameters{}
-	err := decoder.Decode(&rqParams)
-	if err != nil {
-		respondWithError(rWriter, 500, "error decoding parameters")
+	
+	authUser, ok := cfg.authenticate(rWriter, rq)
+	if !ok {
 		return
 	}
+	authID := authUser.ID
 
-	apiKey, err := auth.GetAPIKey(rq.Header)
+	rqParams := parameters{}
+	err := decode.JSON(rWriter, rq, &rqParams)
 	if err != nil {
-		respondWithError(rWriter, 401, "no apiKey header found")
+		respondWithError(rWriter, rq, err)
 		return
 	}
 
-	cfgApiKey := cfg.polkaKey
-	if apiKey != cfgApiKey {
-		respondWithError(rWriter, 401, "incorrect api key")
+	hashPass, err := auth.HashPassword(rqParams.Password)
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("hashing password: %w", err))
 		return
 	}
 
+	dbParams := database.UpdatePasswordAndEmailParams{
+		HashedPassword: hashPass,
+		Email:			rqParams.Email,
+		ID:				authID,
+	}
 
-	if rqParams.Event != "user.upgraded" {
-		respondWithJSON(rWriter, 204, nil)
-	} else {
-		id, err := uuid.Parse(rqParams.Data.UserID)
-		if err != nil {
-			respondWithError(rWriter, 500, "error parsing user id")
-			return
-		}
-		_, err = cfg.dbQueries.UpgradeUserToRed(rq.Context(), id)
-		if err != nil {
-			respondWithError(rWriter, 404, "error upgrading user")
-			return
-		}
+	dbUser, err := cfg.users.UpdatePasswordAndEmail(rq.Context(), dbParams)
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("updating password and email: %w", err))
+		return
 	}
 
+	cfg.audit(rq, audit.Event{
+		ActorID:	authID,
+		ActorType:	audit.ActorUser,
+		Action:		"user.credentials_update",
+		TargetType:	"user",
+		TargetID:	authID.String(),
+		Before:		map[string]string{"email": authUser.Email},
+		After:		map[string]string{"email": dbUser.Email},
+	})
 
-	respondWithJSON(rWriter, 204, nil)
+	respondWithJSON(rWriter, 200, newUserResponse(dbUser))
 }
 
 
-func main() {
-	err := godotenv.Load()
+// runServe implements `chirpy serve`, the default command.
+func runServe(conf config.Config, args []string) error {
+	flags := newFlagSet("serve")
+	flags.StringVar(&conf.Server.Addr, "addr", conf.Server.Addr, "`address` to listen on")
+	flags.StringVar(&conf.Server.MetricsAddr, "metrics-addr", conf.Server.MetricsAddr, "`address` to serve /metrics on; empty turns it off")
+	flags.StringVar(&conf.Server.TLSCert, "tls-cert", conf.Server.TLSCert, "TLS certificate `file`; serves HTTPS when set together with -tls-key")
+	flags.StringVar(&conf.Server.TLSKey, "tls-key", conf.Server.TLSKey, "TLS private key `file`")
+	flags.DurationVar(&conf.Server.ReadHeaderTimeout, "read-header-timeout", conf.Server.ReadHeaderTimeout, "time allowed to read request headers")
+	flags.DurationVar(&conf.Server.ReadTimeout, "read-timeout", conf.Server.ReadTimeout, "time allowed to read a whole request")
+	flags.DurationVar(&conf.Server.WriteTimeout, "write-timeout", conf.Server.WriteTimeout, "time allowed to write a response")
+	flags.DurationVar(&conf.Server.IdleTimeout, "idle-timeout", conf.Server.IdleTimeout, "how long to keep idle connections open")
+	flags.IntVar(&conf.Server.MaxHeaderBytes, "max-header-bytes", conf.Server.MaxHeaderBytes, "largest request headers accepted, in `bytes`")
+	flags.DurationVar(&conf.Server.DrainDelay, "drain-delay", conf.Server.DrainDelay, "how long to keep serving, reporting not ready, after SIGTERM")
+	flags.DurationVar(&conf.Server.ShutdownTimeout, "shutdown-timeout", conf.Server.ShutdownTimeout, "time allowed for in-flight requests and jobs to finish on shutdown")
+	err := parseFlags(flags, args)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
+	}
+	err = conf.Server.Validate()
+	if err != nil {
+		return err
 	}
-	serveMux := http.NewServeMux()
 
-	dbURL := os.Getenv("DB_URL")
-	db, err := sql.Open("postgres", dbURL)
-	
+	shutdownTracing, err := tracing.Setup(context.Background(), conf.Tracing.Exporter, conf.Tracing.SampleRatio)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := shutdownTracing(ctx)
+		if err != nil {
+			slog.Error("error flushing traces", "err", err)
+		}
+	}()
 
-	dbQueries := database.New(db)
-	platform := os.Getenv("PLATFORM")
-	jwtSecret := os.Getenv("JWT_SECRET")
-	polkaKey := os.Getenv("POLKA_KEY")
+	db, err := openDB(conf)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 
-	apiCfg := &apiConfig{
-		fileserverHits:	atomic.Int32{},
-		dbQueries: 		dbQueries,
-		platform:		platform,
-		jwtSecret: 		jwtSecret,	
-		polkaKey: 		polkaKey,	
+	// Background workers are stopped and waited for before the deferred
+	// db.Close, however runServe returns.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	defer workers.Wait()
+	defer stopWorkers()
+	runWorker := func(work func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			work(workerCtx)
+		}()
 	}
-	serveHandler := http.StripPrefix("/app", http.FileServer(http.Dir(".")))
-	serveMux.Handle("/app/", apiCfg.middlewareMetricsInc(serveHandler))
 
-	healthHandler := func(rWriter http.ResponseWriter, rq *http.Request) {
-		rWriter.Header().Add("Content-Type", "text/plain; charset=utf-8" )
-		rWriter.WriteHeader(200)
-		rWriter.Write([]byte("OK"))
+	migrator, err := newMigrator(db, conf.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	// Instances starting together queue on the migration lock; all but the
+	// first find nothing left to apply.
+	if conf.AutoMigrate {
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			return err
+		}
+		for _, migration := range applied {
+			slog.Info("applied migration", "name", migration.Name)
+		}
 	}
-	serveMux.HandleFunc("GET /api/healthz", healthHandler)
 
-	serveMux.HandleFunc("GET /api/chirps", apiCfg.getMultipleChirpsHandler)
+	dbSystem := tracing.SystemPostgres
+	if sqlite.IsURL(conf.DatabaseURL) {
+		dbSystem = tracing.SystemSQLite
+	}
+	dbQueries := database.New(tracing.DB(db, dbSystem))
 
-	serveMux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.getOneChirpHandler)
-	
-	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.deleteOneChirpHandler)
+	jwtKeys := auth.NewKeyring(string(conf.JWTSecret))
+	err = loadSigningKeys(context.Background(), dbQueries, jwtKeys, string(conf.JWTSecret))
+	if err != nil {
+		slog.Error("error loading signing keys", "err", err)
+	}
+	if !jwtKeys.CanSign() {
+		return errors.New("no key to sign access tokens with: set JWT_SECRET or run chirpy rotate-keys")
+	}
+	jwtKeys.Refresh = func() error {
+		return loadSigningKeys(context.Background(), dbQueries, jwtKeys, string(conf.JWTSecret))
+	}
+	runWorker(func(ctx context.Context) {
+		refreshSigningKeys(ctx, jwtKeys)
+	})
 
-	serveMux.HandleFunc("POST /api/chirps", apiCfg.postChirpsHandler)
+	trustedProxies, err := ratelimit.ParseTrustedProxies(strings.Join(conf.TrustedProxies, ","))
+	if err != nil {
+		return err
+	}
 
-	serveMux.HandleFunc("POST /api/users", apiCfg.usersHandler)
+	var limitStore ratelimit.Store
+	if conf.RateLimit.Store == config.RateLimitPostgres {
+		pgStore := ratelimit.NewPostgresStore(dbQueries)
+		runWorker(func(ctx context.Context) {
+			cleanupRateLimits(ctx, pgStore)
+		})
+		limitStore = pgStore
+	} else {
+		limitStore = ratelimit.NewMemoryStore()
+	}
 
-	serveMux.HandleFunc("PUT /api/users", apiCfg.usersPutHandler)
+	authPolicy, err := ratelimit.ParsePolicy("auth", conf.RateLimit.Auth)
+	if err != nil {
+		return err
+	}
+	chirpsPolicy, err := ratelimit.ParsePolicy("chirps", conf.RateLimit.Chirps)
+	if err != nil {
+		return err
+	}
 
-	serveMux.HandleFunc("POST /api/login", apiCfg.loginHandler)
+	planLimits := entitlements.Default()
+	if conf.EntitlementsFile != "" {
+		planLimits, err = entitlements.Load(conf.EntitlementsFile)
+		if err != nil {
+			return err
+		}
+	}
 
-	serveMux.HandleFunc("POST /api/refresh", apiCfg.refreshHandler)
+	appMetrics := metrics.New(db)
 
-	serveMux.HandleFunc("POST /api/revoke", apiCfg.revokeHandler)
+	var polkaKeys []string
+	for _, key := range conf.PolkaKeys {
+		polkaKeys = append(polkaKeys, string(key))
+	}
 
-	serveMux.HandleFunc("POST /api/polka/webhooks", apiCfg.polkaWebhooksHandler)
+	principals := ratelimit.PrincipalResolver{
+		Keys:			jwtKeys,
+		TrustedProxies:	trustedProxies,
+	}
 
-	serveMux.HandleFunc("GET /admin/metrics", apiCfg.metricsHandler)
-	
-	serveMux.HandleFunc("POST /admin/reset", apiCfg.resetHandler)
+	apiCfg := &apiConfig{
+		metrics:		appMetrics,
+		db:				db,
+		dbQueries: 		dbQueries,
+		dbSystem:		dbSystem,
+		users:			dbQueries,
+		chirps:			dbQueries,
+		tokens:			dbQueries,
+		platform:		conf.Platform,
+		jwtKeys:		jwtKeys,
+		polkaKeys: 		polkaKeys,
+		limiter:		&ratelimit.Limiter{
+			Store:		limitStore,
+			Principal:	principals.Key,
+		},
+		moderator:		moderation.NewPipeline(),
+		auditor:		audit.New(dbQueries, principals.ClientIP),
+		entitlements:	planLimits,
+		webhookLoopback: conf.Webhooks.AllowLoopback,
+		health:			newHealthChecker(db, migrator, dbQueries),
+	}
+	apiCfg.limiter.Multiplier = apiCfg.rateLimitMultiplier
+	apiCfg.transactor = store.SQL{Queries: dbQueries, DB: db, WithTx: apiCfg.withTx}
+
+	runWorker(apiCfg.runSubscriptionExpiry)
+	runWorker(func(ctx context.Context) {
+		webhooks.NewWorker(dbQueries, conf.Webhooks.AllowLoopback).Run(ctx, 5*time.Second)
+	})
+
+	// Metrics are served apart from the API, so that they can be kept off
+	// the public network. Their address is bound before anything starts,
+	// so a port that is taken is a startup error.
+	var metricsServer *http.Server
+	var metricsListener net.Listener
+	if conf.Server.MetricsAddr != "" {
+		metricsListener, err = net.Listen("tcp", conf.Server.MetricsAddr)
+		if err != nil {
+			return fmt.Errorf("listening for metrics: %w", err)
+		}
+		metricsServer = &http.Server{
+			Handler:			apiCfg.metricsRoutes(),
+			ReadHeaderTimeout:	conf.Server.ReadHeaderTimeout,
+			ReadTimeout:		conf.Server.ReadTimeout,
+			WriteTimeout:		conf.Server.WriteTimeout,
+			IdleTimeout:		conf.Server.IdleTimeout,
+		}
+	}
+
+	jobRunner := jobs.NewRunner(dbQueries)
+	jobs.Register(jobRunner, jobWebhookEvent, apiCfg.fanOutWebhook)
+	jobRunner.Start()
 
 	server := &http.Server{
-		Handler:	serveMux,
-		Addr: 		":8080",	
+		Handler:			apiCfg.handler(authPolicy, chirpsPolicy, slog.Default()),
+		Addr: 				conf.Server.Addr,
+		ReadHeaderTimeout:	conf.Server.ReadHeaderTimeout,
+		ReadTimeout:		conf.Server.ReadTimeout,
+		WriteTimeout:		conf.Server.WriteTimeout,
+		IdleTimeout:		conf.Server.IdleTimeout,
+		MaxHeaderBytes:		conf.Server.MaxHeaderBytes,
+	}
+
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	serveErr := make(chan error, 2)
+	go func() {
+		if conf.Server.TLSCert != "" {
+			serveErr <- server.ListenAndServeTLS(conf.Server.TLSCert, conf.Server.TLSKey)
+		} else {
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+	if metricsServer != nil {
+		go func() {
+			serveErr <- fmt.Errorf("serving metrics: %w", metricsServer.Serve(metricsListener))
+		}()
+	}
+	slog.Info("serving", "addr", conf.Server.Addr, "tls", conf.Server.TLSCert != "", "metrics_addr", conf.Server.MetricsAddr)
+	apiCfg.ready.Store(true)
+
+	// When a listener fails the other may still be serving requests, so
+	// both are shut down the same way as on a signal, just without the
+	// drain delay.
+	var serveFailed error
+	select {
+	case serveFailed = <-serveErr:
+		apiCfg.ready.Store(false)
+		slog.Error("listener failed, shutting down", "err", serveFailed)
+	case <-signalCtx.Done():
+		// A second signal kills the process the usual way.
+		stopSignals()
+
+		apiCfg.ready.Store(false)
+		slog.Info("shutting down", "drain_delay", conf.Server.DrainDelay)
+		time.Sleep(conf.Server.DrainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.Server.ShutdownTimeout)
+	defer cancel()
+	err = server.Shutdown(ctx)
+	if err != nil {
+		slog.Error("error shutting down server", "err", err)
+	}
+	if metricsServer != nil {
+		err = metricsServer.Shutdown(ctx)
+		if err != nil {
+			slog.Error("error shutting down metrics server", "err", err)
+		}
 	}
-	server.ListenAndServe()
+	err = jobRunner.Stop(ctx)
+	if err != nil {
+		slog.Error("error stopping job runner", "err", err)
+	}
+	slog.Info("shut down")
+	return serveFailed
+}
 
+// viewerID returns the user making the request when it carries a valid access
+// token. Anonymous requests are fine, so a bad or missing token just yields an
+// invalid ID rather than an error.
+func (cfg *apiConfig) viewerID(rq *http.Request) uuid.NullUUID {
+	jwtToken, err := auth.GetBearerToken(rq.Header)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	authID, err := cfg.jwtKeys.ValidateJWT(jwtToken)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	logging.SetUserID(rq.Context(), authID)
+	return uuid.NullUUID{UUID: authID, Valid: true}
 }
 
-func respondWithError(rWriter http.ResponseWriter, code int, msg string) {
-	type errorStruct struct{
-		Error string `json:"error"`
+// chirpVisibleTo reports whether viewerID may see chirp. Authors always see
+// their own chirps, including held ones and those of a shadow-banned account.
+func chirpVisibleTo(chirp database.Chirp, author database.User, viewerID uuid.NullUUID) bool {
+	if viewerID.Valid && chirp.UserID.Valid && viewerID.UUID == chirp.UserID.UUID {
+		return true
 	}
-	eStruct := errorStruct {
-		Error: msg,
+	return chirp.Visibility == chirpVisible && accountState(author) != stateShadowBanned
+}
+
+func cleanupRateLimits(ctx context.Context, store *ratelimit.PostgresStore) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := store.Cleanup(ctx, time.Now().Add(-24 * time.Hour))
+		if err != nil {
+			slog.Error("error cleaning up rate limits", "err", err)
+		}
 	}
-	respondWithJSON(rWriter, code, eStruct)
+}
+
+// respondWithError writes err as a problem+json response. Return an
+// *apierror.Error for anything the client should know about; any other error
+// is logged and reported as an internal error.
+func respondWithError(rWriter http.ResponseWriter, rq *http.Request, err error) {
+	apierror.Write(rWriter, rq, err)
 }
 
 func respondWithJSON(rWriter http.ResponseWriter, code int, payload interface{}) {
@@ -639,7 +1145,7 @@ func respondWithJSON(rWriter http.ResponseWriter, code int, payload interface{})
 	}
 	dat, err := json.Marshal(payload)
 	if err != nil {
-			log.Printf("Error marshalling JSON: %s", err)
+			slog.Error("error marshalling JSON", "err", err)
 			rWriter.WriteHeader(500)
 			return
 	}
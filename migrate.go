@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jamistoso/chirpy/internal/config"
+	"github.com/jamistoso/chirpy/internal/migrate"
+	"github.com/jamistoso/chirpy/internal/sqlite"
+)
+
+//go:embed sql/schema/*.sql sql/sqlite/*.sql
+var migrationFiles embed.FS
+
+// newMigrator returns a migrator for dbURL's backend with the migrations
+// built into the binary.
+func newMigrator(db *sql.DB, dbURL string) (*migrate.Migrator, error) {
+	dialect, dir := migrate.Postgres, "sql/schema"
+	if sqlite.IsURL(dbURL) {
+		dialect, dir = migrate.SQLite, "sql/sqlite"
+	}
+	fsys, err := fs.Sub(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, dialect, fsys)
+}
+
+const migrateUsage = `usage: chirpy migrate up|down|status|redo
+
+  up      apply all pending migrations
+  down    roll back the most recent migration
+  status  list migrations and whether they are applied
+  redo    roll back the most recent migration and apply it again`
+
+// runMigrate implements `chirpy migrate`.
+func runMigrate(conf config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+	db, err := openDB(conf)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	m, err := newMigrator(db, conf.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied %s\n", migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		migration, err := m.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back %s\n", migration.Name)
+	case "redo":
+		migration, err := m.Redo(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("redid %s\n", migration.Name)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "APPLIED AT\tMIGRATION")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%s\t%s\n", appliedAt, status.Name)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/apierror"
+	"github.com/jamistoso/chirpy/internal/audit"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/decode"
+	"github.com/jamistoso/chirpy/internal/moderation"
+	"github.com/jamistoso/chirpy/internal/store"
+	"github.com/jamistoso/chirpy/internal/webhooks"
+)
+
+// moderationWindow is how far back a user's chirps are fed to the scorers.
+const moderationWindow = 24 * time.Hour
+
+// moderateChirp scores body against the author's recent chirps. When an
+// existing chirp is being edited its ID is passed as editing so the old
+// version isn't counted as a duplicate of the new one.
+func (cfg *apiConfig) moderateChirp(ctx context.Context, dbUser database.User, body string, editing uuid.UUID) (moderation.Decision, error) {
+	recent, err := cfg.chirps.GetRecentChirpsFromAuthor(ctx, database.GetRecentChirpsFromAuthorParams{
+		UserID: uuid.NullUUID{UUID: dbUser.ID, Valid: true},
+		Since:  time.Now().Add(-moderationWindow),
+	})
+	if err != nil {
+		return moderation.Decision{}, err
+	}
+
+	sub := moderation.Submission{
+		UserID:           dbUser.ID,
+		Body:             body,
+		AccountCreatedAt: dbUser.CreatedAt,
+		Now:              time.Now(),
+	}
+	for _, chirp := range recent {
+		if chirp.ID == editing {
+			continue
+		}
+		sub.Recent = append(sub.Recent, moderation.Post{
+			Body:      chirp.Body,
+			CreatedAt: chirp.CreatedAt,
+		})
+	}
+	return cfg.moderator.Evaluate(ctx, sub), nil
+}
+
+// recordModerationDecision stores a non-allow decision. Held and hidden chirps
+// go into the review queue; rejected ones never became chirps so are only kept
+// for the record.
+func (cfg *apiConfig) recordModerationDecision(ctx context.Context, userID uuid.UUID, chirpID uuid.NullUUID, body string, decision moderation.Decision) error {
+	status := "pending"
+	if decision.Action == moderation.Reject {
+		status = "logged"
+	}
+	_, err := cfg.dbQueries.CreateModerationDecision(ctx, database.CreateModerationDecisionParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+		Body:    body,
+		Action:  string(decision.Action),
+		Score:   decision.Score,
+		Reasons: strings.Join(decision.Reasons(), "\n"),
+		Status:  status,
+	})
+	return err
+}
+
+type moderationDecisionResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Created_at time.Time  `json:"created_at"`
+	User_id    uuid.UUID  `json:"user_id"`
+	Chirp_id   *uuid.UUID `json:"chirp_id"`
+	Body       string     `json:"body"`
+	Action     string     `json:"action"`
+	Score      float64    `json:"score"`
+	Reasons    []string   `json:"reasons"`
+	Status     string     `json:"status"`
+	Resolution string     `json:"resolution,omitempty"`
+}
+
+func newModerationDecisionResponse(decision database.ModerationDecision) moderationDecisionResponse {
+	resp := moderationDecisionResponse{
+		ID:         decision.ID,
+		Created_at: decision.CreatedAt,
+		User_id:    decision.UserID,
+		Body:       decision.Body,
+		Action:     decision.Action,
+		Score:      decision.Score,
+		Reasons:    strings.Split(decision.Reasons, "\n"),
+		Status:     decision.Status,
+		Resolution: decision.Resolution.String,
+	}
+	if decision.ChirpID.Valid {
+		resp.Chirp_id = &decision.ChirpID.UUID
+	}
+	return resp
+}
+
+func (cfg *apiConfig) moderationQueueHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	if _, ok := cfg.requireAdmin(rWriter, rq); !ok {
+		return
+	}
+
+	decisions, err := cfg.dbQueries.GetPendingModerationDecisions(rq.Context())
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("retrieving moderation queue: %w", err))
+		return
+	}
+
+	returnArr := []moderationDecisionResponse{}
+	for _, decision := range decisions {
+		returnArr = append(returnArr, newModerationDecisionResponse(decision))
+	}
+	respondWithJSON(rWriter, 200, returnArr)
+}
+
+// resolveModerationHandler either approves a queued chirp, making it visible
+// to everyone, or removes it.
+// resolveModeratedChirp applies a resolution to the chirp a decision held
+// back, telling the author's webhooks about it as if it had just been posted
+// or deleted. A chirp its author has deleted since is left alone.
+func resolveModeratedChirp(ctx context.Context, tx store.Tx, chirpID uuid.UUID, resolution string) error {
+	chirp, err := tx.GetOneChirp(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if resolution == "remove" {
+		err = tx.DeleteChirp(ctx, chirp.ID)
+		if err != nil {
+			return err
+		}
+		return emitWebhook(ctx, tx, chirp.UserID.UUID, webhooks.EventChirpDeleted, newChirpEventData(chirp))
+	}
+
+	if chirp.Visibility == chirpVisible {
+		// Subscribers heard about it when it was posted.
+		return nil
+	}
+	err = tx.SetChirpVisibility(ctx, database.SetChirpVisibilityParams{
+		Visibility: chirpVisible,
+		ID:         chirp.ID,
+	})
+	if err != nil {
+		return err
+	}
+	return emitWebhook(ctx, tx, chirp.UserID.UUID, webhooks.EventChirpCreated, newChirpEventData(chirp))
+}
+
+func (cfg *apiConfig) resolveModerationHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	type parameters struct {
+		Resolution string `json:"resolution" validate:"required,oneof=approve remove"`
+	}
+
+	admin, ok := cfg.requireAdmin(rWriter, rq)
+	if !ok {
+		return
+	}
+
+	decisionID, err := uuid.Parse(rq.PathValue("decisionID"))
+	if err != nil {
+		respondWithError(rWriter, rq, apierror.InvalidParameter("decisionID", "must be a UUID"))
+		return
+	}
+
+	rqParams := parameters{}
+	err = decode.JSON(rWriter, rq, &rqParams)
+	if err != nil {
+		respondWithError(rWriter, rq, err)
+		return
+	}
+
+	decision, err := cfg.dbQueries.GetModerationDecision(rq.Context(), decisionID)
+	if err != nil {
+		respondWithError(rWriter, rq, apierror.NoRows(err, apierror.NotFound("moderation decision not found")))
+		return
+	}
+	if decision.Status != "pending" {
+		respondWithError(rWriter, rq, apierror.Conflict(apierror.CodeConflict, "moderation decision already resolved"))
+		return
+	}
+
+	if decision.ChirpID.Valid {
+		err = cfg.transactor.InTx(rq.Context(), func(tx store.Tx) error {
+			return resolveModeratedChirp(rq.Context(), tx, decision.ChirpID.UUID, rqParams.Resolution)
+		})
+		if err != nil {
+			respondWithError(rWriter, rq, fmt.Errorf("updating chirp: %w", err))
+			return
+		}
+	}
+
+	resolved, err := cfg.dbQueries.ResolveModerationDecision(rq.Context(), database.ResolveModerationDecisionParams{
+		Resolution: sql.NullString{String: rqParams.Resolution, Valid: true},
+		ResolvedBy: uuid.NullUUID{UUID: admin.ID, Valid: true},
+		ID:         decision.ID,
+	})
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("resolving moderation decision: %w", err))
+		return
+	}
+
+	cfg.audit(rq, audit.Event{
+		ActorID:    admin.ID,
+		ActorType:  audit.ActorAdmin,
+		Action:     "moderation.resolve",
+		TargetType: "moderation_decision",
+		TargetID:   decision.ID.String(),
+		Before:     newModerationDecisionResponse(decision),
+		After:      newModerationDecisionResponse(resolved),
+	})
+
+	respondWithJSON(rWriter, 200, newModerationDecisionResponse(resolved))
+}
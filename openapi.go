@@ -0,0 +1,108 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/jamistoso/chirpy/internal/apierror"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The spec is written as YAML to keep it readable, and served as JSON, which
+// is what tools expect to fetch.
+//
+//go:embed api/openapi.yaml
+var openAPIYAML []byte
+
+//go:embed api/docs.html
+var docsPage []byte
+
+// Swagger UI is vendored by api/vendor-swagger-ui.sh rather than loaded from
+// a CDN, so the docs page runs only code we ship.
+//
+//go:embed api/swagger-ui
+var swaggerUI embed.FS
+
+// docsAssets are the files of api/swagger-ui the docs page loads, with their
+// content types. TestDocsAssetsAreVendored checks each is there.
+var docsAssets = map[string]string{
+	"swagger-ui.css":       "text/css; charset=utf-8",
+	"swagger-ui-bundle.js": "text/javascript; charset=utf-8",
+	"init.js":              "text/javascript; charset=utf-8",
+}
+
+// docsPolicy keeps the docs page to its own assets. Swagger UI sets styles
+// inline and draws some icons from data: URLs.
+const docsPolicy = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; frame-ancestors 'none'"
+
+var openAPIJSON = sync.OnceValues(func() ([]byte, error) {
+	var doc any
+	err := yaml.Unmarshal(openAPIYAML, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("parsing api/openapi.yaml: %w", err)
+	}
+	return json.Marshal(jsonValue(doc))
+})
+
+// jsonValue makes a decoded YAML value encodable as JSON: mappings with keys
+// YAML didn't read as strings, like 200, become map[string]any.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = jsonValue(value)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return m
+	case []any:
+		for i, value := range v {
+			v[i] = jsonValue(value)
+		}
+		return v
+	}
+	return v
+}
+
+func openAPIHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	dat, err := openAPIJSON()
+	if err != nil {
+		respondWithError(rWriter, rq, err)
+		return
+	}
+	rWriter.Header().Set("Content-Type", "application/json")
+	rWriter.Write(dat)
+}
+
+// docsHandler serves Swagger UI for the spec.
+func docsHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	rWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rWriter.Header().Set("Content-Security-Policy", docsPolicy)
+	rWriter.Write(docsPage)
+}
+
+// docsAssetHandler serves the docs page's scripts and styles.
+func docsAssetHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	name := rq.PathValue("name")
+	contentType, ok := docsAssets[name]
+	if !ok {
+		respondWithError(rWriter, rq, apierror.NotFound("no such asset"))
+		return
+	}
+	dat, err := swaggerUI.ReadFile("api/swagger-ui/" + name)
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("reading docs asset %s: %w", name, err))
+		return
+	}
+	rWriter.Header().Set("Content-Type", contentType)
+	rWriter.Header().Set("Cache-Control", "public, max-age=86400")
+	rWriter.Write(dat)
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/apierror"
+	"github.com/jamistoso/chirpy/internal/health"
+	"github.com/jamistoso/chirpy/internal/metrics"
+	"github.com/jamistoso/chirpy/internal/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+// responseSchemas maps the spec's response schemas to the structs handlers
+// encode them from.
+var responseSchemas = map[string]any{
+	"Problem":               apierror.Problem{},
+	"FieldError":            apierror.FieldError{},
+	"User":                  userResponse{},
+	"Login":                 loginResponse{},
+	"Token":                 tokenResponse{},
+	"Chirp":                 chirpResponse{},
+	"AccountState":          accountStateResponse{},
+	"AuditLog":              auditLogResponse{},
+	"AuditEntry":            auditEntryResponse{},
+	"Entitlements":          entitlementsResponse{},
+	"SubscriptionHistory":   subscriptionHistoryResponse{},
+	"SubscriptionEvent":     subscriptionEventResponse{},
+	"WebhookEndpoint":       webhookEndpointResponse{},
+	"WebhookDelivery":       webhookDeliveryResponse{},
+	"WebhookDeliveryDetail": webhookDeliveryDetailResponse{},
+	"WebhookAttempt":        webhookAttemptResponse{},
+	"ModerationDecision":    moderationDecisionResponse{},
+	"HealthReport":          health.Report{},
+	"HealthResult":          health.Result{},
+}
+
+type specDoc struct {
+	OpenAPI    string                               `yaml:"openapi"`
+	Paths      map[string]map[string]yaml.Node      `yaml:"paths"`
+	Components struct{ Schemas map[string]*schema } `yaml:"components"`
+}
+
+type schema struct {
+	Ref        string             `yaml:"$ref"`
+	Type       any                `yaml:"type"`
+	Format     string             `yaml:"format"`
+	Enum       []string           `yaml:"enum"`
+	Items      *schema            `yaml:"items"`
+	Properties map[string]*schema `yaml:"properties"`
+	// AdditionalProperties is a schema, or false for closed request
+	// objects.
+	AdditionalProperties yaml.Node `yaml:"additionalProperties"`
+	Required             []string  `yaml:"required"`
+}
+
+// types returns the JSON types s allows, sorted.
+func (s *schema) types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		var types []string
+		for _, name := range t {
+			types = append(types, name.(string))
+		}
+		sort.Strings(types)
+		return types
+	}
+	return nil
+}
+
+func loadSpec(t *testing.T) specDoc {
+	t.Helper()
+	var doc specDoc
+	err := yaml.Unmarshal(openAPIYAML, &doc)
+	if err != nil {
+		t.Fatalf(`parsing api/openapi.yaml: %v`, err)
+	}
+	return doc
+}
+
+func TestSpecCoversRoutes(t *testing.T) {
+	doc := loadSpec(t)
+	cfg := &apiConfig{metrics: metrics.New(nil), limiter: &ratelimit.Limiter{}}
+
+	registered := map[string]bool{}
+	for _, route := range cfg.routes(ratelimit.Policy{}, ratelimit.Policy{}) {
+		method, path, found := strings.Cut(route.pattern, " ")
+		if !found {
+			// Patterns without a method match them all; GET is what's
+			// documented.
+			method, path = "GET", route.pattern
+		}
+		operation := strings.ToLower(method) + " " + path
+		registered[operation] = true
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf(`%q is registered but api/openapi.yaml doesn't document %s`, route.pattern, operation)
+		}
+	}
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			if !registered[method+" "+path] {
+				t.Errorf(`api/openapi.yaml documents %s %s, which isn't registered`, method, path)
+			}
+		}
+	}
+}
+
+func TestSpecReferencesResolve(t *testing.T) {
+	var doc map[string]any
+	err := yaml.Unmarshal(openAPIYAML, &doc)
+	if err != nil {
+		t.Fatalf(`parsing api/openapi.yaml: %v`, err)
+	}
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				target := any(doc)
+				for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					m, _ := target.(map[string]any)
+					target = m[part]
+				}
+				if target == nil {
+					t.Errorf(`$ref %q doesn't resolve`, ref)
+				}
+			}
+			for _, value := range v {
+				walk(value)
+			}
+		case []any:
+			for _, value := range v {
+				walk(value)
+			}
+		}
+	}
+	walk(doc)
+}
+
+func TestSchemasMatchResponses(t *testing.T) {
+	doc := loadSpec(t)
+	schemaNames := map[reflect.Type]string{}
+	for name, v := range responseSchemas {
+		schemaNames[reflect.TypeOf(v)] = name
+	}
+
+	for name, v := range responseSchemas {
+		t.Run(name, func(t *testing.T) {
+			s, ok := doc.Components.Schemas[name]
+			if !ok {
+				t.Fatalf(`api/openapi.yaml has no schema %s`, name)
+			}
+			checkStruct(t, doc, schemaNames, name, s, reflect.TypeOf(v))
+		})
+	}
+}
+
+func checkStruct(t *testing.T, doc specDoc, schemaNames map[reflect.Type]string, name string, s *schema, typ reflect.Type) {
+	t.Helper()
+	fields := jsonFields(typ)
+
+	var goNames, specNames, goRequired []string
+	for _, field := range fields {
+		goNames = append(goNames, field.name)
+		if !field.omitempty {
+			goRequired = append(goRequired, field.name)
+		}
+	}
+	for property := range s.Properties {
+		specNames = append(specNames, property)
+	}
+	sort.Strings(goNames)
+	sort.Strings(specNames)
+	sort.Strings(goRequired)
+	if !slices.Equal(goNames, specNames) {
+		t.Fatalf(`%s has properties %v, wanted %v from %s`, name, specNames, goNames, typ)
+	}
+	required := slices.Clone(s.Required)
+	sort.Strings(required)
+	if !slices.Equal(goRequired, required) {
+		t.Fatalf(`%s requires %v, wanted the fields %s always sends: %v`, name, required, typ, goRequired)
+	}
+
+	for _, field := range fields {
+		checkType(t, doc, schemaNames, name+"."+field.name, s.Properties[field.name], field.typ)
+	}
+}
+
+// checkType fails unless s describes how encoding/json encodes typ.
+func checkType(t *testing.T, doc specDoc, schemaNames map[reflect.Type]string, path string, s *schema, typ reflect.Type) {
+	t.Helper()
+	if s.Ref != "" {
+		refName := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		if want, ok := schemaNames[typ]; ok {
+			if refName != want {
+				t.Fatalf(`%s refers to %s, wanted %s`, path, refName, want)
+			}
+			return
+		}
+		s = doc.Components.Schemas[refName]
+		if s == nil {
+			t.Fatalf(`%s refers to missing schema %s`, path, refName)
+		}
+	}
+
+	want := []string{}
+	nullable := false
+	if typ.Kind() == reflect.Pointer {
+		nullable = true
+		typ = typ.Elem()
+	}
+	switch {
+	case typ == reflect.TypeOf(json.RawMessage{}):
+		// Any JSON value.
+		want = nil
+	case typ == reflect.TypeOf(uuid.UUID{}):
+		want = []string{"string"}
+		if s.Format != "uuid" {
+			t.Fatalf(`%s has format %q, wanted uuid`, path, s.Format)
+		}
+	case typ == reflect.TypeOf(time.Time{}):
+		want = []string{"string"}
+		if s.Format != "date-time" {
+			t.Fatalf(`%s has format %q, wanted date-time`, path, s.Format)
+		}
+	default:
+		switch typ.Kind() {
+		case reflect.String:
+			want = []string{"string"}
+		case reflect.Bool:
+			want = []string{"boolean"}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			want = []string{"integer"}
+		case reflect.Float32, reflect.Float64:
+			want = []string{"number"}
+		case reflect.Slice:
+			// encoding/json writes a nil slice as null, but handlers always
+			// send empty ones.
+			want = []string{"array"}
+			if s.Items == nil {
+				t.Fatalf(`%s has no items`, path)
+			}
+			checkType(t, doc, schemaNames, path+"[]", s.Items, typ.Elem())
+		case reflect.Map:
+			want = []string{"object"}
+			var values schema
+			err := s.AdditionalProperties.Decode(&values)
+			if s.AdditionalProperties.Kind != yaml.MappingNode || err != nil {
+				t.Fatalf(`%s has no additionalProperties schema`, path)
+			}
+			checkType(t, doc, schemaNames, path+"{}", &values, typ.Elem())
+		case reflect.Struct:
+			name, ok := schemaNames[typ]
+			if !ok {
+				t.Fatalf(`%s is a %s, which has no schema in responseSchemas`, path, typ)
+			}
+			t.Fatalf(`%s is inline, wanted a $ref to %s`, path, name)
+		default:
+			t.Fatalf(`%s is a %s, which checkType doesn't know`, path, typ)
+		}
+	}
+	if want != nil && nullable {
+		want = append(want, "null")
+	}
+	sort.Strings(want)
+	if got := s.types(); !slices.Equal(got, want) {
+		t.Fatalf(`%s has type %v, wanted %v for a %s`, path, got, want, typ)
+	}
+}
+
+type jsonField struct {
+	name      string
+	omitempty bool
+	typ       reflect.Type
+}
+
+// jsonFields lists the fields encoding/json writes for typ, with embedded
+// structs' fields promoted.
+func jsonFields(typ reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			fields = append(fields, jsonFields(field.Type)...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, jsonField{
+			name:      name,
+			omitempty: slices.Contains(strings.Split(options, ","), "omitempty"),
+			typ:       field.Type,
+		})
+	}
+	return fields
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	openAPIHandler(w, httptest.NewRequest("GET", "/api/openapi.json", nil))
+
+	var doc struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &doc)
+	if err != nil {
+		t.Fatalf(`decoding /api/openapi.json: %v`, err)
+	}
+	if w.Code != 200 || doc.OpenAPI != "3.1.0" {
+		t.Fatalf(`GET /api/openapi.json = %d, openapi %q, wanted 200 and 3.1.0`, w.Code, doc.OpenAPI)
+	}
+	if _, ok := doc.Paths["/api/chirps"]["get"].(map[string]any)["responses"].(map[string]any)["200"]; !ok {
+		t.Fatalf(`GET /api/openapi.json lost the numeric response codes: %v`, doc.Paths["/api/chirps"])
+	}
+}
+
+func TestDocsAssetsAreVendored(t *testing.T) {
+	for name := range docsAssets {
+		dat, err := swaggerUI.ReadFile("api/swagger-ui/" + name)
+		if err != nil || len(dat) == 0 {
+			t.Errorf(`api/swagger-ui/%s = %d bytes, %v; run api/vendor-swagger-ui.sh and commit its output`, name, len(dat), err)
+		}
+	}
+}
+
+func TestDocsHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/docs", docsHandler)
+	mux.HandleFunc("GET /api/docs/assets/{name}", docsAssetHandler)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/docs", nil))
+	if w.Code != 200 || w.Header().Get("Content-Security-Policy") != docsPolicy {
+		t.Fatalf(`GET /api/docs = %d with policy %q, wanted 200 and %q`, w.Code, w.Header().Get("Content-Security-Policy"), docsPolicy)
+	}
+	if strings.Contains(w.Body.String(), "://") {
+		t.Fatalf(`GET /api/docs loads something from elsewhere: %s`, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/docs/assets/init.js", nil))
+	if w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/javascript") {
+		t.Fatalf(`GET /api/docs/assets/init.js = %d %q, wanted 200 text/javascript`, w.Code, w.Header().Get("Content-Type"))
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/docs/assets/README.md", nil))
+	if w.Code != 404 {
+		t.Fatalf(`GET /api/docs/assets/README.md = %d, wanted 404`, w.Code)
+	}
+}
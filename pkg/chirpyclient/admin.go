@@ -0,0 +1,176 @@
+package chirpyclient
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The methods in this file need an admin account.
+
+type ModerationDecision struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UserID     uuid.UUID  `json:"user_id"`
+	ChirpID    *uuid.UUID `json:"chirp_id"`
+	Body       string     `json:"body"`
+	Action     string     `json:"action"`
+	Score      float64    `json:"score"`
+	Reasons    []string   `json:"reasons"`
+	Status     string     `json:"status"`
+	Resolution string     `json:"resolution,omitempty"`
+}
+
+// ModerationQueue returns the chirps held for review.
+func (c *Client) ModerationQueue(ctx context.Context) ([]ModerationDecision, error) {
+	var decisions []ModerationDecision
+	_, err := c.do(ctx, request{method: "GET", path: "/admin/moderation", auth: accessToken}, &decisions)
+	return decisions, err
+}
+
+const (
+	ResolutionApprove = "approve"
+	ResolutionRemove  = "remove"
+)
+
+// ResolveModeration approves or removes a held chirp.
+func (c *Client) ResolveModeration(ctx context.Context, decisionID uuid.UUID, resolution string) (ModerationDecision, error) {
+	body := struct {
+		Resolution string `json:"resolution"`
+	}{resolution}
+	var decision ModerationDecision
+	_, err := c.do(ctx, request{
+		method: "POST",
+		path:   "/admin/moderation/" + decisionID.String(),
+		body:   body,
+		auth:   accessToken,
+	}, &decision)
+	return decision, err
+}
+
+const (
+	StateActive       = "active"
+	StateSuspended    = "suspended"
+	StateShadowBanned = "shadow_banned"
+	StateDeactivated  = "deactivated"
+)
+
+type AccountState struct {
+	ID             uuid.UUID  `json:"id"`
+	Email          string     `json:"email"`
+	State          string     `json:"state"`
+	StateReason    string     `json:"state_reason"`
+	StateExpiresAt *time.Time `json:"state_expires_at"`
+}
+
+// AccountStateChange is a new state for an account.
+type AccountStateChange struct {
+	State  string `json:"state"`
+	Reason string `json:"reason,omitempty"`
+	// ExpiresAt, if set, is when the account goes back to active.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// SetAccountState suspends, shadow-bans, deactivates or reinstates a user.
+func (c *Client) SetAccountState(ctx context.Context, userID uuid.UUID, change AccountStateChange) (AccountState, error) {
+	var state AccountState
+	_, err := c.do(ctx, request{
+		method: "PUT",
+		path:   "/admin/users/" + userID.String() + "/state",
+		body:   change,
+		auth:   accessToken,
+	}, &state)
+	return state, err
+}
+
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ActorID    *uuid.UUID      `json:"actor_id"`
+	ActorType  string          `json:"actor_type"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	RequestID  string          `json:"request_id"`
+	IP         string          `json:"ip"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+}
+
+// AuditFilter picks and pages audit entries. Zero fields don't filter.
+type AuditFilter struct {
+	ActorID  uuid.UUID
+	Action   string
+	TargetID string
+	Since    time.Time
+	Until    time.Time
+	// Limit is the most entries in a page. Zero means the server's default.
+	Limit int
+	// Cursor is where to continue from: a previous page's NextCursor.
+	Cursor string
+}
+
+// AuditPage is a page of audit entries, newest first.
+type AuditPage struct {
+	Entries []AuditEntry `json:"entries"`
+	// NextCursor continues after this page; it is empty on the last page.
+	NextCursor string `json:"next_cursor"`
+}
+
+// ListAuditEntries returns one page of the audit log.
+func (c *Client) ListAuditEntries(ctx context.Context, filter AuditFilter) (AuditPage, error) {
+	query := url.Values{}
+	if filter.ActorID != uuid.Nil {
+		query.Set("actor_id", filter.ActorID.String())
+	}
+	if filter.Action != "" {
+		query.Set("action", filter.Action)
+	}
+	if filter.TargetID != "" {
+		query.Set("target_id", filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		query.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	if !filter.Until.IsZero() {
+		query.Set("until", filter.Until.Format(time.RFC3339))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Cursor != "" {
+		query.Set("cursor", filter.Cursor)
+	}
+
+	var page AuditPage
+	_, err := c.do(ctx, request{method: "GET", path: "/admin/audit", query: query, auth: accessToken}, &page)
+	return page, err
+}
+
+// AuditEntries iterates over the audit entries matching filter, newest
+// first, fetching pages as it goes. It stops at the first error.
+func (c *Client) AuditEntries(ctx context.Context, filter AuditFilter) iter.Seq2[AuditEntry, error] {
+	return func(yield func(AuditEntry, error) bool) {
+		for {
+			page, err := c.ListAuditEntries(ctx, filter)
+			if err != nil {
+				yield(AuditEntry{}, err)
+				return
+			}
+			for _, entry := range page.Entries {
+				if !yield(entry, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			filter.Cursor = page.NextCursor
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package chirpyclient
+
+import (
+	"context"
+	"time"
+)
+
+// Entitlements are what the user's plan allows.
+type Entitlements struct {
+	Plan                string  `json:"plan"`
+	MaxChirpLength      int     `json:"max_chirp_length"`
+	CanEditChirps       bool    `json:"can_edit_chirps"`
+	RateLimitMultiplier float64 `json:"rate_limit_multiplier"`
+}
+
+// Entitlements returns the logged-in user's plan and its limits.
+func (c *Client) Entitlements(ctx context.Context) (Entitlements, error) {
+	var entitlements Entitlements
+	_, err := c.do(ctx, request{method: "GET", path: "/api/entitlements", auth: accessToken}, &entitlements)
+	return entitlements, err
+}
+
+type Subscription struct {
+	Plan             string              `json:"plan"`
+	Status           string              `json:"status"`
+	CurrentPeriodEnd time.Time           `json:"current_period_end"`
+	CancelAt         *time.Time          `json:"cancel_at"`
+	IsChirpyRed      bool                `json:"is_chirpy_red"`
+	History          []SubscriptionEvent `json:"history"`
+}
+
+type SubscriptionEvent struct {
+	Event            string     `json:"event"`
+	CreatedAt        time.Time  `json:"created_at"`
+	Status           string     `json:"status"`
+	CurrentPeriodEnd time.Time  `json:"current_period_end"`
+	CancelAt         *time.Time `json:"cancel_at"`
+}
+
+// Subscription returns the logged-in user's Chirpy Red subscription and its
+// history. Users who never subscribed get a CodeNotFound error.
+func (c *Client) Subscription(ctx context.Context) (Subscription, error) {
+	var sub Subscription
+	_, err := c.do(ctx, request{method: "GET", path: "/api/subscription", auth: accessToken}, &sub)
+	return sub, err
+}
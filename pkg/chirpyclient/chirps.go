@@ -0,0 +1,157 @@
+package chirpyclient
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Chirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// ListChirpsOptions filters and pages chirps. The zero value lists every
+// chirp, oldest first.
+type ListChirpsOptions struct {
+	// AuthorID, if set, only lists this user's chirps.
+	AuthorID uuid.UUID
+	// Sort is SortAsc or SortDesc, by creation time.
+	Sort string
+	// Limit is the most chirps in a page. Zero means no limit.
+	Limit int
+	// Cursor is where to continue from: a previous page's NextCursor.
+	Cursor string
+}
+
+// ChirpPage is a page of chirps.
+type ChirpPage struct {
+	Chirps []Chirp
+	// NextCursor continues after this page; it is empty on the last page.
+	NextCursor string
+}
+
+// ListChirps returns one page of chirps. If the client is logged in, the
+// user's own held and hidden chirps are included.
+func (c *Client) ListChirps(ctx context.Context, opts ListChirpsOptions) (ChirpPage, error) {
+	query := url.Values{}
+	if opts.AuthorID != uuid.Nil {
+		query.Set("author_id", opts.AuthorID.String())
+	}
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Cursor != "" {
+		query.Set("cursor", opts.Cursor)
+	}
+
+	var page ChirpPage
+	header, err := c.do(ctx, request{
+		method: "GET",
+		path:   "/api/chirps",
+		query:  query,
+		auth:   c.optionalAuth(),
+	}, &page.Chirps)
+	if err != nil {
+		return ChirpPage{}, err
+	}
+	page.NextCursor = nextCursor(header)
+	return page, nil
+}
+
+// Chirps iterates over the chirps ListChirps would list, fetching pages of
+// opts.Limit as it goes. It stops at the first error.
+func (c *Client) Chirps(ctx context.Context, opts ListChirpsOptions) iter.Seq2[Chirp, error] {
+	return func(yield func(Chirp, error) bool) {
+		for {
+			page, err := c.ListChirps(ctx, opts)
+			if err != nil {
+				yield(Chirp{}, err)
+				return
+			}
+			for _, chirp := range page.Chirps {
+				if !yield(chirp, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			opts.Cursor = page.NextCursor
+		}
+	}
+}
+
+// GetChirp returns one chirp.
+func (c *Client) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	var chirp Chirp
+	_, err := c.do(ctx, request{
+		method: "GET",
+		path:   "/api/chirps/" + id.String(),
+		auth:   c.optionalAuth(),
+	}, &chirp)
+	return chirp, err
+}
+
+type chirpBody struct {
+	Body string `json:"body"`
+}
+
+// CreateChirp posts a chirp as the logged-in user.
+func (c *Client) CreateChirp(ctx context.Context, body string) (Chirp, error) {
+	var chirp Chirp
+	_, err := c.do(ctx, request{
+		method: "POST",
+		path:   "/api/chirps",
+		body:   chirpBody{Body: body},
+		auth:   accessToken,
+	}, &chirp)
+	return chirp, err
+}
+
+// EditChirp replaces the body of one of the logged-in user's chirps. Only
+// some plans allow it.
+func (c *Client) EditChirp(ctx context.Context, id uuid.UUID, body string) (Chirp, error) {
+	var chirp Chirp
+	_, err := c.do(ctx, request{
+		method: "PUT",
+		path:   "/api/chirps/" + id.String(),
+		body:   chirpBody{Body: body},
+		auth:   accessToken,
+	}, &chirp)
+	return chirp, err
+}
+
+// DeleteChirp deletes one of the logged-in user's chirps.
+func (c *Client) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := c.do(ctx, request{
+		method: "DELETE",
+		path:   "/api/chirps/" + id.String(),
+		auth:   accessToken,
+	}, nil)
+	return err
+}
+
+// optionalAuth authenticates requests that work either way when the client
+// is logged in.
+func (c *Client) optionalAuth() credential {
+	if c.Tokens() != (Tokens{}) {
+		return accessToken
+	}
+	return noAuth
+}
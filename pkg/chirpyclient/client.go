@@ -0,0 +1,240 @@
+// Package chirpyclient is a client for chirpy's HTTP API.
+//
+//	client := chirpyclient.New("https://chirpy.example.com")
+//	_, err := client.Login(ctx, "walt@example.com", "hunter2")
+//	...
+//	chirp, err := client.CreateChirp(ctx, "Say my name.")
+//
+// After logging in, the client keeps the access and refresh tokens. When the
+// server says the access token has expired, the client gets a new one with
+// the refresh token and retries the request once. Requests that fail return
+// an *Error holding the server's problem details.
+package chirpyclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Tokens are the credentials of a logged-in client.
+type Tokens struct {
+	Access  string `json:"access_token"`
+	Refresh string `json:"refresh_token"`
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	onTokens   func(Tokens)
+
+	mu     sync.Mutex
+	tokens Tokens
+	// refreshing is held while the access token is refreshed.
+	refreshing sync.Mutex
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sends requests with httpClient instead of
+// http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTokens starts the client logged in, e.g. with tokens saved from an
+// earlier session. The access token may be empty: the refresh token gets a
+// new one on the first request that needs it.
+func WithTokens(tokens Tokens) Option {
+	return func(c *Client) {
+		c.tokens = tokens
+	}
+}
+
+// OnTokens calls fn whenever the client's tokens change: on login, refresh
+// and revoke. fn must not call the client.
+func OnTokens(fn func(Tokens)) Option {
+	return func(c *Client) {
+		c.onTokens = fn
+	}
+}
+
+// New returns a client for the chirpy server at baseURL, like
+// "https://chirpy.example.com".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Tokens returns the client's current tokens.
+func (c *Client) Tokens() Tokens {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens
+}
+
+func (c *Client) setTokens(tokens Tokens) {
+	c.mu.Lock()
+	c.tokens = tokens
+	c.mu.Unlock()
+	if c.onTokens != nil {
+		c.onTokens(tokens)
+	}
+}
+
+// credential is what a request authenticates with.
+type credential int
+
+const (
+	noAuth credential = iota
+	accessToken
+	refreshToken
+)
+
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	auth   credential
+}
+
+// do sends r and decodes a successful response's body into out, if out isn't
+// nil. Requests with an access token are retried once after a refresh if the
+// token was rejected.
+func (c *Client) do(ctx context.Context, r request, out any) (http.Header, error) {
+	var body []byte
+	if r.body != nil {
+		var err error
+		body, err = json.Marshal(r.body)
+		if err != nil {
+			return nil, fmt.Errorf("encoding request: %w", err)
+		}
+	}
+
+	token := ""
+	switch r.auth {
+	case accessToken:
+		tokens := c.Tokens()
+		token = tokens.Access
+		if token == "" && tokens.Refresh != "" {
+			var err error
+			token, err = c.refreshAccess(ctx, "")
+			if err != nil {
+				return nil, err
+			}
+		}
+	case refreshToken:
+		token = c.Tokens().Refresh
+	}
+
+	resp, err := c.send(ctx, r, body, token)
+	if err != nil {
+		return nil, err
+	}
+	if r.auth == accessToken && resp.StatusCode == http.StatusUnauthorized && c.Tokens().Refresh != "" {
+		apiErr := decodeError(resp)
+		if apiErr.Code != CodeInvalidToken {
+			return nil, apiErr
+		}
+		token, err = c.refreshAccess(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.send(ctx, r, body, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, decodeError(resp)
+	}
+	if out != nil {
+		err = json.NewDecoder(resp.Body).Decode(out)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s %s response: %w", r.method, r.path, err)
+		}
+	}
+	return resp.Header, nil
+}
+
+func (c *Client) send(ctx context.Context, r request, body []byte, token string) (*http.Response, error) {
+	target := c.baseURL + r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	rq, err := http.NewRequestWithContext(ctx, r.method, target, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		rq.Header.Set("Content-Type", "application/json")
+	}
+	rq.Header.Set("Accept", "application/json")
+	if token != "" {
+		rq.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.httpClient.Do(rq)
+}
+
+// refreshAccess gets a new access token to replace rejected. Concurrent
+// requests whose token was rejected share one refresh: if the token has
+// already been replaced, the replacement is returned.
+func (c *Client) refreshAccess(ctx context.Context, rejected string) (string, error) {
+	c.refreshing.Lock()
+	defer c.refreshing.Unlock()
+	if current := c.Tokens().Access; current != rejected {
+		return current, nil
+	}
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	_, err := c.do(ctx, request{method: "POST", path: "/api/refresh", auth: refreshToken}, &resp)
+	if err != nil {
+		return "", fmt.Errorf("refreshing access token: %w", err)
+	}
+	tokens := c.Tokens()
+	tokens.Access = resp.Token
+	c.setTokens(tokens)
+	return resp.Token, nil
+}
+
+// nextCursor returns the cursor query parameter of the rel="next" link in
+// header, or "" if there is no next page.
+func nextCursor(header http.Header) string {
+	for _, link := range header.Values("Link") {
+		for _, part := range strings.Split(link, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+			if !ok || !strings.Contains(params, `rel="next"`) {
+				continue
+			}
+			next, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+			if err != nil {
+				return ""
+			}
+			return next.Query().Get("cursor")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,201 @@
+package chirpyclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func writeProblem(w http.ResponseWriter, status int, code, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"type":       "urn:chirpy:problem:" + code,
+		"title":      http.StatusText(status),
+		"status":     status,
+		"code":       code,
+		"detail":     detail,
+		"request_id": "req-1",
+	})
+}
+
+// tokenServer accepts the access token "fresh", and hands it out for the
+// refresh token "refresh".
+func tokenServer(t *testing.T, refreshes *atomic.Int32) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/refresh", func(w http.ResponseWriter, r *http.Request) {
+		refreshes.Add(1)
+		if r.Header.Get("Authorization") != "Bearer refresh" {
+			writeProblem(w, 401, CodeInvalidToken, "invalid refresh token")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": "fresh"})
+	})
+	mux.HandleFunc("POST /api/chirps", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			writeProblem(w, 401, CodeInvalidToken, "invalid or expired access token")
+			return
+		}
+		var body chirpBody
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(201)
+		json.NewEncoder(w).Encode(Chirp{ID: uuid.New(), Body: body.Body})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRefreshesRejectedToken(t *testing.T) {
+	var refreshes atomic.Int32
+	server := tokenServer(t, &refreshes)
+	var saved []Tokens
+	client := New(server.URL,
+		WithTokens(Tokens{Access: "expired", Refresh: "refresh"}),
+		OnTokens(func(tokens Tokens) { saved = append(saved, tokens) }),
+	)
+
+	chirp, err := client.CreateChirp(context.Background(), "hello")
+	if err != nil || chirp.Body != "hello" {
+		t.Fatalf(`CreateChirp() = %+v, %v, wanted the chirp`, chirp, err)
+	}
+	want := []Tokens{{Access: "fresh", Refresh: "refresh"}}
+	if refreshes.Load() != 1 || !reflect.DeepEqual(saved, want) {
+		t.Fatalf(`refreshed %d times and saved %+v, wanted once and %+v`, refreshes.Load(), saved, want)
+	}
+}
+
+func TestRefreshesMissingToken(t *testing.T) {
+	var refreshes atomic.Int32
+	server := tokenServer(t, &refreshes)
+	client := New(server.URL, WithTokens(Tokens{Refresh: "refresh"}))
+
+	_, err := client.CreateChirp(context.Background(), "hello")
+	if err != nil || refreshes.Load() != 1 {
+		t.Fatalf(`CreateChirp() = %v after %d refreshes, wanted success after 1`, err, refreshes.Load())
+	}
+}
+
+func TestConcurrentRequestsShareARefresh(t *testing.T) {
+	var refreshes atomic.Int32
+	server := tokenServer(t, &refreshes)
+	client := New(server.URL, WithTokens(Tokens{Access: "expired", Refresh: "refresh"}))
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.CreateChirp(context.Background(), "hello")
+			if err != nil {
+				t.Errorf(`CreateChirp() = %v`, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if refreshes.Load() != 1 {
+		t.Fatalf(`refreshed %d times, wanted 1`, refreshes.Load())
+	}
+}
+
+func TestRefreshFails(t *testing.T) {
+	var refreshes atomic.Int32
+	server := tokenServer(t, &refreshes)
+	client := New(server.URL, WithTokens(Tokens{Access: "expired", Refresh: "revoked"}))
+
+	_, err := client.CreateChirp(context.Background(), "hello")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != 401 || apiErr.Code != CodeInvalidToken {
+		t.Fatalf(`CreateChirp() = %v, wanted a 401 %s`, err, CodeInvalidToken)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/users":
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(400)
+			fmt.Fprint(w, `{"type":"urn:chirpy:problem:validation_failed","title":"Bad Request","status":400,`+
+				`"code":"validation_failed","detail":"email: must be an email address",`+
+				`"errors":[{"field":"email","message":"must be an email address"}],"request_id":"req-1"}`)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(502)
+			fmt.Fprint(w, "<html>Bad Gateway</html>\n")
+		}
+	}))
+	defer server.Close()
+	client := New(server.URL)
+
+	_, err := client.CreateUser(context.Background(), "walt", "hunter2")
+	want := &Error{
+		Status:    400,
+		Type:      "urn:chirpy:problem:validation_failed",
+		Title:     "Bad Request",
+		Detail:    "email: must be an email address",
+		Code:      CodeValidationFailed,
+		Errors:    []FieldError{{Field: "email", Message: "must be an email address"}},
+		RequestID: "req-1",
+	}
+	if !reflect.DeepEqual(err, want) {
+		t.Fatalf(`CreateUser() = %#v, wanted %#v`, err, want)
+	}
+	if ErrorCode(fmt.Errorf("signing up: %w", err)) != CodeValidationFailed {
+		t.Fatalf(`ErrorCode() = %q, wanted %q`, ErrorCode(err), CodeValidationFailed)
+	}
+
+	_, err = client.GetChirp(context.Background(), uuid.New())
+	want = &Error{Status: 502, Title: "Bad Gateway", Detail: "<html>Bad Gateway</html>"}
+	if !reflect.DeepEqual(err, want) {
+		t.Fatalf(`GetChirp() = %#v, wanted %#v`, err, want)
+	}
+	if err.Error() != "chirpy: 502: <html>Bad Gateway</html>" {
+		t.Fatalf(`Error() = %q`, err.Error())
+	}
+}
+
+func TestChirpsFollowsPages(t *testing.T) {
+	chirps := make([]Chirp, 5)
+	for i := range chirps {
+		chirps[i] = Chirp{ID: uuid.New(), Body: fmt.Sprint(i)}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := 0
+		if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+			for i, chirp := range chirps {
+				if chirp.ID.String() == cursor {
+					start = i + 1
+				}
+			}
+		}
+		end := min(start+2, len(chirps))
+		if end < len(chirps) {
+			w.Header().Set("Link", fmt.Sprintf(`</api/chirps?limit=2&cursor=%s>; rel="next"`, chirps[end-1].ID))
+		}
+		json.NewEncoder(w).Encode(chirps[start:end])
+	}))
+	defer server.Close()
+	client := New(server.URL)
+
+	var got []Chirp
+	for chirp, err := range client.Chirps(context.Background(), ListChirpsOptions{Limit: 2}) {
+		if err != nil {
+			t.Fatalf(`Chirps() yielded %v`, err)
+		}
+		got = append(got, chirp)
+	}
+	if !reflect.DeepEqual(got, chirps) {
+		t.Fatalf(`Chirps() = %+v, wanted %+v`, got, chirps)
+	}
+}
@@ -0,0 +1,102 @@
+package chirpyclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// The codes the server puts in an Error. They never change meaning; new ones
+// may be added. The server's tests check they match its own.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeInvalidJSON          = "invalid_json"
+	CodeBodyTooLarge         = "body_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeInvalidParameter     = "invalid_parameter"
+	CodeValidationFailed     = "validation_failed"
+	CodeContentRejected      = "content_rejected"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidToken         = "invalid_token"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeInvalidSignature     = "invalid_signature"
+	CodeForbidden            = "forbidden"
+	CodeAccountSuspended     = "account_suspended"
+	CodeAccountDeactivated   = "account_deactivated"
+	CodePlanRequired         = "plan_required"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeLimitReached         = "limit_reached"
+	CodeUnprocessable        = "unprocessable"
+	CodeRateLimited          = "rate_limited"
+	CodeInternal             = "internal_error"
+)
+
+// FieldError says what is wrong with one field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is a request the server refused or failed, with the problem details
+// it sent. Responses that aren't problem details, say from a proxy, have an
+// empty Code and the start of the body as Detail.
+type Error struct {
+	Status    int          `json:"status"`
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance"`
+	Code      string       `json:"code"`
+	Errors    []FieldError `json:"errors"`
+	RequestID string       `json:"request_id"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("chirpy: %d", e.Status)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// ErrorCode returns the code of the *Error in err's chain, or "" if there is
+// none:
+//
+//	if chirpyclient.ErrorCode(err) == chirpyclient.CodeNotFound {
+func ErrorCode(err error) string {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+// decodeError reads and closes the body of a failed response.
+func decodeError(resp *http.Response) *Error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	apiErr := &Error{}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" && json.Unmarshal(body, apiErr) == nil {
+		apiErr.Status = resp.StatusCode
+		return apiErr
+	}
+	apiErr = &Error{
+		Status: resp.StatusCode,
+		Title:  http.StatusText(resp.StatusCode),
+		Detail: strings.TrimSpace(string(body)),
+	}
+	if len(apiErr.Detail) > 200 {
+		apiErr.Detail = apiErr.Detail[:200] + "..."
+	}
+	return apiErr
+}
@@ -0,0 +1,82 @@
+package chirpyclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type User struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Email       string    `json:"email"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// CreateUser signs up a new user. It doesn't log in.
+func (c *Client) CreateUser(ctx context.Context, email, password string) (User, error) {
+	var user User
+	_, err := c.do(ctx, request{
+		method: "POST",
+		path:   "/api/users",
+		body:   credentials{Email: email, Password: password},
+	}, &user)
+	return user, err
+}
+
+// UpdateUser changes the logged-in user's email and password.
+func (c *Client) UpdateUser(ctx context.Context, email, password string) (User, error) {
+	var user User
+	_, err := c.do(ctx, request{
+		method: "PUT",
+		path:   "/api/users",
+		body:   credentials{Email: email, Password: password},
+		auth:   accessToken,
+	}, &user)
+	return user, err
+}
+
+// Login logs the client in as the user with email and password, keeping the
+// tokens the server returns.
+func (c *Client) Login(ctx context.Context, email, password string) (User, error) {
+	var resp struct {
+		User
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	_, err := c.do(ctx, request{
+		method: "POST",
+		path:   "/api/login",
+		body:   credentials{Email: email, Password: password},
+	}, &resp)
+	if err != nil {
+		return User{}, err
+	}
+	c.setTokens(Tokens{Access: resp.Token, Refresh: resp.RefreshToken})
+	return resp.User, nil
+}
+
+// Refresh gets a new access token with the refresh token. Requests do this
+// by themselves when needed; Refresh is for doing it ahead of time.
+func (c *Client) Refresh(ctx context.Context) error {
+	_, err := c.refreshAccess(ctx, c.Tokens().Access)
+	return err
+}
+
+// Revoke revokes the client's refresh token, ending its session, and forgets
+// its tokens.
+func (c *Client) Revoke(ctx context.Context) error {
+	_, err := c.do(ctx, request{method: "POST", path: "/api/revoke", auth: refreshToken}, nil)
+	if err != nil {
+		return err
+	}
+	c.setTokens(Tokens{})
+	return nil
+}
@@ -0,0 +1,99 @@
+package chirpyclient
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookEndpoint struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	// Secret signs the endpoint's deliveries. It is only returned by
+	// CreateWebhook.
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID       `json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	EventType     string          `json:"event_type"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at"`
+	Payload       json.RawMessage `json:"payload"`
+	// AttemptLog is only returned by GetWebhookDelivery.
+	AttemptLog []WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+type WebhookAttempt struct {
+	CreatedAt      time.Time `json:"created_at"`
+	ResponseStatus *int      `json:"response_status"`
+	Error          string    `json:"error,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+}
+
+// CreateWebhook registers an endpoint for the given event types, or for all
+// of them if none are given.
+func (c *Client) CreateWebhook(ctx context.Context, url string, eventTypes ...string) (WebhookEndpoint, error) {
+	body := struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types,omitempty"`
+	}{url, eventTypes}
+	var endpoint WebhookEndpoint
+	_, err := c.do(ctx, request{method: "POST", path: "/api/webhooks", body: body, auth: accessToken}, &endpoint)
+	return endpoint, err
+}
+
+func (c *Client) ListWebhooks(ctx context.Context) ([]WebhookEndpoint, error) {
+	var endpoints []WebhookEndpoint
+	_, err := c.do(ctx, request{method: "GET", path: "/api/webhooks", auth: accessToken}, &endpoints)
+	return endpoints, err
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, endpointID uuid.UUID) error {
+	_, err := c.do(ctx, request{
+		method: "DELETE",
+		path:   "/api/webhooks/" + endpointID.String(),
+		auth:   accessToken,
+	}, nil)
+	return err
+}
+
+// ListWebhookDeliveries returns an endpoint's most recent deliveries, newest
+// first.
+func (c *Client) ListWebhookDeliveries(ctx context.Context, endpointID uuid.UUID) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	_, err := c.do(ctx, request{
+		method: "GET",
+		path:   "/api/webhooks/" + endpointID.String() + "/deliveries",
+		auth:   accessToken,
+	}, &deliveries)
+	return deliveries, err
+}
+
+// GetWebhookDelivery returns a delivery with the log of its attempts.
+func (c *Client) GetWebhookDelivery(ctx context.Context, endpointID, deliveryID uuid.UUID) (WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	_, err := c.do(ctx, request{
+		method: "GET",
+		path:   "/api/webhooks/" + endpointID.String() + "/deliveries/" + deliveryID.String(),
+		auth:   accessToken,
+	}, &delivery)
+	return delivery, err
+}
+
+// RedeliverWebhook queues a delivery to be sent again.
+func (c *Client) RedeliverWebhook(ctx context.Context, endpointID, deliveryID uuid.UUID) (WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	_, err := c.do(ctx, request{
+		method: "POST",
+		path:   "/api/webhooks/" + endpointID.String() + "/deliveries/" + deliveryID.String() + "/redeliver",
+		auth:   accessToken,
+	}, &delivery)
+	return delivery, err
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/apierror"
+	"github.com/jamistoso/chirpy/internal/audit"
+	"github.com/jamistoso/chirpy/internal/auth"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/decode"
+	"github.com/jamistoso/chirpy/internal/logging"
+	"github.com/jamistoso/chirpy/internal/metrics"
+	"github.com/jamistoso/chirpy/internal/subscription"
+)
+
+const (
+	polkaSignatureTolerance = 5 * time.Minute
+	polkaMaxBodyBytes       = 1 << 20
+)
+
+// polkaWebhooksHandler accepts signed events from Polka. Each request carries
+// a Polka-Timestamp header and a Polka-Signature header holding
+// "sha256=<hex HMAC of timestamp.body>"; events are deduplicated on their id
+// so redeliveries are acknowledged without being applied twice. Subscription
+// events move the user's Chirpy Red subscription through its lifecycle; one
+// the subscription can't take is recorded as ignored and acknowledged too,
+// since redelivering it wouldn't change anything.
+func (cfg *apiConfig) polkaWebhooksHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	type parameters struct {
+		ID    string `json:"id" validate:"required"`
+		Event string `json:"event"`
+		Data  struct {
+			UserID           string    `json:"user_id"`
+			Plan             string    `json:"plan"`
+			CurrentPeriodEnd time.Time `json:"current_period_end"`
+		} `json:"data"`
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(rWriter, rq.Body, polkaMaxBodyBytes))
+	if err != nil {
+		respondWithError(rWriter, rq, apierror.InvalidJSON(err))
+		return
+	}
+
+	err = auth.VerifyWebhookSignature(
+		rq.Header.Get("Polka-Signature"),
+		rq.Header.Get("Polka-Timestamp"),
+		body,
+		cfg.polkaKeys,
+		time.Now(),
+		polkaSignatureTolerance,
+	)
+	if err != nil {
+		cfg.metrics.AuthFailure(metrics.AuthPolkaSignature)
+		respondWithError(rWriter, rq, apierror.Unauthorized(apierror.CodeInvalidSignature, err.Error()))
+		return
+	}
+
+	// Unlike our own clients', Polka's events are decoded leniently: they may
+	// gain fields, and a missing Content-Type shouldn't lose an upgrade.
+	rqParams := parameters{}
+	err = json.Unmarshal(body, &rqParams)
+	if err != nil {
+		respondWithError(rWriter, rq, apierror.InvalidJSON(err))
+		return
+	}
+	err = decode.Validate(&rqParams)
+	if err != nil {
+		respondWithError(rWriter, rq, err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(rq.Context(), nil)
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("starting transaction: %w", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	recorded, err := qtx.RecordPolkaEvent(rq.Context(), database.RecordPolkaEventParams{
+		ID:    rqParams.ID,
+		Event: rqParams.Event,
+	})
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("recording event: %w", err))
+		return
+	}
+	if recorded == 0 {
+		// Already processed: acknowledge so Polka stops redelivering.
+		respondWithJSON(rWriter, 204, nil)
+		return
+	}
+
+	var auditEvent *audit.Event
+	upgraded := false
+	if subscription.Handles(rqParams.Event) {
+		id, err := uuid.Parse(rqParams.Data.UserID)
+		if err != nil {
+			respondWithError(rWriter, rq, apierror.Validation(apierror.FieldError{Field: "data.user_id", Message: "must be a UUID"}))
+			return
+		}
+		_, err = qtx.GetUserFromID(rq.Context(), id)
+		if err != nil {
+			respondWithError(rWriter, rq, apierror.NoRows(err, apierror.NotFound("user not found")))
+			return
+		}
+
+		before, sub, err := applySubscriptionEvent(rq.Context(), qtx, id, subscription.Event{
+			Type:      rqParams.Event,
+			Plan:      rqParams.Data.Plan,
+			PeriodEnd: rqParams.Data.CurrentPeriodEnd,
+		}, rqParams.ID)
+		switch {
+		case errors.Is(err, subscription.ErrInvalidTransition):
+			logging.FromContext(rq.Context()).Warn("ignoring polka event", "event_id", rqParams.ID, "err", err)
+			err = qtx.IgnorePolkaEvent(rq.Context(), database.IgnorePolkaEventParams{
+				ID:            rqParams.ID,
+				IgnoredReason: sql.NullString{String: err.Error(), Valid: true},
+			})
+			if err != nil {
+				respondWithError(rWriter, rq, fmt.Errorf("recording ignored event: %w", err))
+				return
+			}
+		case err != nil:
+			respondWithError(rWriter, rq, err)
+			return
+		default:
+			after := newSubscriptionResponse(sub)
+			upgraded = after.Is_chirpy_red && (before == nil || !subscription.IsRed(*before, time.Now()))
+			auditEvent = &audit.Event{
+				ActorType:  audit.ActorWebhook,
+				Action:     "polka." + rqParams.Event,
+				TargetType: "user",
+				TargetID:   id.String(),
+				Before:     before,
+				After:      after,
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("committing event: %w", err))
+		return
+	}
+
+	if upgraded {
+		cfg.metrics.RedUpgrades.Inc()
+	}
+	if auditEvent != nil {
+		cfg.audit(rq, *auditEvent)
+	}
+	respondWithJSON(rWriter, 204, nil)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/jamistoso/chirpy/internal/logging"
+	"github.com/jamistoso/chirpy/internal/ratelimit"
+	"github.com/jamistoso/chirpy/internal/tracing"
+)
+
+// handler serves chirpy's routes, traced, logged to logger and measured. It
+// is what runServe listens with, and what the integration tests call.
+func (cfg *apiConfig) handler(authPolicy, chirpsPolicy ratelimit.Policy, logger *slog.Logger) http.Handler {
+	serveMux := http.NewServeMux()
+	for _, route := range cfg.routes(authPolicy, chirpsPolicy) {
+		serveMux.Handle(route.pattern, route.handler)
+	}
+	return tracing.Middleware(logging.Middleware(logger, cfg.metrics.Instrument(serveMux)))
+}
+
+// metricsRoutes serves the Prometheus metrics. runServe listens with it on
+// its own address, since they aren't for the API's clients.
+func (cfg *apiConfig) metricsRoutes() http.Handler {
+	serveMux := http.NewServeMux()
+	serveMux.Handle("GET /metrics", cfg.metrics.Handler())
+	return serveMux
+}
+
+// route is a ServeMux pattern and what serves it.
+type route struct {
+	pattern string
+	handler http.Handler
+}
+
+// routes is everything chirpy serves. Each API route is documented in
+// api/openapi.yaml, which TestSpecCoversRoutes holds to this list.
+func (cfg *apiConfig) routes(authPolicy, chirpsPolicy ratelimit.Policy) []route {
+	limit := func(policy ratelimit.Policy, handler http.HandlerFunc) http.Handler {
+		return cfg.limiter.Limit(policy, handler)
+	}
+	serveHandler := http.StripPrefix("/app", http.FileServer(http.Dir(".")))
+
+	return []route{
+		{"/app/", cfg.middlewareMetricsInc(serveHandler)},
+
+		{"GET /api/openapi.json", http.HandlerFunc(openAPIHandler)},
+		{"GET /api/docs", http.HandlerFunc(docsHandler)},
+		{"GET /api/docs/assets/{name}", http.HandlerFunc(docsAssetHandler)},
+
+		{"GET /api/livez", http.HandlerFunc(livezHandler)},
+		{"GET /api/readyz", http.HandlerFunc(cfg.readyHandler)},
+		{"GET /api/healthz", http.HandlerFunc(healthzHandler)},
+
+		{"GET /api/chirps", http.HandlerFunc(cfg.getMultipleChirpsHandler)},
+		{"GET /api/chirps/{chirpID}", http.HandlerFunc(cfg.getOneChirpHandler)},
+		{"DELETE /api/chirps/{chirpID}", http.HandlerFunc(cfg.deleteOneChirpHandler)},
+		{"PUT /api/chirps/{chirpID}", limit(chirpsPolicy, cfg.editChirpHandler)},
+		{"POST /api/chirps", limit(chirpsPolicy, cfg.postChirpsHandler)},
+
+		{"POST /api/users", limit(authPolicy, cfg.usersHandler)},
+		{"PUT /api/users", limit(authPolicy, cfg.usersPutHandler)},
+		{"POST /api/login", limit(authPolicy, cfg.loginHandler)},
+		{"POST /api/refresh", limit(authPolicy, cfg.refreshHandler)},
+		{"POST /api/revoke", limit(authPolicy, cfg.revokeHandler)},
+
+		{"POST /api/polka/webhooks", http.HandlerFunc(cfg.polkaWebhooksHandler)},
+		{"GET /api/subscription", http.HandlerFunc(cfg.subscriptionHandler)},
+		{"GET /api/entitlements", http.HandlerFunc(cfg.entitlementsHandler)},
+
+		{"POST /api/webhooks", http.HandlerFunc(cfg.createWebhookHandler)},
+		{"GET /api/webhooks", http.HandlerFunc(cfg.listWebhooksHandler)},
+		{"DELETE /api/webhooks/{endpointID}", http.HandlerFunc(cfg.deleteWebhookHandler)},
+		{"GET /api/webhooks/{endpointID}/deliveries", http.HandlerFunc(cfg.webhookDeliveriesHandler)},
+		{"GET /api/webhooks/{endpointID}/deliveries/{deliveryID}", http.HandlerFunc(cfg.webhookDeliveryHandler)},
+		{"POST /api/webhooks/{endpointID}/deliveries/{deliveryID}/redeliver", http.HandlerFunc(cfg.redeliverWebhookHandler)},
+
+		{"GET /admin/metrics", http.HandlerFunc(cfg.metricsHandler)},
+		{"POST /admin/reset", http.HandlerFunc(cfg.resetHandler)},
+		{"GET /admin/moderation", http.HandlerFunc(cfg.moderationQueueHandler)},
+		{"POST /admin/moderation/{decisionID}", http.HandlerFunc(cfg.resolveModerationHandler)},
+		{"PUT /admin/users/{userID}/state", http.HandlerFunc(cfg.userStateHandler)},
+		{"GET /admin/audit", http.HandlerFunc(cfg.auditLogHandler)},
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/auth"
+	"github.com/jamistoso/chirpy/internal/config"
+	"github.com/jamistoso/chirpy/internal/database"
+)
+
+var (
+	seedFirstNames = []string{
+		"ada", "alan", "barbara", "dennis", "edsger", "frances", "grace", "guido", "john", "ken",
+		"linus", "margaret", "niklaus", "radia", "rob", "sophie", "tim", "donald", "katherine", "leslie",
+	}
+	seedLastNames = []string{
+		"lovelace", "turing", "liskov", "ritchie", "dijkstra", "allen", "hopper", "rossum", "backus", "thompson",
+		"torvalds", "hamilton", "wirth", "perlman", "pike", "wilson", "berners-lee", "knuth", "johnson", "lamport",
+	}
+	seedOpeners = []string{
+		"Just finished", "Can't stop thinking about", "Hot take:", "Today I learned about", "Reminder:",
+		"Spent all morning on", "Unpopular opinion:", "Finally shipped", "Reading up on", "Anyone else into",
+	}
+	seedTopics = []string{
+		"garbage collectors", "my sourdough starter", "distributed consensus", "the new coffee place downtown",
+		"tabs versus spaces", "a 40 year old paper on compilers", "mechanical keyboards", "type inference",
+		"birdwatching at dawn", "refactoring legacy code", "the perfect espresso", "writing tests first",
+		"vim keybindings", "long walks without my phone", "hash maps", "a tiny side project",
+	}
+	seedClosers = []string{
+		"and I regret nothing.", "Thoughts?", "10/10 would recommend.", "Send help.", "More on this later.",
+		"It's the little things.", "Who knew?", "Back to work.", "",
+	}
+)
+
+// runSeed implements `chirpy seed`, which fills a dev database with users
+// and chirps that look like real activity. All seeded users share one
+// password so that they can be logged into.
+func runSeed(conf config.Config, args []string) error {
+	flags := newFlagSet("seed")
+	users := flags.Int("users", 10, "number of users to create")
+	chirps := flags.Int("chirps", 5, "number of chirps per user")
+	password := flags.String("password", "password", "`password` of every seeded user")
+	seed := flags.Uint64("seed", 0, "random seed, for repeatable data; 0 picks one")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if conf.Platform != config.PlatformDev {
+		return errors.New("seed only runs with PLATFORM=dev")
+	}
+	if *users < 0 || *chirps < 0 {
+		return errors.New("seed: -users and -chirps can't be negative")
+	}
+	if *seed == 0 {
+		*seed = uint64(time.Now().UnixNano())
+	}
+	rng := rand.New(rand.NewPCG(*seed, *seed))
+
+	db, err := openDB(conf)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	dbQueries := database.New(db)
+	ctx := context.Background()
+
+	hashedPassword, err := auth.HashPassword(*password)
+	if err != nil {
+		return err
+	}
+
+	createdUsers, createdChirps := 0, 0
+	for range *users {
+		dbUser, err := seedUser(ctx, dbQueries, rng, hashedPassword)
+		if err != nil {
+			return err
+		}
+		createdUsers++
+
+		for range *chirps {
+			_, err := dbQueries.CreateChirp(ctx, database.CreateChirpParams{
+				Body:       seedChirpBody(rng),
+				UserID:     uuid.NullUUID{UUID: dbUser.ID, Valid: true},
+				Visibility: chirpVisible,
+			})
+			if err != nil {
+				return fmt.Errorf("creating chirp: %w", err)
+			}
+			createdChirps++
+		}
+	}
+
+	fmt.Printf("seeded %d users and %d chirps (seed %d); every user's password is %q\n", createdUsers, createdChirps, *seed, *password)
+	return nil
+}
+
+// seedUser creates a user with a made-up name, numbering the email when the
+// name is already taken.
+func seedUser(ctx context.Context, dbQueries *database.Queries, rng *rand.Rand, hashedPassword string) (database.User, error) {
+	first := seedFirstNames[rng.IntN(len(seedFirstNames))]
+	last := seedLastNames[rng.IntN(len(seedLastNames))]
+	for n := 1; ; n++ {
+		email := fmt.Sprintf("%s.%s@example.com", first, last)
+		if n > 1 {
+			email = fmt.Sprintf("%s.%s%d@example.com", first, last, n)
+		}
+		_, err := dbQueries.GetUserFromEmail(ctx, email)
+		if err == nil {
+			continue
+		}
+		dbUser, err := dbQueries.CreateUser(ctx, database.CreateUserParams{
+			Email:          email,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			return database.User{}, fmt.Errorf("creating user %s: %w", email, err)
+		}
+		return dbUser, nil
+	}
+}
+
+func seedChirpBody(rng *rand.Rand) string {
+	parts := []string{
+		seedOpeners[rng.IntN(len(seedOpeners))],
+		seedTopics[rng.IntN(len(seedTopics))],
+	}
+	if closer := seedClosers[rng.IntN(len(seedClosers))]; closer != "" {
+		parts = append(parts, closer)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jamistoso/chirpy/internal/audit"
+	"github.com/jamistoso/chirpy/internal/auth"
+	"github.com/jamistoso/chirpy/internal/config"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/entitlements"
+	"github.com/jamistoso/chirpy/internal/metrics"
+	"github.com/jamistoso/chirpy/internal/moderation"
+	"github.com/jamistoso/chirpy/internal/ratelimit"
+	"github.com/jamistoso/chirpy/internal/sqlite"
+	"github.com/jamistoso/chirpy/internal/store"
+	"github.com/jamistoso/chirpy/internal/tracing"
+)
+
+const testPolkaKey = "test-polka-key"
+
+// newTestDB returns a migrated database of the test's own. It is a SQLite
+// file, or, when CHIRPY_TEST_DB_URL names a Postgres database by URL, a
+// schema created in it for the test and dropped afterwards.
+func newTestDB(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		dbURL = "sqlite:" + filepath.Join(t.TempDir(), "chirpy.db")
+	} else {
+		dbURL = newTestSchema(t, dbURL)
+	}
+
+	db, err := openDB(config.Config{DatabaseURL: dbURL})
+	if err != nil {
+		t.Fatalf(`opening %s: %v`, dbURL, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrator, err := newMigrator(db, dbURL)
+	if err != nil {
+		t.Fatalf(`newMigrator() = %v`, err)
+	}
+	_, err = migrator.Up(context.Background())
+	if err != nil {
+		t.Fatalf(`migrating: %v`, err)
+	}
+	return db, dbURL
+}
+
+// newTestSchema creates an empty schema in the Postgres database at dbURL
+// and returns a URL whose connections use it.
+func newTestSchema(t *testing.T, dbURL string) string {
+	t.Helper()
+	parsed, err := url.Parse(dbURL)
+	if err != nil || parsed.Scheme == "" {
+		t.Fatalf(`CHIRPY_TEST_DB_URL must be a postgres:// URL`)
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf(`opening CHIRPY_TEST_DB_URL: %v`, err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	schema := "chirpy_test_" + hex.EncodeToString(suffix)
+	_, err = db.Exec(`CREATE SCHEMA ` + schema)
+	if err != nil {
+		t.Fatalf(`creating schema %s: %v`, schema, err)
+	}
+	t.Cleanup(func() {
+		_, err := db.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		if err != nil {
+			t.Errorf(`dropping schema %s: %v`, schema, err)
+		}
+	})
+
+	// lib/pq sends parameters it doesn't know itself to the server.
+	query := parsed.Query()
+	query.Set("search_path", schema)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// newTestServer serves chirpy's handler from a fresh database, without rate
+// limits that tests would run into. Polka's events are signed with
+// testPolkaKey.
+func newTestServer(t *testing.T) (*httptest.Server, *apiConfig) {
+	t.Helper()
+	db, dbURL := newTestDB(t)
+	migrator, err := newMigrator(db, dbURL)
+	if err != nil {
+		t.Fatalf(`newMigrator() = %v`, err)
+	}
+
+	dbSystem := tracing.SystemPostgres
+	if sqlite.IsURL(dbURL) {
+		dbSystem = tracing.SystemSQLite
+	}
+	dbQueries := database.New(db)
+	jwtKeys := auth.NewKeyring("test-secret-test-secret-test-secret")
+	principals := ratelimit.PrincipalResolver{Keys: jwtKeys}
+	cfg := &apiConfig{
+		metrics:   metrics.New(db),
+		db:        db,
+		dbQueries: dbQueries,
+		dbSystem:  dbSystem,
+		users:     dbQueries,
+		chirps:    dbQueries,
+		tokens:    dbQueries,
+		platform:  "dev",
+		jwtKeys:   jwtKeys,
+		polkaKeys: []string{testPolkaKey},
+		limiter: &ratelimit.Limiter{
+			Store:     ratelimit.NewMemoryStore(),
+			Principal: principals.Key,
+		},
+		moderator:    moderation.NewPipeline(),
+		auditor:      audit.New(dbQueries, principals.ClientIP),
+		entitlements: entitlements.Default(),
+		// Receivers in tests are httptest servers on 127.0.0.1.
+		webhookLoopback: true,
+		health:          newHealthChecker(db, migrator, dbQueries),
+	}
+	cfg.transactor = store.SQL{Queries: dbQueries, DB: db, WithTx: cfg.withTx}
+	cfg.ready.Store(true)
+
+	unlimited, _ := ratelimit.ParsePolicy("test", "1000/1s")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server := httptest.NewServer(cfg.handler(unlimited, unlimited, logger))
+	t.Cleanup(server.Close)
+	return server, cfg
+}
+
+// call sends body, if it isn't nil, as JSON to the test server with token as
+// the bearer token, if it isn't empty. It returns the response's status and,
+// for errors, its problem code; a successful response's body is decoded into
+// out, if out isn't nil.
+func call(t *testing.T, server *httptest.Server, method, path, token string, body, out any) (int, string) {
+	t.Helper()
+	var bodyReader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf(`encoding %s %s body: %v`, method, path, err)
+		}
+		bodyReader = bytes.NewReader(data)
+	}
+	rq, err := http.NewRequest(method, server.URL+path, bodyReader)
+	if err != nil {
+		t.Fatalf(`NewRequest(%s %s) = %v`, method, path, err)
+	}
+	if body != nil {
+		rq.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		rq.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := server.Client().Do(rq)
+	if err != nil {
+		t.Fatalf(`%s %s: %v`, method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var problem struct {
+			Code string `json:"code"`
+		}
+		json.NewDecoder(resp.Body).Decode(&problem)
+		return resp.StatusCode, problem.Code
+	}
+	if out != nil {
+		err = json.NewDecoder(resp.Body).Decode(out)
+		if err != nil {
+			t.Fatalf(`decoding %s %s response: %v`, method, path, err)
+		}
+	}
+	return resp.StatusCode, ""
+}
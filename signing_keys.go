@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jamistoso/chirpy/internal/audit"
+	"github.com/jamistoso/chirpy/internal/auth"
+	"github.com/jamistoso/chirpy/internal/config"
+	"github.com/jamistoso/chirpy/internal/database"
+)
+
+// signingKeyRefreshInterval is how soon running servers notice a rotation.
+// A server that sees a token from a key it hasn't loaded yet reloads straight
+// away, so this mostly decides when they start signing with the new key.
+const signingKeyRefreshInterval = 30 * time.Second
+
+// loadSigningKeys loads the access token keys into keyring. The newest
+// unretired key signs. Retired keys keep validating for as long as a token
+// they signed can live, and so does JWT_SECRET after the first rotation.
+func loadSigningKeys(ctx context.Context, db *database.Queries, keyring *auth.Keyring, legacySecret string) error {
+	keys, err := db.GetSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var active auth.SigningKey
+	var verify []auth.SigningKey
+	for _, key := range keys {
+		signingKey := auth.SigningKey{ID: key.ID, Secret: key.Secret}
+		switch {
+		case !key.RetiredAt.Valid && active.ID == "":
+			active = signingKey
+		case !key.RetiredAt.Valid || now.Sub(key.RetiredAt.Time) < accessTokenTTL:
+			verify = append(verify, signingKey)
+		}
+	}
+
+	if len(keys) > 0 && now.Sub(keys[len(keys)-1].CreatedAt) >= accessTokenTTL {
+		legacySecret = ""
+	}
+	keyring.Update(active, verify, legacySecret)
+	return nil
+}
+
+func refreshSigningKeys(ctx context.Context, keyring *auth.Keyring) {
+	ticker := time.NewTicker(signingKeyRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := keyring.Refresh()
+		if err != nil {
+			slog.Error("error refreshing signing keys", "err", err)
+		}
+	}
+}
+
+// runRotateKeys implements `chirpy rotate-keys`: it adds a new signing key,
+// retires the current one and drops keys retired long enough ago that
+// nothing they signed is still valid.
+func runRotateKeys(conf config.Config, args []string) error {
+	err := parseFlags(newFlagSet("rotate-keys"), args)
+	if err != nil {
+		return err
+	}
+	db, err := openDB(conf)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	dbQueries := database.New(db)
+	ctx := context.Background()
+
+	key, err := auth.NewSigningKey()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := dbQueries.WithTx(tx)
+
+	_, err = qtx.CreateSigningKey(ctx, database.CreateSigningKeyParams{
+		ID:     key.ID,
+		Secret: key.Secret,
+	})
+	if err != nil {
+		return fmt.Errorf("creating signing key: %w", err)
+	}
+	err = qtx.RetireSigningKeys(ctx, key.ID)
+	if err != nil {
+		return fmt.Errorf("retiring signing keys: %w", err)
+	}
+	deleted, err := qtx.DeleteRetiredSigningKeys(ctx, sql.NullTime{Time: time.Now().Add(-accessTokenTTL), Valid: true})
+	if err != nil {
+		return fmt.Errorf("deleting old signing keys: %w", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	recordCLIEvent(ctx, dbQueries, audit.Event{
+		Action:     "signing_keys.rotate",
+		TargetType: "signing_key",
+		TargetID:   key.ID,
+		After:      map[string]any{"deleted_keys": deleted},
+	})
+
+	fmt.Printf("new signing key %s; running servers switch to it within %s\n", key.ID, signingKeyRefreshInterval)
+	fmt.Printf("the previous key keeps validating tokens for %s\n", accessTokenTTL)
+	if deleted > 0 {
+		fmt.Printf("deleted %d expired keys\n", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+-- name: CreateAuditEntry :one
+INSERT INTO audit_log (created_at, actor_id, actor_type, action, target_type, target_id, request_id, ip, before, after)
+VALUES (
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+)
+RETURNING *;
+
+-- name: ListAuditEntries :many
+SELECT * FROM audit_log
+WHERE (sqlc.narg(actor_id) IS NULL OR actor_id = sqlc.narg(actor_id))
+AND (sqlc.narg(action) IS NULL OR action = sqlc.narg(action))
+AND (sqlc.narg(target_id) IS NULL OR target_id = sqlc.narg(target_id))
+AND (sqlc.narg(since) IS NULL OR created_at >= sqlc.narg(since))
+AND (sqlc.narg(until) IS NULL OR created_at < sqlc.narg(until))
+AND (sqlc.narg(before_id) IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(max_entries);
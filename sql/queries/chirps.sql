@@ -1,26 +1,77 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, visibility)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
--- name: GetAllChirps :many
+-- name: ListChirps :many
 SELECT * FROM chirps
-ORDER BY created_at ASC;
+WHERE (sqlc.narg(author_id) IS NULL OR user_id = sqlc.narg(author_id))
+AND (
+    (
+        visibility = 'visible'
+        AND NOT EXISTS (
+            SELECT 1 FROM users
+            WHERE users.id = chirps.user_id
+            AND users.state = 'shadow_banned'
+            AND (users.state_expires_at IS NULL OR users.state_expires_at > NOW())
+        )
+    )
+    OR user_id = sqlc.narg(viewer_id)
+)
+AND (created_at, id) > (sqlc.arg(after_created_at), sqlc.arg(after_id))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(max_chirps);
 
--- name: GetChirpsFromAuthor :many
+-- name: ListChirpsDesc :many
 SELECT * FROM chirps
-WHERE user_id = $1;
+WHERE (sqlc.narg(author_id) IS NULL OR user_id = sqlc.narg(author_id))
+AND (
+    (
+        visibility = 'visible'
+        AND NOT EXISTS (
+            SELECT 1 FROM users
+            WHERE users.id = chirps.user_id
+            AND users.state = 'shadow_banned'
+            AND (users.state_expires_at IS NULL OR users.state_expires_at > NOW())
+        )
+    )
+    OR user_id = sqlc.narg(viewer_id)
+)
+AND (created_at, id) < (sqlc.arg(after_created_at), sqlc.arg(after_id))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_chirps);
 
 -- name: GetOneChirp :one
 SELECT * FROM chirps
 WHERE id = $1;
 
+-- name: GetRecentChirpsFromAuthor :many
+SELECT * FROM chirps
+WHERE user_id = $1
+AND created_at > sqlc.arg(since)
+ORDER BY created_at DESC;
+
+-- name: SetChirpVisibility :exec
+UPDATE chirps
+SET visibility = $1,
+updated_at = NOW()
+WHERE id = $2;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1,
+visibility = $2,
+updated_at = NOW()
+WHERE id = $3
+RETURNING *;
+
 -- name: DeleteChirp :exec
 DELETE FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
@@ -0,0 +1,54 @@
+-- name: EnqueueJob :one
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, status, attempts, max_attempts, run_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    'queued',
+    0,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: ClaimJobs :many
+UPDATE jobs
+SET status = 'running',
+attempts = attempts + 1,
+run_at = sqlc.arg(lease_until),
+updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE status IN ('queued', 'running')
+    AND run_at <= sqlc.arg(now)
+    ORDER BY run_at ASC
+    LIMIT sqlc.arg(max_jobs)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: FinishJob :exec
+UPDATE jobs
+SET status = $1,
+run_at = $2,
+last_error = $3,
+updated_at = NOW()
+WHERE id = $4;
+
+-- name: GetJob :one
+SELECT * FROM jobs
+WHERE id = $1;
+
+-- name: DeleteFinishedJobs :execrows
+DELETE FROM jobs
+WHERE status = 'succeeded'
+AND updated_at < $1;
+
+-- name: OldestDueJob :one
+SELECT run_at FROM jobs
+WHERE status IN ('queued', 'running')
+AND run_at <= sqlc.arg(now)
+ORDER BY run_at ASC
+LIMIT 1;
@@ -0,0 +1,34 @@
+-- name: CreateModerationDecision :one
+INSERT INTO moderation_decisions (id, created_at, updated_at, user_id, chirp_id, body, action, score, reasons, status)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: GetModerationDecision :one
+SELECT * FROM moderation_decisions
+WHERE id = $1;
+
+-- name: GetPendingModerationDecisions :many
+SELECT * FROM moderation_decisions
+WHERE status = 'pending'
+ORDER BY created_at ASC;
+
+-- name: ResolveModerationDecision :one
+UPDATE moderation_decisions
+SET status = 'resolved',
+resolution = $1,
+resolved_by = $2,
+resolved_at = NOW(),
+updated_at = NOW()
+WHERE id = $3
+RETURNING *;
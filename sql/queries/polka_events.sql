@@ -0,0 +1,13 @@
+-- name: RecordPolkaEvent :execrows
+INSERT INTO polka_events (id, event, received_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: IgnorePolkaEvent :exec
+UPDATE polka_events
+SET ignored_reason = $2
+WHERE id = $1;
@@ -0,0 +1,21 @@
+-- name: TakeRateLimitToken :one
+INSERT INTO rate_limits (key, tokens, allowed, updated_at)
+VALUES (
+    $1,
+    sqlc.arg(burst)::float8 - 1,
+    true,
+    NOW()
+)
+ON CONFLICT (key) DO UPDATE
+SET tokens = CASE
+        WHEN LEAST(sqlc.arg(burst)::float8, rate_limits.tokens + EXTRACT(EPOCH FROM NOW() - rate_limits.updated_at)::float8 * sqlc.arg(rate)::float8) >= 1
+        THEN LEAST(sqlc.arg(burst)::float8, rate_limits.tokens + EXTRACT(EPOCH FROM NOW() - rate_limits.updated_at)::float8 * sqlc.arg(rate)::float8) - 1
+        ELSE LEAST(sqlc.arg(burst)::float8, rate_limits.tokens + EXTRACT(EPOCH FROM NOW() - rate_limits.updated_at)::float8 * sqlc.arg(rate)::float8)
+    END,
+    allowed = LEAST(sqlc.arg(burst)::float8, rate_limits.tokens + EXTRACT(EPOCH FROM NOW() - rate_limits.updated_at)::float8 * sqlc.arg(rate)::float8) >= 1,
+    updated_at = NOW()
+RETURNING tokens, allowed;
+
+-- name: DeleteStaleRateLimits :exec
+DELETE FROM rate_limits
+WHERE updated_at < $1;
@@ -18,4 +18,11 @@ WHERE token = $1;
 UPDATE refresh_tokens
 SET updated_at = NOW(),
 revoked_at = NOW()
-WHERE token = $1;
\ No newline at end of file
+WHERE token = $1;
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET updated_at = NOW(),
+revoked_at = NOW()
+WHERE user_id = $1
+AND revoked_at IS NULL;
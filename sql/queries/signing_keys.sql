@@ -0,0 +1,23 @@
+-- name: CreateSigningKey :one
+INSERT INTO signing_keys (id, created_at, secret, retired_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    NULL
+)
+RETURNING *;
+
+-- name: GetSigningKeys :many
+SELECT * FROM signing_keys
+ORDER BY created_at DESC;
+
+-- name: RetireSigningKeys :exec
+UPDATE signing_keys
+SET retired_at = NOW()
+WHERE retired_at IS NULL
+AND id <> $1;
+
+-- name: DeleteRetiredSigningKeys :execrows
+DELETE FROM signing_keys
+WHERE retired_at < $1;
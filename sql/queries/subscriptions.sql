@@ -0,0 +1,48 @@
+-- name: GetSubscriptionFromUser :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end, cancel_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+status = EXCLUDED.status,
+current_period_end = EXCLUDED.current_period_end,
+cancel_at = EXCLUDED.cancel_at,
+updated_at = NOW()
+RETURNING *;
+
+-- name: ExpireLapsedSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired',
+updated_at = NOW()
+WHERE status <> 'expired'
+AND COALESCE(cancel_at, current_period_end) <= sqlc.arg(now)
+RETURNING *;
+
+-- name: CreateSubscriptionEvent :exec
+INSERT INTO subscription_events (created_at, subscription_id, event, status, current_period_end, cancel_at, source_event_id)
+VALUES (
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+);
+
+-- name: GetSubscriptionEvents :many
+SELECT * FROM subscription_events
+WHERE subscription_id = $1
+ORDER BY id ASC;
@@ -23,8 +23,22 @@ email = $2
 WHERE id = $3
 RETURNING *;
 
--- name: UpgradeUserToRed :one
+-- name: GetUserFromID :one
+SELECT * FROM users
+WHERE id = $1;
+
+
+-- name: SetUserState :one
 UPDATE users
-SET is_chirpy_red = True
-WHERE id = $1
-RETURNING *;
\ No newline at end of file
+SET state = $1,
+state_reason = $2,
+state_expires_at = $3,
+updated_at = NOW()
+WHERE id = $4
+RETURNING *;
+-- name: SetUserAdmin :one
+UPDATE users
+SET is_admin = $1,
+updated_at = NOW()
+WHERE id = $2
+RETURNING *;
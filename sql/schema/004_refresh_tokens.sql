@@ -12,4 +12,4 @@ CREATE TABLE refresh_tokens(
 );
 
 -- +goose Down
-DROP TABLE chirps;
\ No newline at end of file
+DROP TABLE refresh_tokens;
\ No newline at end of file
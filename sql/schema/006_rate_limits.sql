@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE rate_limits(
+    key         TEXT                PRIMARY KEY,
+    tokens      DOUBLE PRECISION    NOT NULL,
+    allowed     BOOLEAN             NOT NULL,
+    updated_at  TIMESTAMP           NOT NULL
+);
+
+-- +goose Down
+DROP TABLE rate_limits;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE users
+ADD state TEXT NOT NULL DEFAULT 'active',
+ADD state_reason TEXT NOT NULL DEFAULT '',
+ADD state_expires_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN state,
+DROP COLUMN state_reason,
+DROP COLUMN state_expires_at;
@@ -0,0 +1,39 @@
+-- +goose Up
+CREATE TABLE audit_log(
+    id          BIGSERIAL   PRIMARY KEY,
+    created_at  TIMESTAMP   NOT NULL,
+    actor_id    UUID,
+    actor_type  TEXT        NOT NULL,
+    action      TEXT        NOT NULL,
+    target_type TEXT        NOT NULL,
+    target_id   TEXT        NOT NULL,
+    request_id  TEXT        NOT NULL,
+    ip          TEXT        NOT NULL,
+    before      JSONB       NOT NULL,
+    after       JSONB       NOT NULL
+);
+
+CREATE INDEX audit_log_actor_idx ON audit_log(actor_id, id);
+CREATE INDEX audit_log_action_idx ON audit_log(action, id);
+CREATE INDEX audit_log_target_idx ON audit_log(target_id, id);
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_log_no_update_or_delete
+BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_no_truncate
+BEFORE TRUNCATE ON audit_log
+FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+-- +goose Down
+DROP TABLE audit_log;
+
+DROP FUNCTION audit_log_append_only;
@@ -0,0 +1,57 @@
+-- +goose Up
+CREATE TABLE subscriptions(
+    id                  UUID        PRIMARY KEY,
+    created_at          TIMESTAMP   NOT NULL,
+    updated_at          TIMESTAMP   NOT NULL,
+    user_id             UUID        NOT NULL UNIQUE
+                                    REFERENCES users(id)
+                                    ON DELETE CASCADE,
+    plan                TEXT        NOT NULL,
+    status              TEXT        NOT NULL,
+    current_period_end  TIMESTAMP   NOT NULL,
+    cancel_at           TIMESTAMP
+);
+
+CREATE TABLE subscription_events(
+    id                  BIGSERIAL   PRIMARY KEY,
+    created_at          TIMESTAMP   NOT NULL,
+    subscription_id     UUID        NOT NULL
+                                    REFERENCES subscriptions(id)
+                                    ON DELETE CASCADE,
+    event               TEXT        NOT NULL,
+    status              TEXT        NOT NULL,
+    current_period_end  TIMESTAMP   NOT NULL,
+    cancel_at           TIMESTAMP,
+    source_event_id     TEXT
+);
+
+CREATE INDEX subscription_events_subscription_idx ON subscription_events(subscription_id, id);
+
+-- users.is_chirpy_red is kept only as a cache of subscription state so
+-- existing queries keep working; nothing writes it except this trigger.
+-- +goose StatementBegin
+CREATE FUNCTION sync_is_chirpy_red() RETURNS trigger AS $$
+BEGIN
+    UPDATE users
+    SET is_chirpy_red = NEW.status <> 'expired'
+    WHERE id = NEW.user_id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER subscriptions_sync_is_chirpy_red
+AFTER INSERT OR UPDATE ON subscriptions
+FOR EACH ROW EXECUTE FUNCTION sync_is_chirpy_red();
+
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end, cancel_at)
+SELECT gen_random_uuid(), NOW(), NOW(), id, 'red', 'active', NOW() + INTERVAL '30 days', NULL
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscription_events;
+
+DROP TABLE subscriptions;
+
+DROP FUNCTION sync_is_chirpy_red;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- An event is delivered to each endpoint at most once, however many times
+-- the job fanning it out runs.
+ALTER TABLE webhook_deliveries
+ADD event_id UUID;
+
+UPDATE webhook_deliveries
+SET event_id = (payload->>'id')::UUID;
+
+ALTER TABLE webhook_deliveries
+ALTER COLUMN event_id SET NOT NULL;
+
+CREATE UNIQUE INDEX webhook_deliveries_event_idx ON webhook_deliveries(endpoint_id, event_id);
+
+-- +goose Down
+DROP INDEX webhook_deliveries_event_idx;
+
+ALTER TABLE webhook_deliveries
+DROP COLUMN event_id;
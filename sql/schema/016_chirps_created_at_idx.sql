@@ -0,0 +1,6 @@
+-- +goose Up
+-- Chirp listings page through chirps in this order.
+CREATE INDEX chirps_created_at_idx ON chirps(created_at, id);
+
+-- +goose Down
+DROP INDEX chirps_created_at_idx;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Why an event was acknowledged without being applied, if it was.
+ALTER TABLE polka_events ADD COLUMN ignored_reason TEXT;
+
+-- +goose Down
+ALTER TABLE polka_events DROP COLUMN ignored_reason;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE users (
+    id          TEXT        PRIMARY KEY,
+    created_at  TIMESTAMP   NOT NULL,
+    updated_at  TIMESTAMP   NOT NULL,
+    email       TEXT        NOT NULL UNIQUE
+);
+
+-- +goose Down
+DROP TABLE users;
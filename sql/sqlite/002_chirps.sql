@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE chirps(
+    id          TEXT        PRIMARY KEY,
+    created_at  TIMESTAMP   NOT NULL,
+    updated_at  TIMESTAMP   NOT NULL,
+    body        TEXT        NOT NULL,
+    user_id     TEXT        REFERENCES users(id)
+                            ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE chirps;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Only the Postgres rate limit store uses this table; it is created here so
+-- the two schemas keep the same shape.
+CREATE TABLE rate_limits(
+    key         TEXT        PRIMARY KEY,
+    tokens      REAL        NOT NULL,
+    allowed     BOOLEAN     NOT NULL,
+    updated_at  TIMESTAMP   NOT NULL
+);
+
+-- +goose Down
+DROP TABLE rate_limits;
@@ -0,0 +1,36 @@
+-- +goose Up
+ALTER TABLE users
+ADD is_admin BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE chirps
+ADD visibility TEXT NOT NULL DEFAULT 'visible';
+
+CREATE TABLE moderation_decisions(
+    id          TEXT        PRIMARY KEY,
+    created_at  TIMESTAMP   NOT NULL,
+    updated_at  TIMESTAMP   NOT NULL,
+    user_id     TEXT        NOT NULL
+                            REFERENCES users(id)
+                            ON DELETE CASCADE,
+    chirp_id    TEXT        REFERENCES chirps(id)
+                            ON DELETE SET NULL,
+    body        TEXT        NOT NULL,
+    action      TEXT        NOT NULL,
+    score       REAL        NOT NULL,
+    reasons     TEXT        NOT NULL,
+    status      TEXT        NOT NULL,
+    resolution  TEXT,
+    resolved_by TEXT,
+    resolved_at TIMESTAMP
+);
+
+CREATE INDEX moderation_decisions_status_idx ON moderation_decisions(status, created_at);
+
+-- +goose Down
+DROP TABLE moderation_decisions;
+
+ALTER TABLE chirps
+DROP COLUMN visibility;
+
+ALTER TABLE users
+DROP COLUMN is_admin;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- SQLite adds and drops one column per statement.
+ALTER TABLE users
+ADD state TEXT NOT NULL DEFAULT 'active';
+
+ALTER TABLE users
+ADD state_reason TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE users
+ADD state_expires_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN state;
+
+ALTER TABLE users
+DROP COLUMN state_reason;
+
+ALTER TABLE users
+DROP COLUMN state_expires_at;
@@ -0,0 +1,39 @@
+-- +goose Up
+CREATE TABLE audit_log(
+    id          INTEGER     PRIMARY KEY AUTOINCREMENT,
+    created_at  TIMESTAMP   NOT NULL,
+    actor_id    TEXT,
+    actor_type  TEXT        NOT NULL,
+    action      TEXT        NOT NULL,
+    target_type TEXT        NOT NULL,
+    target_id   TEXT        NOT NULL,
+    request_id  TEXT        NOT NULL,
+    ip          TEXT        NOT NULL,
+    before      TEXT        NOT NULL,
+    after       TEXT        NOT NULL
+);
+
+CREATE INDEX audit_log_actor_idx ON audit_log(actor_id, id);
+CREATE INDEX audit_log_action_idx ON audit_log(action, id);
+CREATE INDEX audit_log_target_idx ON audit_log(target_id, id);
+
+-- SQLite has no TRUNCATE; DELETE without a WHERE clause is caught by the
+-- delete trigger.
+-- +goose StatementBegin
+CREATE TRIGGER audit_log_no_update
+BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER audit_log_no_delete
+BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE audit_log;
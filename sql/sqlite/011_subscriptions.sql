@@ -0,0 +1,72 @@
+-- +goose Up
+CREATE TABLE subscriptions(
+    id                  TEXT        PRIMARY KEY,
+    created_at          TIMESTAMP   NOT NULL,
+    updated_at          TIMESTAMP   NOT NULL,
+    user_id             TEXT        NOT NULL UNIQUE
+                                    REFERENCES users(id)
+                                    ON DELETE CASCADE,
+    plan                TEXT        NOT NULL,
+    status              TEXT        NOT NULL,
+    current_period_end  TIMESTAMP   NOT NULL,
+    cancel_at           TIMESTAMP
+);
+
+CREATE TABLE subscription_events(
+    id                  INTEGER     PRIMARY KEY AUTOINCREMENT,
+    created_at          TIMESTAMP   NOT NULL,
+    subscription_id     TEXT        NOT NULL
+                                    REFERENCES subscriptions(id)
+                                    ON DELETE CASCADE,
+    event               TEXT        NOT NULL,
+    status              TEXT        NOT NULL,
+    current_period_end  TIMESTAMP   NOT NULL,
+    cancel_at           TIMESTAMP,
+    source_event_id     TEXT
+);
+
+CREATE INDEX subscription_events_subscription_idx ON subscription_events(subscription_id, id);
+
+-- users.is_chirpy_red is kept only as a cache of subscription state so
+-- existing queries keep working; nothing writes it except these triggers.
+-- +goose StatementBegin
+CREATE TRIGGER subscriptions_sync_is_chirpy_red_insert
+AFTER INSERT ON subscriptions
+BEGIN
+    UPDATE users
+    SET is_chirpy_red = NEW.status <> 'expired'
+    WHERE id = NEW.user_id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER subscriptions_sync_is_chirpy_red_update
+AFTER UPDATE ON subscriptions
+BEGIN
+    UPDATE users
+    SET is_chirpy_red = NEW.status <> 'expired'
+    WHERE id = NEW.user_id;
+END;
+-- +goose StatementEnd
+
+-- Only built-in functions here: the goose CLI doesn't have the now() and
+-- gen_random_uuid() that internal/sqlite registers. Timestamps are written in
+-- the same fixed-width form the server uses.
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end, cancel_at)
+SELECT
+    lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+        substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+    strftime('%Y-%m-%d %H:%M:%f', 'now') || '000',
+    strftime('%Y-%m-%d %H:%M:%f', 'now') || '000',
+    id,
+    'red',
+    'active',
+    strftime('%Y-%m-%d %H:%M:%f', 'now', '+30 days') || '000',
+    NULL
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscription_events;
+
+DROP TABLE subscriptions;
@@ -0,0 +1,49 @@
+-- +goose Up
+CREATE TABLE webhook_endpoints(
+    id          TEXT        PRIMARY KEY,
+    created_at  TIMESTAMP   NOT NULL,
+    updated_at  TIMESTAMP   NOT NULL,
+    user_id     TEXT        NOT NULL
+                            REFERENCES users(id)
+                            ON DELETE CASCADE,
+    url         TEXT        NOT NULL,
+    secret      TEXT        NOT NULL,
+    event_types TEXT        NOT NULL
+);
+
+CREATE INDEX webhook_endpoints_user_idx ON webhook_endpoints(user_id);
+
+CREATE TABLE webhook_deliveries(
+    id              TEXT        PRIMARY KEY,
+    created_at      TIMESTAMP   NOT NULL,
+    updated_at      TIMESTAMP   NOT NULL,
+    endpoint_id     TEXT        NOT NULL
+                                REFERENCES webhook_endpoints(id)
+                                ON DELETE CASCADE,
+    event_type      TEXT        NOT NULL,
+    payload         TEXT        NOT NULL,
+    status          TEXT        NOT NULL,
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP   NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX webhook_deliveries_endpoint_idx ON webhook_deliveries(endpoint_id, created_at);
+
+CREATE TABLE webhook_delivery_attempts(
+    id              INTEGER     PRIMARY KEY AUTOINCREMENT,
+    created_at      TIMESTAMP   NOT NULL,
+    delivery_id     TEXT        NOT NULL
+                                REFERENCES webhook_deliveries(id)
+                                ON DELETE CASCADE,
+    response_status INTEGER,
+    error           TEXT        NOT NULL,
+    duration_ms     INTEGER     NOT NULL
+);
+
+CREATE INDEX webhook_delivery_attempts_delivery_idx ON webhook_delivery_attempts(delivery_id, id);
+
+-- +goose Down
+DROP TABLE webhook_delivery_attempts;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE jobs(
+    id           TEXT        PRIMARY KEY,
+    created_at   TIMESTAMP   NOT NULL,
+    updated_at   TIMESTAMP   NOT NULL,
+    kind         TEXT        NOT NULL,
+    payload      TEXT        NOT NULL,
+    status       TEXT        NOT NULL,
+    attempts     INTEGER     NOT NULL DEFAULT 0,
+    max_attempts INTEGER     NOT NULL,
+    run_at       TIMESTAMP   NOT NULL,
+    last_error   TEXT        NOT NULL DEFAULT ''
+);
+
+CREATE INDEX jobs_due_idx ON jobs(status, run_at);
+
+-- +goose Down
+DROP TABLE jobs;
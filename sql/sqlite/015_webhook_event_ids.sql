@@ -0,0 +1,17 @@
+-- +goose Up
+-- An event is delivered to each endpoint at most once, however many times
+-- the job fanning it out runs. SQLite can't add a NOT NULL column without a
+-- default, so the default is filled in from the payload straight away.
+ALTER TABLE webhook_deliveries
+ADD event_id TEXT NOT NULL DEFAULT '';
+
+UPDATE webhook_deliveries
+SET event_id = json_extract(payload, '$.id');
+
+CREATE UNIQUE INDEX webhook_deliveries_event_idx ON webhook_deliveries(endpoint_id, event_id);
+
+-- +goose Down
+DROP INDEX webhook_deliveries_event_idx;
+
+ALTER TABLE webhook_deliveries
+DROP COLUMN event_id;
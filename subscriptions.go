@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/apierror"
+	"github.com/jamistoso/chirpy/internal/audit"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/logging"
+	"github.com/jamistoso/chirpy/internal/subscription"
+)
+
+const subscriptionExpiryInterval = 5 * time.Minute
+
+func subscriptionState(sub database.Subscription) subscription.State {
+	state := subscription.State{
+		Plan:             sub.Plan,
+		Status:           sub.Status,
+		CurrentPeriodEnd: sub.CurrentPeriodEnd,
+	}
+	if sub.CancelAt.Valid {
+		state.CancelAt = &sub.CancelAt.Time
+	}
+	return state
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+// applySubscriptionEvent moves userID's subscription through ev using qtx,
+// recording the change in the subscription's history. It returns the
+// subscription before (nil if there was none) and after the event. An event
+// the subscription can't take is subscription.ErrInvalidTransition.
+func applySubscriptionEvent(ctx context.Context, qtx *database.Queries, userID uuid.UUID, ev subscription.Event, sourceEventID string) (*subscription.State, database.Subscription, error) {
+	var current *subscription.State
+	existing, err := qtx.GetSubscriptionFromUser(ctx, userID)
+	if err == nil {
+		state := subscriptionState(existing)
+		current = &state
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, database.Subscription{}, err
+	}
+
+	next, err := subscription.Apply(current, ev, time.Now())
+	if err != nil {
+		return nil, database.Subscription{}, err
+	}
+
+	sub, err := qtx.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+		UserID:           userID,
+		Plan:             next.Plan,
+		Status:           next.Status,
+		CurrentPeriodEnd: next.CurrentPeriodEnd,
+		CancelAt:         nullTime(next.CancelAt),
+	})
+	if err != nil {
+		return nil, database.Subscription{}, err
+	}
+
+	err = qtx.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{
+		SubscriptionID:   sub.ID,
+		Event:            ev.Type,
+		Status:           sub.Status,
+		CurrentPeriodEnd: sub.CurrentPeriodEnd,
+		CancelAt:         sub.CancelAt,
+		SourceEventID:    sql.NullString{String: sourceEventID, Valid: sourceEventID != ""},
+	})
+	if err != nil {
+		return nil, database.Subscription{}, err
+	}
+	return current, sub, nil
+}
+
+// expireSubscriptions marks every subscription whose period (or scheduled
+// cancellation) has passed as expired, which clears is_chirpy_red.
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	expired, err := qtx.ExpireLapsedSubscriptions(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, sub := range expired {
+		err = qtx.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{
+			SubscriptionID:   sub.ID,
+			Event:            subscription.EventExpired,
+			Status:           sub.Status,
+			CurrentPeriodEnd: sub.CurrentPeriodEnd,
+			CancelAt:         sub.CancelAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range expired {
+		cfg.auditSystem(ctx, audit.Event{
+			Action:     "subscription.expired",
+			TargetType: "user",
+			TargetID:   sub.UserID.String(),
+			After:      newSubscriptionResponse(sub),
+		})
+	}
+	return nil
+}
+
+func (cfg *apiConfig) runSubscriptionExpiry(ctx context.Context) {
+	ticker := time.NewTicker(subscriptionExpiryInterval)
+	defer ticker.Stop()
+	for {
+		err := cfg.expireSubscriptions(ctx)
+		if err != nil {
+			logging.FromContext(ctx).Error("error expiring subscriptions", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type subscriptionResponse struct {
+	Plan               string     `json:"plan"`
+	Status             string     `json:"status"`
+	Current_period_end time.Time  `json:"current_period_end"`
+	Cancel_at          *time.Time `json:"cancel_at"`
+	Is_chirpy_red      bool       `json:"is_chirpy_red"`
+}
+
+func newSubscriptionResponse(sub database.Subscription) subscriptionResponse {
+	state := subscriptionState(sub)
+	return subscriptionResponse{
+		Plan:               state.Plan,
+		Status:             state.Status,
+		Current_period_end: state.CurrentPeriodEnd,
+		Cancel_at:          state.CancelAt,
+		Is_chirpy_red:      subscription.IsRed(state, time.Now()),
+	}
+}
+
+type subscriptionEventResponse struct {
+	Event              string     `json:"event"`
+	Created_at         time.Time  `json:"created_at"`
+	Status             string     `json:"status"`
+	Current_period_end time.Time  `json:"current_period_end"`
+	Cancel_at          *time.Time `json:"cancel_at"`
+}
+
+func newSubscriptionEventResponse(event database.SubscriptionEvent) subscriptionEventResponse {
+	resp := subscriptionEventResponse{
+		Event:              event.Event,
+		Created_at:         event.CreatedAt,
+		Status:             event.Status,
+		Current_period_end: event.CurrentPeriodEnd,
+	}
+	if event.CancelAt.Valid {
+		resp.Cancel_at = &event.CancelAt.Time
+	}
+	return resp
+}
+
+type subscriptionHistoryResponse struct {
+	subscriptionResponse
+	History []subscriptionEventResponse `json:"history"`
+}
+
+// subscriptionHandler shows the caller their subscription and its history.
+func (cfg *apiConfig) subscriptionHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	dbUser, ok := cfg.authenticate(rWriter, rq)
+	if !ok {
+		return
+	}
+
+	sub, err := cfg.dbQueries.GetSubscriptionFromUser(rq.Context(), dbUser.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rWriter, rq, apierror.NotFound("no subscription found"))
+		return
+	}
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("retrieving subscription: %w", err))
+		return
+	}
+
+	events, err := cfg.dbQueries.GetSubscriptionEvents(rq.Context(), sub.ID)
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("retrieving subscription history: %w", err))
+		return
+	}
+
+	respBody := subscriptionHistoryResponse{
+		subscriptionResponse: newSubscriptionResponse(sub),
+		History:              []subscriptionEventResponse{},
+	}
+	for _, event := range events {
+		respBody.History = append(respBody.History, newSubscriptionEventResponse(event))
+	}
+
+	respondWithJSON(rWriter, 200, respBody)
+}
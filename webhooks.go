@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jamistoso/chirpy/internal/apierror"
+	"github.com/jamistoso/chirpy/internal/audit"
+	"github.com/jamistoso/chirpy/internal/config"
+	"github.com/jamistoso/chirpy/internal/database"
+	"github.com/jamistoso/chirpy/internal/decode"
+	"github.com/jamistoso/chirpy/internal/jobs"
+	"github.com/jamistoso/chirpy/internal/webhooks"
+)
+
+const (
+	maxWebhookEndpoints  = 10
+	webhookDeliveryLimit = 50
+)
+
+// webhookEventJob is an event to fan out to a user's endpoints. EventID is
+// picked when the job is queued, so every run of the job fans out the same
+// event.
+type webhookEventJob struct {
+	EventID   uuid.UUID       `json:"event_id"`
+	UserID    uuid.UUID       `json:"user_id"`
+	EventType string          `json:"event_type"`
+	Data      json.RawMessage `json:"data"`
+}
+
+const jobWebhookEvent jobs.Kind[webhookEventJob] = "webhooks.event"
+
+// emitWebhook queues eventType for the user's webhook endpoints as a job on
+// tx, so the event is only sent if the change that caused it commits.
+func emitWebhook(ctx context.Context, tx jobs.Enqueuer, userID uuid.UUID, eventType string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = jobs.Enqueue(ctx, tx, jobWebhookEvent, webhookEventJob{
+		EventID:   uuid.New(),
+		UserID:    userID,
+		EventType: eventType,
+		Data:      raw,
+	})
+	return err
+}
+
+// fanOutWebhook turns a webhook event into a delivery per subscribed
+// endpoint. webhooks.Enqueue skips endpoints that already have a delivery
+// of the event, so a job retried after creating some of them, or after
+// finishing but failing to be marked done, doesn't deliver anything twice.
+func (cfg *apiConfig) fanOutWebhook(ctx context.Context, ev webhookEventJob) error {
+	if ev.EventID == uuid.Nil {
+		// Without an ID a retry would deliver it again as a new event.
+		return jobs.Permanent(errors.New("webhook event has no id"))
+	}
+	return webhooks.Enqueue(ctx, cfg.dbQueries, ev.EventID, ev.UserID, ev.EventType, ev.Data)
+}
+
+type chirpEventData struct {
+	ID         uuid.UUID `json:"id"`
+	Created_at time.Time `json:"created_at"`
+	Updated_at time.Time `json:"updated_at"`
+	Body       string    `json:"body"`
+	User_id    uuid.UUID `json:"user_id"`
+}
+
+func newChirpEventData(chirp database.Chirp) chirpEventData {
+	return chirpEventData{
+		ID:         chirp.ID,
+		Created_at: chirp.CreatedAt,
+		Updated_at: chirp.UpdatedAt,
+		Body:       chirp.Body,
+		User_id:    chirp.UserID.UUID,
+	}
+}
+
+type webhookEndpointResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Created_at  time.Time `json:"created_at"`
+	Url         string    `json:"url"`
+	Event_types []string  `json:"event_types"`
+	Secret      string    `json:"secret,omitempty"`
+}
+
+func newWebhookEndpointResponse(endpoint database.WebhookEndpoint) webhookEndpointResponse {
+	return webhookEndpointResponse{
+		ID:          endpoint.ID,
+		Created_at:  endpoint.CreatedAt,
+		Url:         endpoint.Url,
+		Event_types: config.SplitList(endpoint.EventTypes),
+	}
+}
+
+type webhookDeliveryResponse struct {
+	ID              uuid.UUID       `json:"id"`
+	Created_at      time.Time       `json:"created_at"`
+	Event_type      string          `json:"event_type"`
+	Status          string          `json:"status"`
+	Attempts        int32           `json:"attempts"`
+	Next_attempt_at *time.Time      `json:"next_attempt_at"`
+	Payload         json.RawMessage `json:"payload"`
+}
+
+func newWebhookDeliveryResponse(delivery database.WebhookDelivery) webhookDeliveryResponse {
+	resp := webhookDeliveryResponse{
+		ID:         delivery.ID,
+		Created_at: delivery.CreatedAt,
+		Event_type: delivery.EventType,
+		Status:     delivery.Status,
+		Attempts:   delivery.Attempts,
+		Payload:    delivery.Payload,
+	}
+	if delivery.Status == webhooks.StatusPending {
+		resp.Next_attempt_at = &delivery.NextAttemptAt
+	}
+	return resp
+}
+
+type webhookAttemptResponse struct {
+	Created_at      time.Time `json:"created_at"`
+	Response_status *int32    `json:"response_status"`
+	Error           string    `json:"error,omitempty"`
+	Duration_ms     int64     `json:"duration_ms"`
+}
+
+func newWebhookAttemptResponse(attempt database.WebhookDeliveryAttempt) webhookAttemptResponse {
+	resp := webhookAttemptResponse{
+		Created_at:  attempt.CreatedAt,
+		Error:       attempt.Error,
+		Duration_ms: attempt.DurationMs,
+	}
+	if attempt.ResponseStatus.Valid {
+		resp.Response_status = &attempt.ResponseStatus.Int32
+	}
+	return resp
+}
+
+type webhookDeliveryDetailResponse struct {
+	webhookDeliveryResponse
+	Attempt_log []webhookAttemptResponse `json:"attempt_log"`
+}
+
+// createWebhookHandler registers an endpoint. The signing secret is only
+// ever returned here.
+func (cfg *apiConfig) createWebhookHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	type parameters struct {
+		Url         string   `json:"url" validate:"required,max=2048"`
+		Event_types []string `json:"event_types"`
+	}
+
+	dbUser, ok := cfg.authenticate(rWriter, rq)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	err := decode.JSON(rWriter, rq, &params)
+	if err != nil {
+		respondWithError(rWriter, rq, err)
+		return
+	}
+
+	err = webhooks.ValidateURL(params.Url, cfg.webhookLoopback)
+	if err != nil {
+		respondWithError(rWriter, rq, apierror.Validation(apierror.FieldError{Field: "url", Message: err.Error()}))
+		return
+	}
+	eventTypes, err := webhooks.ParseEventTypes(params.Event_types)
+	if err != nil {
+		respondWithError(rWriter, rq, apierror.Validation(apierror.FieldError{Field: "event_types", Message: err.Error()}))
+		return
+	}
+
+	existing, err := cfg.dbQueries.GetWebhookEndpointsFromUser(rq.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("retrieving webhooks: %w", err))
+		return
+	}
+	if len(existing) >= maxWebhookEndpoints {
+		respondWithError(rWriter, rq, apierror.Conflict(apierror.CodeLimitReached, "webhook endpoint limit reached"))
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("creating webhook secret: %w", err))
+		return
+	}
+
+	endpoint, err := cfg.dbQueries.CreateWebhookEndpoint(rq.Context(), database.CreateWebhookEndpointParams{
+		UserID:     dbUser.ID,
+		Url:        params.Url,
+		Secret:     secret,
+		EventTypes: eventTypes,
+	})
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("creating webhook: %w", err))
+		return
+	}
+
+	cfg.audit(rq, audit.Event{
+		ActorID:    dbUser.ID,
+		ActorType:  audit.ActorUser,
+		Action:     "webhook.create",
+		TargetType: "webhook_endpoint",
+		TargetID:   endpoint.ID.String(),
+		After:      map[string]string{"url": endpoint.Url, "event_types": endpoint.EventTypes},
+	})
+
+	resp := newWebhookEndpointResponse(endpoint)
+	resp.Secret = endpoint.Secret
+	respondWithJSON(rWriter, 201, resp)
+}
+
+func (cfg *apiConfig) listWebhooksHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	dbUser, ok := cfg.authenticate(rWriter, rq)
+	if !ok {
+		return
+	}
+
+	endpoints, err := cfg.dbQueries.GetWebhookEndpointsFromUser(rq.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("retrieving webhooks: %w", err))
+		return
+	}
+
+	respBody := []webhookEndpointResponse{}
+	for _, endpoint := range endpoints {
+		respBody = append(respBody, newWebhookEndpointResponse(endpoint))
+	}
+	respondWithJSON(rWriter, 200, respBody)
+}
+
+func (cfg *apiConfig) deleteWebhookHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	dbUser, ok := cfg.authenticate(rWriter, rq)
+	if !ok {
+		return
+	}
+
+	endpointID, err := uuid.Parse(rq.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(rWriter, rq, apierror.InvalidParameter("endpointID", "must be a UUID"))
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteWebhookEndpoint(rq.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: dbUser.ID,
+	})
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("deleting webhook: %w", err))
+		return
+	}
+	if deleted == 0 {
+		respondWithError(rWriter, rq, apierror.NotFound("webhook not found"))
+		return
+	}
+
+	cfg.audit(rq, audit.Event{
+		ActorID:    dbUser.ID,
+		ActorType:  audit.ActorUser,
+		Action:     "webhook.delete",
+		TargetType: "webhook_endpoint",
+		TargetID:   endpointID.String(),
+	})
+
+	respondWithJSON(rWriter, 204, nil)
+}
+
+// ownWebhookEndpoint loads the endpoint named in the path, responding 404 if
+// it doesn't exist or belongs to someone else.
+func (cfg *apiConfig) ownWebhookEndpoint(rWriter http.ResponseWriter, rq *http.Request, dbUser database.User) (database.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(rq.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(rWriter, rq, apierror.InvalidParameter("endpointID", "must be a UUID"))
+		return database.WebhookEndpoint{}, false
+	}
+	endpoint, err := cfg.dbQueries.GetWebhookEndpoint(rq.Context(), endpointID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rWriter, rq, fmt.Errorf("retrieving webhook: %w", err))
+		return database.WebhookEndpoint{}, false
+	}
+	if err != nil || endpoint.UserID != dbUser.ID {
+		respondWithError(rWriter, rq, apierror.NotFound("webhook not found"))
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+// ownWebhookDelivery is ownWebhookEndpoint for a delivery of that endpoint.
+func (cfg *apiConfig) ownWebhookDelivery(rWriter http.ResponseWriter, rq *http.Request, dbUser database.User) (database.WebhookDelivery, bool) {
+	endpoint, ok := cfg.ownWebhookEndpoint(rWriter, rq, dbUser)
+	if !ok {
+		return database.WebhookDelivery{}, false
+	}
+	deliveryID, err := uuid.Parse(rq.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(rWriter, rq, apierror.InvalidParameter("deliveryID", "must be a UUID"))
+		return database.WebhookDelivery{}, false
+	}
+	delivery, err := cfg.dbQueries.GetWebhookDelivery(rq.Context(), deliveryID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rWriter, rq, fmt.Errorf("retrieving delivery: %w", err))
+		return database.WebhookDelivery{}, false
+	}
+	if err != nil || delivery.EndpointID != endpoint.ID {
+		respondWithError(rWriter, rq, apierror.NotFound("delivery not found"))
+		return database.WebhookDelivery{}, false
+	}
+	return delivery, true
+}
+
+// webhookDeliveriesHandler lists an endpoint's most recent deliveries.
+func (cfg *apiConfig) webhookDeliveriesHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	dbUser, ok := cfg.authenticate(rWriter, rq)
+	if !ok {
+		return
+	}
+	endpoint, ok := cfg.ownWebhookEndpoint(rWriter, rq, dbUser)
+	if !ok {
+		return
+	}
+
+	deliveries, err := cfg.dbQueries.GetWebhookDeliveriesFromEndpoint(rq.Context(), database.GetWebhookDeliveriesFromEndpointParams{
+		EndpointID:    endpoint.ID,
+		MaxDeliveries: webhookDeliveryLimit,
+	})
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("retrieving deliveries: %w", err))
+		return
+	}
+
+	respBody := []webhookDeliveryResponse{}
+	for _, delivery := range deliveries {
+		respBody = append(respBody, newWebhookDeliveryResponse(delivery))
+	}
+	respondWithJSON(rWriter, 200, respBody)
+}
+
+// webhookDeliveryHandler shows one delivery with the log of its attempts.
+func (cfg *apiConfig) webhookDeliveryHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	dbUser, ok := cfg.authenticate(rWriter, rq)
+	if !ok {
+		return
+	}
+	delivery, ok := cfg.ownWebhookDelivery(rWriter, rq, dbUser)
+	if !ok {
+		return
+	}
+
+	attempts, err := cfg.dbQueries.GetWebhookDeliveryAttempts(rq.Context(), delivery.ID)
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("retrieving delivery attempts: %w", err))
+		return
+	}
+
+	respBody := webhookDeliveryDetailResponse{
+		webhookDeliveryResponse: newWebhookDeliveryResponse(delivery),
+		Attempt_log:             []webhookAttemptResponse{},
+	}
+	for _, attempt := range attempts {
+		respBody.Attempt_log = append(respBody.Attempt_log, newWebhookAttemptResponse(attempt))
+	}
+	respondWithJSON(rWriter, 200, respBody)
+}
+
+// redeliverWebhookHandler puts a delivery back in the queue with a fresh set
+// of attempts, whether it succeeded, is still retrying or was dead lettered.
+func (cfg *apiConfig) redeliverWebhookHandler(rWriter http.ResponseWriter, rq *http.Request) {
+	dbUser, ok := cfg.authenticate(rWriter, rq)
+	if !ok {
+		return
+	}
+	delivery, ok := cfg.ownWebhookDelivery(rWriter, rq, dbUser)
+	if !ok {
+		return
+	}
+
+	delivery, err := cfg.dbQueries.RedeliverWebhookDelivery(rq.Context(), delivery.ID)
+	if err != nil {
+		respondWithError(rWriter, rq, fmt.Errorf("scheduling redelivery: %w", err))
+		return
+	}
+
+	respondWithJSON(rWriter, 202, newWebhookDeliveryResponse(delivery))
+}